@@ -0,0 +1,421 @@
+package ihttp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gitkeng/ihttp/util/cryptutil"
+	"github.com/gitkeng/ihttp/util/id"
+	"github.com/gitkeng/ihttp/util/stringutil"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// APIKey is the stored API key of a machine client, the secret is kept as its salted HMAC only
+type APIKey struct {
+	ID     string   `json:"id"`
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	// Quota is the max requests per QuotaWindow, 0 is unlimited
+	Quota       int           `json:"quota,omitempty"`
+	QuotaWindow time.Duration `json:"quota_window,omitempty"`
+	Salt        string        `json:"salt,omitempty"`
+	Hash        string        `json:"hash,omitempty"`
+	CreatedAt   time.Time     `json:"created_at"`
+	ExpiresAt   *time.Time    `json:"expires_at,omitempty"`
+	RevokedAt   *time.Time    `json:"revoked_at,omitempty"`
+}
+
+// Active report whether the key is not revoked or expired
+func (key *APIKey) Active() bool {
+	return key.RevokedAt == nil && (key.ExpiresAt == nil || time.Now().Before(*key.ExpiresAt))
+}
+
+// public return the key without its salt and hash
+func (key *APIKey) public() *APIKey {
+	public := *key
+	public.Salt, public.Hash = "", ""
+	return &public
+}
+
+// APIKeyRequest is the request creating an API key
+type APIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+	Quota  int      `json:"quota"`
+	// QuotaWindowSeconds is the quota window, default DefaultAPIKeyQuotaWindow
+	QuotaWindowSeconds int        `json:"quota_window_seconds"`
+	ExpiresAt          *time.Time `json:"expires_at"`
+}
+
+func (req *APIKeyRequest) Validate() error {
+	var errs Errors
+	if stringutil.IsEmptyString(req.Name) {
+		errs = append(errs, NewError("NAME_REQUIRED", "name is required"))
+	}
+	if req.Quota < 0 || req.QuotaWindowSeconds < 0 {
+		errs = append(errs, NewError("QUOTA_INVALID", "quota and quota window must not be negative"))
+	}
+	if len(errs) > 0 {
+		return &errs
+	}
+	return nil
+}
+
+// APIKeyManagerConfig is the config of APIKeyManager
+type APIKeyManagerConfig struct {
+	// Store keep the keys, see NewRedisAPIKeyStore and NewDBAPIKeyStore
+	Store IAPIKeyStore
+	// QuotaCache count the requests of the keys with quota across the replicas,
+	// nil count them in the memory of the replica
+	QuotaCache IRedisCache
+	// QuotaKeyPrefix is the redis key prefix of the quota counters, default DefaultAPIKeyQuotaKeyPrefix
+	QuotaKeyPrefix string
+	// CacheTTL is the time a verified key is cached in memory, default DefaultAPIKeyCacheTTL,
+	// a key revoked on another replica is accepted here until it expires from the cache
+	CacheTTL time.Duration
+}
+
+// APIKeyManager create, verify and revoke the API keys.
+//
+// An API key is "<id>.<secret>", the store keep the id with a random salt and the HMAC-SHA256 of
+// the secret keyed by the salt, the plaintext is returned by Create only.
+type APIKeyManager struct {
+	config APIKeyManagerConfig
+
+	mutex    sync.Mutex
+	verified map[string]verifiedAPIKey
+	counters map[string]*apiKeyCounter
+}
+
+type verifiedAPIKey struct {
+	key      *APIKey
+	cachedAt time.Time
+}
+
+type apiKeyCounter struct {
+	window int64
+	count  int
+}
+
+// NewAPIKeyManager return new APIKeyManager
+func NewAPIKeyManager(cfg APIKeyManagerConfig) (*APIKeyManager, error) {
+	if cfg.Store == nil {
+		return nil, ErrAPIKeyStoreIsRequire
+	}
+	if cfg.QuotaKeyPrefix == "" {
+		cfg.QuotaKeyPrefix = DefaultAPIKeyQuotaKeyPrefix
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultAPIKeyCacheTTL
+	}
+	return &APIKeyManager{
+		config:   cfg,
+		verified: make(map[string]verifiedAPIKey),
+		counters: make(map[string]*apiKeyCounter),
+	}, nil
+}
+
+// Create store a new key and return it with its plaintext, the plaintext cannot be read again
+func (manager *APIKeyManager) Create(ctx context.Context, req APIKeyRequest) (*APIKey, string, error) {
+	if err := req.Validate(); err != nil {
+		return nil, "", err
+	}
+	// the secret is url safe so the key can be sent in a query parameter
+	raw := make([]byte, DefaultAPIKeySecretSize)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", err
+	}
+	secret := base64.RawURLEncoding.EncodeToString(raw)
+	salt := cryptutil.RandToken(DefaultAPIKeySecretSize)
+	hash, err := cryptutil.HashSHA256(secret, salt)
+	if err != nil {
+		return nil, "", err
+	}
+	key := &APIKey{
+		ID:        id.UUID(),
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		Quota:     req.Quota,
+		Salt:      salt,
+		Hash:      hash,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: req.ExpiresAt,
+	}
+	if key.Quota > 0 {
+		key.QuotaWindow = time.Duration(req.QuotaWindowSeconds) * time.Second
+		if key.QuotaWindow <= 0 {
+			key.QuotaWindow = DefaultAPIKeyQuotaWindow
+		}
+	}
+	if err := manager.config.Store.Save(ctx, key); err != nil {
+		return nil, "", err
+	}
+	return key.public(), key.ID + "." + secret, nil
+}
+
+// List return every key without its salt and hash
+func (manager *APIKeyManager) List(ctx context.Context) ([]*APIKey, error) {
+	keys, err := manager.config.Store.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for idx := range keys {
+		keys[idx] = keys[idx].public()
+	}
+	return keys, nil
+}
+
+// Revoke revoke the key and drop it from the cache of the replica
+func (manager *APIKeyManager) Revoke(ctx context.Context, keyID string) error {
+	if err := manager.config.Store.Revoke(ctx, keyID); err != nil {
+		return err
+	}
+	manager.mutex.Lock()
+	defer manager.mutex.Unlock()
+	for digest, verified := range manager.verified {
+		if verified.key.ID == keyID {
+			delete(manager.verified, digest)
+		}
+	}
+	return nil
+}
+
+// Verify return the active key of the plaintext, it return an error matching ErrAPIKeyInvalid otherwise
+func (manager *APIKeyManager) Verify(ctx context.Context, plaintext string) (*APIKey, error) {
+	// the cache is keyed by the digest so the plaintext is not kept in memory
+	sum := sha256.Sum256([]byte(plaintext))
+	digest := hex.EncodeToString(sum[:])
+	manager.mutex.Lock()
+	verified, found := manager.verified[digest]
+	manager.mutex.Unlock()
+	if found && time.Since(verified.cachedAt) < manager.config.CacheTTL {
+		if !verified.key.Active() {
+			return nil, ErrAPIKeyInvalid
+		}
+		return verified.key, nil
+	}
+
+	keyID, secret, ok := strings.Cut(plaintext, ".")
+	if !ok || keyID == "" || secret == "" {
+		return nil, ErrAPIKeyInvalid
+	}
+	key, err := manager.config.Store.Find(ctx, keyID)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrAPIKeyInvalid
+	}
+	hash, err := cryptutil.HashSHA256(secret, key.Salt)
+	if err != nil {
+		return nil, err
+	}
+	if !hmac.Equal([]byte(hash), []byte(key.Hash)) {
+		return nil, ErrAPIKeyInvalid
+	}
+	manager.mutex.Lock()
+	manager.verified[digest] = verifiedAPIKey{key: key, cachedAt: time.Now()}
+	manager.mutex.Unlock()
+	if !key.Active() {
+		return nil, ErrAPIKeyInvalid
+	}
+	return key, nil
+}
+
+// Allow count a request of the key and report whether it is within the quota,
+// it also return the remaining requests and the end of the current window
+func (manager *APIKeyManager) Allow(key *APIKey) (bool, int, time.Time, error) {
+	if key.Quota <= 0 {
+		return true, -1, time.Time{}, nil
+	}
+	window := time.Now().UnixNano() / int64(key.QuotaWindow)
+	reset := time.Unix(0, (window+1)*int64(key.QuotaWindow))
+
+	var count int
+	if manager.config.QuotaCache != nil {
+		counterKey := manager.config.QuotaKeyPrefix + ":" + key.ID + ":" + strconv.FormatInt(window, 10)
+		var err error
+		if count, err = manager.config.QuotaCache.Incr(counterKey); err != nil {
+			return false, 0, reset, err
+		}
+		if count == 1 {
+			if err := manager.config.QuotaCache.Expire(counterKey, key.QuotaWindow); err != nil {
+				return false, 0, reset, err
+			}
+		}
+	} else {
+		manager.mutex.Lock()
+		counter, found := manager.counters[key.ID]
+		if !found || counter.window != window {
+			counter = &apiKeyCounter{window: window}
+			manager.counters[key.ID] = counter
+		}
+		counter.count++
+		count = counter.count
+		manager.mutex.Unlock()
+	}
+	remaining := key.Quota - count
+	if remaining < 0 {
+		remaining = 0
+	}
+	return count <= key.Quota, remaining, reset, nil
+}
+
+// APIKeyConfig is the config of the API key middleware
+type APIKeyConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+	// Manager verify the keys
+	Manager *APIKeyManager
+	// KeyLookup is the comma separated sources of the key, "header:<name>" or "query:<name>",
+	// default "header:X-API-Key"
+	KeyLookup string
+}
+
+// APIKey return the middleware authenticating the API key of the request, the principal of a valid key
+// has the key id as subject and the key scopes so RequireScopes applies to it.
+// Missing and invalid keys are answered 401, exceeded quotas 429.
+func (ms *Microservice) APIKey(config APIKeyConfig) (echo.MiddlewareFunc, error) {
+	if config.Manager == nil {
+		return nil, ErrAPIKeyManagerIsRequire
+	}
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.KeyLookup == "" {
+		config.KeyLookup = DefaultAPIKeyLookup
+	}
+	extractors := make([]func(c echo.Context) string, 0)
+	for _, source := range strings.Split(config.KeyLookup, ",") {
+		kind, name, found := strings.Cut(strings.TrimSpace(source), ":")
+		if !found || name == "" {
+			return nil, ErrAPIKeyLookupInvalid(source)
+		}
+		switch kind {
+		case "header":
+			extractors = append(extractors, func(c echo.Context) string {
+				return c.Request().Header.Get(name)
+			})
+		case "query":
+			extractors = append(extractors, func(c echo.Context) string {
+				return c.QueryParam(name)
+			})
+		default:
+			return nil, ErrAPIKeyLookupInvalid(source)
+		}
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			ctx := NewHTTPContext(ms, c)
+			var plaintext string
+			for _, extract := range extractors {
+				if plaintext = extract(c); plaintext != "" {
+					break
+				}
+			}
+			if plaintext == "" {
+				return ctx.Response(WarnLevel, "APIKEY", http.StatusUnauthorized, "UNAUTHORIZED", "api key is required", ErrAPIKeyMissing)
+			}
+			key, err := config.Manager.Verify(c.Request().Context(), plaintext)
+			if errors.Is(err, ErrAPIKeyInvalid) {
+				return ctx.Response(WarnLevel, "APIKEY", http.StatusUnauthorized, "INVALID_API_KEY", "api key is invalid", err)
+			}
+			if err != nil {
+				return ctx.Response(ErrorLevel, "APIKEY", http.StatusInternalServerError, "INTERNAL_ERROR", "cannot verify api key", err)
+			}
+			allowed, remaining, reset, err := config.Manager.Allow(key)
+			if err != nil {
+				return ctx.Response(ErrorLevel, "APIKEY", http.StatusInternalServerError, "INTERNAL_ERROR", "cannot count api key quota", err)
+			}
+			if key.Quota > 0 {
+				header := c.Response().Header()
+				header.Set("X-RateLimit-Limit", strconv.Itoa(key.Quota))
+				header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+				header.Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+			}
+			if !allowed {
+				c.Response().Header().Set("Retry-After", strconv.Itoa(int(time.Until(reset).Seconds())+1))
+				return ctx.Response(WarnLevel, "APIKEY", http.StatusTooManyRequests, "QUOTA_EXCEEDED", "api key quota exceeded", ErrAPIKeyQuotaExceeded(key.ID))
+			}
+			c.Set(principalContextKey, &Principal{
+				Subject: key.ID,
+				Scopes:  key.Scopes,
+				Claims: map[string]any{
+					"name": key.Name,
+					"auth": "api_key",
+				},
+			})
+			return next(c)
+		}
+	}, nil
+}
+
+// APIKeyAdmin register the admin endpoints of the keys under path, m must restrict them to administrators
+// and it panic when m is empty:
+//
+//	POST   path      create a key, the response is the only time the plaintext is shown
+//	GET    path      list the keys
+//	DELETE path/:id  revoke the key
+func (ms *Microservice) APIKeyAdmin(path string, manager *APIKeyManager, m ...echo.MiddlewareFunc) {
+	if len(m) == 0 {
+		panic(ErrAPIKeyAdminAuthIsRequire)
+	}
+	path = strings.TrimSuffix(path, "/")
+	ms.POST(path, func(ctx IContext) error {
+		req := &APIKeyRequest{}
+		if err := ctx.Bind(req); err != nil {
+			return ctx.Response(WarnLevel, "APIKEY", http.StatusBadRequest, "BAD_REQUEST", "invalid api key request", err)
+		}
+		key, plaintext, err := manager.Create(ctx.WebContext().Request().Context(), *req)
+		var invalid *Errors
+		if errors.As(err, &invalid) {
+			return ctx.Response(WarnLevel, "APIKEY", http.StatusBadRequest, "BAD_REQUEST", "invalid api key request", err)
+		}
+		if err != nil {
+			return ctx.Response(ErrorLevel, "APIKEY", http.StatusInternalServerError, "INTERNAL_ERROR", "cannot create api key", err)
+		}
+		// the plaintext is not passed to Response so it is never logged
+		ms.logger.Infof("[APIKEY] api key %s (%s) created", key.ID, key.Name)
+		return ctx.WebContext().JSON(http.StatusCreated, Response{
+			RequestId:  ctx.WebContext().Response().Header().Get(echo.HeaderXRequestID),
+			StatusCode: http.StatusCreated,
+			Code:       "CREATED",
+			Message:    "store the api key now, it cannot be shown again",
+			Data: map[string]any{
+				"api_key": key,
+				"key":     plaintext,
+			},
+		})
+	}, m...)
+	ms.GET(path, func(ctx IContext) error {
+		keys, err := manager.List(ctx.WebContext().Request().Context())
+		if err != nil {
+			return ctx.Response(ErrorLevel, "APIKEY", http.StatusInternalServerError, "INTERNAL_ERROR", "cannot list api keys", err)
+		}
+		return ctx.Response(InfoLevel, "APIKEY", http.StatusOK, "OK", "api keys", nil, Field{Key: "api_keys", Value: keys})
+	}, m...)
+	ms.DELETE(path+"/:id", func(ctx IContext) error {
+		if err := manager.Revoke(ctx.WebContext().Request().Context(), ctx.Param("id")); err != nil {
+			if err == ErrAPIKeyNotfound {
+				return ctx.Response(WarnLevel, "APIKEY", http.StatusNotFound, "NOT_FOUND", "api key not found", err)
+			}
+			return ctx.Response(ErrorLevel, "APIKEY", http.StatusInternalServerError, "INTERNAL_ERROR", "cannot revoke api key", err)
+		}
+		return ctx.Response(InfoLevel, "APIKEY", http.StatusOK, "REVOKED", "api key revoked", nil, Field{Key: "id", Value: ctx.Param("id")})
+	}, m...)
+}
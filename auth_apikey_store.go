@@ -0,0 +1,262 @@
+package ihttp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// IAPIKeyStore keep the API keys of APIKeyManager
+type IAPIKeyStore interface {
+	// Save store the new key
+	Save(ctx context.Context, key *APIKey) error
+	// Find return the key of id, nil when not found
+	Find(ctx context.Context, id string) (*APIKey, error)
+	// List return every key ordered by creation time
+	List(ctx context.Context) ([]*APIKey, error)
+	// Revoke mark the key revoked, it return ErrAPIKeyNotfound when not found
+	Revoke(ctx context.Context, id string) error
+}
+
+// RedisAPIKeyStore keep the API keys as the fields of a redis hash
+type RedisAPIKeyStore struct {
+	cache IRedisCache
+	key   string
+}
+
+// NewRedisAPIKeyStore return new RedisAPIKeyStore of the hash key, default DefaultAPIKeyHashKey
+func NewRedisAPIKeyStore(cache IRedisCache, key string) *RedisAPIKeyStore {
+	if key == "" {
+		key = DefaultAPIKeyHashKey
+	}
+	return &RedisAPIKeyStore{
+		cache: cache,
+		key:   key,
+	}
+}
+
+func (store *RedisAPIKeyStore) Save(ctx context.Context, key *APIKey) error {
+	raw, err := json.Marshal(key)
+	if err != nil {
+		return err
+	}
+	return store.cache.HSetSNoExpire(store.key, key.ID, string(raw))
+}
+
+func (store *RedisAPIKeyStore) Find(ctx context.Context, id string) (*APIKey, error) {
+	raw, err := store.cache.HGet(store.key, id)
+	if err != nil || raw == "" {
+		return nil, err
+	}
+	key := &APIKey{}
+	if err := json.Unmarshal([]byte(raw), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+func (store *RedisAPIKeyStore) List(ctx context.Context) ([]*APIKey, error) {
+	ids, err := store.cache.HFields(store.key, "*")
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*APIKey, 0, len(ids))
+	for _, id := range ids {
+		key, err := store.Find(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+	return keys, nil
+}
+
+func (store *RedisAPIKeyStore) Revoke(ctx context.Context, id string) error {
+	key, err := store.Find(ctx, id)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return ErrAPIKeyNotfound
+	}
+	if key.RevokedAt != nil {
+		return nil
+	}
+	now := time.Now().UTC()
+	key.RevokedAt = &now
+	return store.Save(ctx, key)
+}
+
+// DBAPIKeyStore keep the API keys in a database table, see APIKeySchema
+type DBAPIKeyStore struct {
+	db    IDBStore
+	table string
+}
+
+// NewDBAPIKeyStore return new DBAPIKeyStore of the table, default DefaultAPIKeyTable
+func NewDBAPIKeyStore(db IDBStore, table string) *DBAPIKeyStore {
+	if table == "" {
+		table = DefaultAPIKeyTable
+	}
+	return &DBAPIKeyStore{
+		db:    db,
+		table: table,
+	}
+}
+
+// APIKeySchema return the statements creating the API key table for the database provider
+func APIKeySchema(provider string, table string) ([]string, error) {
+	columns := map[string][2]string{
+		POSTGRES: {"VARCHAR(64)", "TIMESTAMP"},
+		MYSQL:    {"VARCHAR(64)", "DATETIME(6)"},
+		SQLITE:   {"TEXT", "TIMESTAMP"},
+	}
+	types, found := columns[provider]
+	if !found {
+		return nil, ErrInvalidDBProvider(provider)
+	}
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    id                   %[2]s PRIMARY KEY,
+    name                 VARCHAR(255) NOT NULL,
+    scopes               TEXT NOT NULL,
+    quota                INT NOT NULL DEFAULT 0,
+    quota_window_seconds INT NOT NULL DEFAULT 0,
+    salt                 VARCHAR(255) NOT NULL,
+    hash                 VARCHAR(255) NOT NULL,
+    created_at           %[3]s NOT NULL,
+    expires_at           %[3]s NULL,
+    revoked_at           %[3]s NULL
+)`, table, types[0], types[1]),
+	}, nil
+}
+
+// CreateTable create the API key table when it does not exist
+func (store *DBAPIKeyStore) CreateTable(ctx context.Context) error {
+	statements, err := APIKeySchema(store.db.Provider(), store.table)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err := store.db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type apiKeyRow struct {
+	ID                 string       `db:"id"`
+	Name               string       `db:"name"`
+	Scopes             string       `db:"scopes"`
+	Quota              int          `db:"quota"`
+	QuotaWindowSeconds int          `db:"quota_window_seconds"`
+	Salt               string       `db:"salt"`
+	Hash               string       `db:"hash"`
+	CreatedAt          time.Time    `db:"created_at"`
+	ExpiresAt          sql.NullTime `db:"expires_at"`
+	RevokedAt          sql.NullTime `db:"revoked_at"`
+}
+
+func (row apiKeyRow) apiKey() (*APIKey, error) {
+	key := &APIKey{
+		ID:          row.ID,
+		Name:        row.Name,
+		Quota:       row.Quota,
+		QuotaWindow: time.Duration(row.QuotaWindowSeconds) * time.Second,
+		Salt:        row.Salt,
+		Hash:        row.Hash,
+		CreatedAt:   row.CreatedAt,
+	}
+	if row.ExpiresAt.Valid {
+		key.ExpiresAt = &row.ExpiresAt.Time
+	}
+	if row.RevokedAt.Valid {
+		key.RevokedAt = &row.RevokedAt.Time
+	}
+	if err := json.Unmarshal([]byte(row.Scopes), &key.Scopes); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+const apiKeyColumns = "id, name, scopes, quota, quota_window_seconds, salt, hash, created_at, expires_at, revoked_at"
+
+func (store *DBAPIKeyStore) Save(ctx context.Context, key *APIKey) error {
+	scopes, err := json.Marshal(key.Scopes)
+	if err != nil {
+		return err
+	}
+	row := apiKeyRow{
+		ID:                 key.ID,
+		Name:               key.Name,
+		Scopes:             string(scopes),
+		Quota:              key.Quota,
+		QuotaWindowSeconds: int(key.QuotaWindow / time.Second),
+		Salt:               key.Salt,
+		Hash:               key.Hash,
+		CreatedAt:          key.CreatedAt.UTC(),
+	}
+	if key.ExpiresAt != nil {
+		row.ExpiresAt = sql.NullTime{Time: key.ExpiresAt.UTC(), Valid: true}
+	}
+	_, err = Exec(ctx, store.db, fmt.Sprintf(
+		`INSERT INTO %s (id, name, scopes, quota, quota_window_seconds, salt, hash, created_at, expires_at)
+VALUES (:id, :name, :scopes, :quota, :quota_window_seconds, :salt, :hash, :created_at, :expires_at)`, store.table), row)
+	return err
+}
+
+func (store *DBAPIKeyStore) Find(ctx context.Context, id string) (*APIKey, error) {
+	row, err := QueryOne[apiKeyRow](ctx, store.db, fmt.Sprintf(
+		"SELECT %s FROM %s WHERE id = :id", apiKeyColumns, store.table), map[string]any{"id": id})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return row.apiKey()
+}
+
+func (store *DBAPIKeyStore) List(ctx context.Context) ([]*APIKey, error) {
+	rows, err := QueryAll[apiKeyRow](ctx, store.db, fmt.Sprintf(
+		"SELECT %s FROM %s ORDER BY created_at", apiKeyColumns, store.table), nil)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]*APIKey, 0, len(rows))
+	for _, row := range rows {
+		key, err := row.apiKey()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+func (store *DBAPIKeyStore) Revoke(ctx context.Context, id string) error {
+	result, err := Exec(ctx, store.db, fmt.Sprintf(
+		"UPDATE %s SET revoked_at = :now WHERE id = :id AND revoked_at IS NULL", store.table),
+		map[string]any{"id": id, "now": time.Now().UTC()})
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected > 0 {
+		return err
+	}
+	if key, err := store.Find(ctx, id); err != nil || key != nil {
+		// already revoked
+		return err
+	}
+	return ErrAPIKeyNotfound
+}
@@ -0,0 +1,209 @@
+package ihttp_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gitkeng/ihttp"
+	"github.com/labstack/echo/v4"
+)
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	dbCfg := &ihttp.DBConfig{
+		ContextName:  "local",
+		Provider:     ihttp.SQLITE,
+		DatabaseName: ":memory:",
+	}
+	if err := dbCfg.Bind(); err != nil {
+		t.Error(err)
+		return
+	}
+	db, err := ihttp.NewDBStore(dbCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.Close()
+	dbStore := ihttp.NewDBAPIKeyStore(db, "")
+	if err := dbStore.CreateTable(context.Background()); err != nil {
+		t.Error(err)
+		return
+	}
+
+	stores := map[string]ihttp.IAPIKeyStore{
+		"redis": ihttp.NewRedisAPIKeyStore(newMemoryCache(), ""),
+		"db":    dbStore,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			manager, err := ihttp.NewAPIKeyManager(ihttp.APIKeyManagerConfig{
+				Store:      store,
+				QuotaCache: newMemoryCache(),
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer ms.Cleanup()
+			auth, err := ms.APIKey(ihttp.APIKeyConfig{
+				Manager:   manager,
+				KeyLookup: "header:X-API-Key,query:api_key",
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			ms.APIKeyAdmin("/admin/api-keys", manager, adminOnly)
+			ms.GET("/reports", func(ctx ihttp.IContext) error {
+				principal, _ := ctx.Principal()
+				return ctx.WebContext().String(http.StatusOK, principal.Claims["name"].(string))
+			}, auth, ms.RequireScopes("reports.read"))
+			server := httptest.NewServer(ms.GetEngine())
+			defer server.Close()
+
+			call := func(method string, path string, body string, apiKey string) (*http.Response, *ihttp.Response) {
+				req, _ := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+				req.Header.Set("Content-Type", "application/json")
+				if apiKey != "" {
+					req.Header.Set("X-API-Key", apiKey)
+				}
+				req.Header.Set("X-Admin-Token", "admin-secret")
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				defer resp.Body.Close()
+				envelope := &ihttp.Response{}
+				json.NewDecoder(resp.Body).Decode(envelope)
+				return resp, envelope
+			}
+
+			resp, err := http.Post(server.URL+"/admin/api-keys", "application/json", strings.NewReader(`{"name":"anonymous"}`))
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("expect unauthenticated admin request rejected, got %d", resp.StatusCode)
+			}
+
+			resp, created := call(http.MethodPost, "/admin/api-keys", `{"name":"billing","scopes":["reports.read"],"quota":2}`, "")
+			if resp.StatusCode != http.StatusCreated {
+				t.Errorf("expect key created, got %d %v", resp.StatusCode, created)
+				return
+			}
+			if resp, invalid := call(http.MethodPost, "/admin/api-keys", `{"quota":-1}`, ""); resp.StatusCode != http.StatusBadRequest || len(invalid.Error) != 2 {
+				t.Errorf("expect 2 validation errors, got %d %v", resp.StatusCode, invalid.Error)
+			}
+			plaintext := created.Data["key"].(string)
+			keyID := created.Data["api_key"].(map[string]any)["id"].(string)
+			_, limited := call(http.MethodPost, "/admin/api-keys", `{"name":"monitor"}`, "")
+			noScopeKey := limited.Data["key"].(string)
+
+			_, listed := call(http.MethodGet, "/admin/api-keys", "", "")
+			raw, _ := json.Marshal(listed.Data)
+			if keys := listed.Data["api_keys"].([]any); len(keys) != 2 || strings.Contains(string(raw), `"hash"`) {
+				t.Errorf("expect 2 keys without hashes, got %s", raw)
+			}
+
+			if resp, _ := call(http.MethodGet, "/reports", "", plaintext); resp.StatusCode != http.StatusOK || resp.Header.Get("X-RateLimit-Remaining") != "1" {
+				t.Errorf("expect key accepted, got %d remaining %s", resp.StatusCode, resp.Header.Get("X-RateLimit-Remaining"))
+			}
+			if resp, _ := call(http.MethodGet, "/reports?api_key="+url.QueryEscape(plaintext), "", ""); resp.StatusCode != http.StatusOK {
+				t.Errorf("expect key accepted from query, got %d", resp.StatusCode)
+			}
+			if resp, envelope := call(http.MethodGet, "/reports", "", plaintext); resp.StatusCode != http.StatusTooManyRequests || envelope.Code != "QUOTA_EXCEEDED" || resp.Header.Get("Retry-After") == "" {
+				t.Errorf("expect quota exceeded, got %d %s", resp.StatusCode, envelope.Code)
+			}
+			if resp, _ := call(http.MethodGet, "/reports", "", noScopeKey); resp.StatusCode != http.StatusForbidden {
+				t.Errorf("expect missing scope rejected, got %d", resp.StatusCode)
+			}
+			if resp, envelope := call(http.MethodGet, "/reports", "", plaintext[:len(plaintext)-1]+"x"); resp.StatusCode != http.StatusUnauthorized || envelope.Code != "INVALID_API_KEY" {
+				t.Errorf("expect tampered key rejected, got %d %s", resp.StatusCode, envelope.Code)
+			}
+
+			if resp, _ := call(http.MethodDelete, "/admin/api-keys/"+keyID, "", ""); resp.StatusCode != http.StatusOK {
+				t.Errorf("expect key revoked, got %d", resp.StatusCode)
+			}
+			if resp, _ := call(http.MethodDelete, "/admin/api-keys/unknown", "", ""); resp.StatusCode != http.StatusNotFound {
+				t.Errorf("expect unknown key not found, got %d", resp.StatusCode)
+			}
+			if resp, _ := call(http.MethodGet, "/reports", "", plaintext); resp.StatusCode != http.StatusUnauthorized {
+				t.Errorf("expect revoked key rejected, got %d", resp.StatusCode)
+			}
+		})
+	}
+}
+
+// adminOnly is the admin authorization middleware of the tests
+func adminOnly(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if c.Request().Header.Get("X-Admin-Token") != "admin-secret" {
+			return echo.ErrUnauthorized
+		}
+		return next(c)
+	}
+}
+
+func TestAPIKeyAdminRequireMiddleware(t *testing.T) {
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ms.Cleanup()
+	defer func() {
+		if recovered := recover(); recovered != ihttp.ErrAPIKeyAdminAuthIsRequire {
+			t.Errorf("expect panic %v got %v", ihttp.ErrAPIKeyAdminAuthIsRequire, recovered)
+		}
+	}()
+	ms.APIKeyAdmin("/admin/api-keys", &ihttp.APIKeyManager{})
+}
+
+// failingAPIKeyStore is the store whose backend is down
+type failingAPIKeyStore struct {
+	ihttp.IAPIKeyStore
+}
+
+func (store failingAPIKeyStore) Find(ctx context.Context, id string) (*ihttp.APIKey, error) {
+	return nil, errors.New("store is down")
+}
+
+func TestAPIKeyStoreError(t *testing.T) {
+	manager, err := ihttp.NewAPIKeyManager(ihttp.APIKeyManagerConfig{Store: failingAPIKeyStore{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ms.Cleanup()
+	auth, err := ms.APIKey(ihttp.APIKeyConfig{Manager: manager})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ms.GET("/reports", func(ctx ihttp.IContext) error {
+		return ctx.WebContext().NoContent(http.StatusOK)
+	}, auth)
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/reports", nil)
+	req.Header.Set("X-API-Key", "key-id.secret")
+	ms.GetEngine().ServeHTTP(rec, req)
+	envelope := &ihttp.Response{}
+	json.Unmarshal(rec.Body.Bytes(), envelope)
+	if rec.Code != http.StatusInternalServerError || envelope.Code != "INTERNAL_ERROR" {
+		t.Errorf("expect store error answered 500, got %d %s", rec.Code, envelope.Code)
+	}
+}
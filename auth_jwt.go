@@ -0,0 +1,178 @@
+package ihttp
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/golang-jwt/jwt"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+// JWTConfig is the config of the JWT middleware
+type JWTConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+	// Secret is the HS256 key, SecretFile read it from a file
+	Secret     string
+	SecretFile string
+	// PublicKeyFiles is the RS256 and ES256 PEM public key files by key id,
+	// the "" key id verify the tokens without kid header
+	PublicKeyFiles map[string]string
+	// TokenIssuer verify the tokens signed by the TokenIssuer of the service with its current keys
+	TokenIssuer *TokenIssuer
+	// JWKSURL is the JWKS document of the RS256 and ES256 keys
+	JWKSURL string
+	// JWKSRefreshInterval is the interval the JWKS document is fetched again, default DefaultJWKSRefreshInterval
+	JWKSRefreshInterval time.Duration
+	// Algorithms is the accepted algorithms, default HS256, RS256 and ES256
+	Algorithms []string
+	// Issuer is the required iss claim, empty accept any issuer
+	Issuer string
+	// Audience accept the tokens with one of them in the aud claim, empty accept any audience
+	Audience []string
+	// Leeway is the accepted clock skew of exp, nbf and iat
+	Leeway time.Duration
+	// ScopeClaim is the claim of the space separated or array scopes, default "scope"
+	ScopeClaim string
+	// RoleClaim is the claim of the roles, default "roles"
+	RoleClaim string
+}
+
+// JWT return the middleware authenticating the Authorization bearer token, the principal of a valid token
+// is available with IContext.Principal. Missing and invalid tokens are answered 401 with the Response envelope.
+func (ms *Microservice) JWT(config JWTConfig) (echo.MiddlewareFunc, error) {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if len(config.Algorithms) == 0 {
+		config.Algorithms = []string{"HS256", "RS256", "ES256"}
+	}
+	if config.JWKSRefreshInterval <= 0 {
+		config.JWKSRefreshInterval = DefaultJWKSRefreshInterval
+	}
+	if config.ScopeClaim == "" {
+		config.ScopeClaim = "scope"
+	}
+	if config.RoleClaim == "" {
+		config.RoleClaim = "roles"
+	}
+
+	keys := &jwtKeySet{
+		secret:          []byte(config.Secret),
+		issuer:          config.TokenIssuer,
+		static:          make(map[string]any, len(config.PublicKeyFiles)),
+		jwksURL:         config.JWKSURL,
+		refreshInterval: config.JWKSRefreshInterval,
+		client:          resty.New().SetTimeout(DefaultJWKSFetchTimeout),
+	}
+	if config.SecretFile != "" {
+		secret, err := os.ReadFile(config.SecretFile)
+		if err != nil {
+			return nil, err
+		}
+		keys.secret = []byte(strings.TrimSpace(string(secret)))
+	}
+	for kid, file := range config.PublicKeyFiles {
+		key, err := LoadPublicKeyFile(file)
+		if err != nil {
+			return nil, err
+		}
+		keys.static[kid] = key
+	}
+	if len(keys.secret) == 0 && len(keys.static) == 0 && keys.jwksURL == "" && keys.issuer == nil {
+		return nil, ErrJWTKeyIsRequire
+	}
+	verifier := &jwtVerifier{config: config, keys: keys}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			ctx := NewHTTPContext(ms, c)
+			token, found := bearerToken(c.Request())
+			if !found {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer`)
+				return ctx.Response(WarnLevel, "JWT", http.StatusUnauthorized, "UNAUTHORIZED", "bearer token is required", ErrJWTMissing)
+			}
+			principal, err := verifier.verify(token)
+			if err != nil {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="invalid_token"`)
+				return ctx.Response(WarnLevel, "JWT", http.StatusUnauthorized, "INVALID_TOKEN", "bearer token is invalid", err)
+			}
+			c.Set(principalContextKey, principal)
+			return next(c)
+		}
+	}, nil
+}
+
+func bearerToken(req *http.Request) (string, bool) {
+	auth := req.Header.Get(echo.HeaderAuthorization)
+	if len(auth) < 7 || !strings.EqualFold(auth[:7], "bearer ") {
+		return "", false
+	}
+	token := strings.TrimSpace(auth[7:])
+	return token, token != ""
+}
+
+type jwtVerifier struct {
+	config JWTConfig
+	keys   *jwtKeySet
+}
+
+func (verifier *jwtVerifier) verify(tokenString string) (*Principal, error) {
+	// the claims are validated below with the leeway
+	parser := &jwt.Parser{
+		ValidMethods:         verifier.config.Algorithms,
+		SkipClaimsValidation: true,
+	}
+	claims := jwt.MapClaims{}
+	_, err := parser.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (any, error) {
+		kid, _ := token.Header["kid"].(string)
+		return verifier.keys.key(kid, token.Method.Alg()[:2])
+	})
+	if err != nil {
+		if validationErr, ok := err.(*jwt.ValidationError); ok && validationErr.Inner != nil {
+			return nil, validationErr.Inner
+		}
+		return nil, err
+	}
+
+	principal := newPrincipal(claims, verifier.config.ScopeClaim, verifier.config.RoleClaim)
+	now := time.Now()
+	leeway := verifier.config.Leeway
+	if principal.ExpiresAt.IsZero() {
+		return nil, ErrJWTExpired
+	}
+	if now.After(principal.ExpiresAt.Add(leeway)) {
+		return nil, ErrJWTExpired
+	}
+	if !principal.NotBefore.IsZero() && now.Add(leeway).Before(principal.NotBefore) {
+		return nil, ErrJWTNotYetValid
+	}
+	if !principal.IssuedAt.IsZero() && now.Add(leeway).Before(principal.IssuedAt) {
+		return nil, ErrJWTNotYetValid
+	}
+	if verifier.config.Issuer != "" && principal.Issuer != verifier.config.Issuer {
+		return nil, ErrJWTIssuerInvalid(principal.Issuer)
+	}
+	if len(verifier.config.Audience) > 0 && !containsAny(principal.Audience, verifier.config.Audience) {
+		return nil, ErrJWTAudienceInvalid
+	}
+	return principal, nil
+}
+
+func containsAny(values []string, expected []string) bool {
+	for _, value := range values {
+		for _, item := range expected {
+			if value == item {
+				return true
+			}
+		}
+	}
+	return false
+}
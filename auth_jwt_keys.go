@@ -0,0 +1,192 @@
+package ihttp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gitkeng/ihttp/util/cryptutil/rsautil"
+	"github.com/go-resty/resty/v2"
+)
+
+// LoadPublicKeyFile load the RSA or ECDSA public key of a PEM file,
+// the file hold a PKCS1 RSA PUBLIC KEY, a PKIX PUBLIC KEY or a CERTIFICATE
+func LoadPublicKeyFile(file string) (any, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, ErrJWTKeyInvalid(file)
+	}
+	var key any
+	switch block.Type {
+	case "RSA PUBLIC KEY":
+		key, err = rsautil.PublicKeyFrom(block.Bytes)
+	case "PUBLIC KEY":
+		key, err = x509.ParsePKIXPublicKey(block.Bytes)
+	case "CERTIFICATE":
+		var cert *x509.Certificate
+		if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+			key = cert.PublicKey
+		}
+	default:
+		return nil, ErrJWTKeyInvalid(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	switch key.(type) {
+	case *rsa.PublicKey, *ecdsa.PublicKey:
+		return key, nil
+	}
+	return nil, ErrJWTKeyInvalid(file)
+}
+
+// jwtKeySet hold the verification keys by key id, the keys of the JWKS document are refreshed
+// periodically and when a token name an unknown key id
+type jwtKeySet struct {
+	secret []byte
+	static map[string]any
+	issuer *TokenIssuer
+
+	jwksURL         string
+	refreshInterval time.Duration
+	client          *resty.Client
+
+	mutex     sync.RWMutex
+	jwks      map[string]any
+	fetchedAt time.Time
+}
+
+// key return the key of kid for the algorithm family: HS, RS or ES
+func (keys *jwtKeySet) key(kid string, family string) (any, error) {
+	if keys.issuer != nil {
+		if key, err := keys.issuer.key(kid, family); err == nil {
+			return key, nil
+		}
+	}
+	if family == "HS" {
+		if len(keys.secret) == 0 {
+			return nil, ErrJWTKeyNotfound(kid)
+		}
+		return keys.secret, nil
+	}
+	if key, found := keys.static[kid]; found {
+		return key, nil
+	}
+	if keys.jwksURL == "" {
+		return nil, ErrJWTKeyNotfound(kid)
+	}
+
+	keys.mutex.RLock()
+	key, found := keys.jwks[kid]
+	stale := time.Since(keys.fetchedAt) > keys.refreshInterval
+	// an unknown kid refetch the document at most once per DefaultJWKSMinRefreshInterval
+	refetch := stale || (!found && time.Since(keys.fetchedAt) > DefaultJWKSMinRefreshInterval)
+	keys.mutex.RUnlock()
+	if !refetch {
+		if !found {
+			return nil, ErrJWTKeyNotfound(kid)
+		}
+		return key, nil
+	}
+
+	if err := keys.refresh(); err != nil && !found {
+		return nil, err
+	}
+	keys.mutex.RLock()
+	defer keys.mutex.RUnlock()
+	if key, found = keys.jwks[kid]; !found {
+		return nil, ErrJWTKeyNotfound(kid)
+	}
+	return key, nil
+}
+
+func (keys *jwtKeySet) refresh() error {
+	keys.mutex.Lock()
+	defer keys.mutex.Unlock()
+	// another request refreshed the document while waiting for the lock
+	if time.Since(keys.fetchedAt) < DefaultJWKSMinRefreshInterval {
+		return nil
+	}
+	keys.fetchedAt = time.Now()
+	resp, err := keys.client.R().SetHeader("Accept", "application/json").Get(keys.jwksURL)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() != http.StatusOK {
+		return ErrJWKSFetch(keys.jwksURL, resp.StatusCode())
+	}
+	jwks, err := ParseJWKS(resp.Body())
+	if err != nil {
+		return err
+	}
+	keys.jwks = jwks
+	return nil
+}
+
+// ParseJWKS return the RSA and EC P-256 public keys of a JWKS document by key id,
+// the keys with other types or use other than sig are skipped
+func ParseJWKS(document []byte) (map[string]any, error) {
+	set := struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+			Crv string `json:"crv"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		} `json:"keys"`
+	}{}
+	if err := json.Unmarshal(document, &set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]any, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		switch jwk.Kty {
+		case "RSA":
+			n, errN := base64URLInt(jwk.N)
+			e, errE := base64URLInt(jwk.E)
+			if errN != nil || errE != nil || !e.IsInt64() {
+				return nil, ErrJWKSKeyInvalid(jwk.Kid)
+			}
+			keys[jwk.Kid] = &rsa.PublicKey{N: n, E: int(e.Int64())}
+		case "EC":
+			if jwk.Crv != "P-256" {
+				continue
+			}
+			x, errX := base64URLInt(jwk.X)
+			y, errY := base64URLInt(jwk.Y)
+			if errX != nil || errY != nil || !elliptic.P256().IsOnCurve(x, y) {
+				return nil, ErrJWKSKeyInvalid(jwk.Kid)
+			}
+			keys[jwk.Kid] = &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}
+		}
+	}
+	return keys, nil
+}
+
+func base64URLInt(value string) (*big.Int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, err
+	}
+	return new(big.Int).SetBytes(raw), nil
+}
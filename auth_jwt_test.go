@@ -0,0 +1,154 @@
+package ihttp_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gitkeng/ihttp"
+	"github.com/golang-jwt/jwt"
+)
+
+func TestJWTMiddleware(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rsaFile := filepath.Join(t.TempDir(), "rsa.pem")
+	rsaPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(&rsaKey.PublicKey)})
+	if err := os.WriteFile(rsaFile, rsaPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encode := func(raw []byte) string {
+			return base64.RawURLEncoding.EncodeToString(raw)
+		}
+		fmt.Fprintf(w, `{"keys":[{"kty":"EC","kid":"ec-1","use":"sig","crv":"P-256","x":"%s","y":"%s"}]}`,
+			encode(ecKey.X.FillBytes(make([]byte, 32))), encode(ecKey.Y.FillBytes(make([]byte, 32))))
+	}))
+	defer jwks.Close()
+
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer ms.Cleanup()
+
+	auth, err := ms.JWT(ihttp.JWTConfig{
+		Secret:         "s3cret",
+		PublicKeyFiles: map[string]string{"rsa-1": rsaFile},
+		JWKSURL:        jwks.URL,
+		Issuer:         "https://auth.example.com",
+		Audience:       []string{"orders"},
+		Leeway:         time.Minute,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ms.GET("/orders", func(ctx ihttp.IContext) error {
+		principal, found := ctx.Principal()
+		if !found {
+			return ctx.WebContext().NoContent(http.StatusInternalServerError)
+		}
+		return ctx.WebContext().String(http.StatusOK, principal.Subject)
+	}, auth, ms.RequireScopes("orders.read"))
+	ms.DELETE("/orders", func(ctx ihttp.IContext) error {
+		return ctx.WebContext().NoContent(http.StatusNoContent)
+	}, auth, ms.RequireRoles("admin"))
+	server := httptest.NewServer(ms.GetEngine())
+	defer server.Close()
+
+	sign := func(method jwt.SigningMethod, kid string, key any, claims jwt.MapClaims) string {
+		base := jwt.MapClaims{
+			"sub":   "somchai",
+			"iss":   "https://auth.example.com",
+			"aud":   []string{"orders"},
+			"scope": "orders.read orders.write",
+			"roles": []string{"staff"},
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}
+		for name, value := range claims {
+			base[name] = value
+		}
+		token := jwt.NewWithClaims(method, base)
+		if kid != "" {
+			token.Header["kid"] = kid
+		}
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signed
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		token  string
+		status int
+		code   string
+	}{
+		{"HS256", http.MethodGet, sign(jwt.SigningMethodHS256, "", []byte("s3cret"), nil), http.StatusOK, ""},
+		{"RS256 key file", http.MethodGet, sign(jwt.SigningMethodRS256, "rsa-1", rsaKey, nil), http.StatusOK, ""},
+		{"ES256 JWKS", http.MethodGet, sign(jwt.SigningMethodES256, "ec-1", ecKey, nil), http.StatusOK, ""},
+		{"expired within leeway", http.MethodGet, sign(jwt.SigningMethodHS256, "", []byte("s3cret"), jwt.MapClaims{"exp": time.Now().Add(-30 * time.Second).Unix()}), http.StatusOK, ""},
+		{"missing token", http.MethodGet, "", http.StatusUnauthorized, "UNAUTHORIZED"},
+		{"wrong secret", http.MethodGet, sign(jwt.SigningMethodHS256, "", []byte("other"), nil), http.StatusUnauthorized, "INVALID_TOKEN"},
+		{"unknown kid", http.MethodGet, sign(jwt.SigningMethodRS256, "rsa-2", rsaKey, nil), http.StatusUnauthorized, "INVALID_TOKEN"},
+		{"expired", http.MethodGet, sign(jwt.SigningMethodHS256, "", []byte("s3cret"), jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}), http.StatusUnauthorized, "INVALID_TOKEN"},
+		{"wrong issuer", http.MethodGet, sign(jwt.SigningMethodHS256, "", []byte("s3cret"), jwt.MapClaims{"iss": "https://evil.example.com"}), http.StatusUnauthorized, "INVALID_TOKEN"},
+		{"wrong audience", http.MethodGet, sign(jwt.SigningMethodHS256, "", []byte("s3cret"), jwt.MapClaims{"aud": "billing"}), http.StatusUnauthorized, "INVALID_TOKEN"},
+		{"missing scope", http.MethodGet, sign(jwt.SigningMethodHS256, "", []byte("s3cret"), jwt.MapClaims{"scope": "orders.write"}), http.StatusForbidden, "FORBIDDEN"},
+		{"missing role", http.MethodDelete, sign(jwt.SigningMethodHS256, "", []byte("s3cret"), nil), http.StatusForbidden, "FORBIDDEN"},
+		{"role", http.MethodDelete, sign(jwt.SigningMethodHS256, "", []byte("s3cret"), jwt.MapClaims{"roles": []string{"admin"}}), http.StatusNoContent, ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(tc.method, server.URL+"/orders", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tc.token)
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != tc.status {
+				t.Errorf("expect status %d, got %d", tc.status, resp.StatusCode)
+				return
+			}
+			if tc.code == "" {
+				return
+			}
+			envelope := &ihttp.Response{}
+			if err := json.NewDecoder(resp.Body).Decode(envelope); err != nil || envelope.Code != tc.code {
+				t.Errorf("expect envelope code %s, got %+v (%v)", tc.code, envelope, err)
+			}
+			if tc.status == http.StatusUnauthorized && !strings.HasPrefix(resp.Header.Get("WWW-Authenticate"), "Bearer") {
+				t.Errorf("expect WWW-Authenticate challenge, got %q", resp.Header.Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
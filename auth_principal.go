@@ -0,0 +1,153 @@
+package ihttp
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+const principalContextKey = "ihttp.principal"
+
+// Principal is the authenticated caller of the request
+type Principal struct {
+	Subject   string
+	Issuer    string
+	Audience  []string
+	Scopes    []string
+	Roles     []string
+	ID        string
+	ExpiresAt time.Time
+	NotBefore time.Time
+	IssuedAt  time.Time
+	// Claims is every claim of the token
+	Claims map[string]any
+}
+
+func newPrincipal(claims map[string]any, scopeClaim string, roleClaim string) *Principal {
+	principal := &Principal{
+		Subject:   claimString(claims, "sub"),
+		Issuer:    claimString(claims, "iss"),
+		Audience:  claimStrings(claims, "aud"),
+		Scopes:    claimStrings(claims, scopeClaim),
+		Roles:     claimStrings(claims, roleClaim),
+		ID:        claimString(claims, "jti"),
+		ExpiresAt: claimTime(claims, "exp"),
+		NotBefore: claimTime(claims, "nbf"),
+		IssuedAt:  claimTime(claims, "iat"),
+		Claims:    claims,
+	}
+	return principal
+}
+
+// HasScope report whether the principal has every scope
+func (principal *Principal) HasScope(scopes ...string) bool {
+	return containsAll(principal.Scopes, scopes)
+}
+
+// HasRole report whether the principal has one of the roles
+func (principal *Principal) HasRole(roles ...string) bool {
+	return containsAny(principal.Roles, roles)
+}
+
+// Claim return the claim of name
+func (principal *Principal) Claim(name string) (any, bool) {
+	value, found := principal.Claims[name]
+	return value, found
+}
+
+// Principal return the principal authenticated by the JWT middleware
+func (ctx *HTTPContext) Principal() (*Principal, bool) {
+	if ctx.ctx == nil {
+		return nil, false
+	}
+	return principalFrom(ctx.ctx)
+}
+
+func principalFrom(c echo.Context) (*Principal, bool) {
+	principal, ok := c.Get(principalContextKey).(*Principal)
+	return principal, ok && principal != nil
+}
+
+// RequireScopes return the middleware answering 403 when the principal does not have every scope,
+// it must be used after the JWT middleware
+func (ms *Microservice) RequireScopes(scopes ...string) echo.MiddlewareFunc {
+	return ms.requirePrincipal("scope", func(principal *Principal) bool {
+		return principal.HasScope(scopes...)
+	})
+}
+
+// RequireRoles return the middleware answering 403 when the principal does not have one of the roles,
+// it must be used after the JWT middleware
+func (ms *Microservice) RequireRoles(roles ...string) echo.MiddlewareFunc {
+	return ms.requirePrincipal("role", func(principal *Principal) bool {
+		return principal.HasRole(roles...)
+	})
+}
+
+func (ms *Microservice) requirePrincipal(requirement string, allowed func(principal *Principal) bool) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, found := principalFrom(c)
+			if !found {
+				ctx := NewHTTPContext(ms, c)
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer`)
+				return ctx.Response(WarnLevel, "JWT", http.StatusUnauthorized, "UNAUTHORIZED", "authentication is required", ErrJWTMissing)
+			}
+			if !allowed(principal) {
+				ctx := NewHTTPContext(ms, c)
+				if requirement == "scope" {
+					c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer error="insufficient_scope"`)
+				}
+				return ctx.Response(WarnLevel, "JWT", http.StatusForbidden, "FORBIDDEN", "insufficient "+requirement, ErrPrincipalForbidden(requirement))
+			}
+			return next(c)
+		}
+	}
+}
+
+func containsAll(values []string, expected []string) bool {
+	for _, item := range expected {
+		if !containsAny(values, []string{item}) {
+			return false
+		}
+	}
+	return true
+}
+
+func claimString(claims map[string]any, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// claimStrings read a space separated string or an array claim
+func claimStrings(claims map[string]any, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return strings.Fields(value)
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if text, ok := item.(string); ok {
+				values = append(values, text)
+			}
+		}
+		return values
+	case []string:
+		return value
+	}
+	return nil
+}
+
+func claimTime(claims map[string]any, name string) time.Time {
+	switch value := claims[name].(type) {
+	case float64:
+		return time.Unix(int64(value), 0)
+	case int64:
+		return time.Unix(value, 0)
+	case int:
+		return time.Unix(int64(value), 0)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,290 @@
+package ihttp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RefreshToken is the stored refresh token, the secret is kept as its hash only
+type RefreshToken struct {
+	ID       string
+	FamilyID string
+	Subject  string
+	Hash     string
+	// Claims is the claims of the access tokens issued with the token
+	Claims          map[string]any
+	FamilyCreatedAt time.Time
+	CreatedAt       time.Time
+	ExpiresAt       time.Time
+	// Revoked is true when the family or the subject is revoked
+	Revoked bool
+}
+
+// IRefreshTokenStore keep the refresh tokens of TokenIssuer
+type IRefreshTokenStore interface {
+	// Save store the new refresh token until its ExpiresAt
+	Save(ctx context.Context, token *RefreshToken) error
+	// Find return the refresh token of id, nil when not found
+	Find(ctx context.Context, id string) (*RefreshToken, error)
+	// Use mark the refresh token used, it return false when it was already used
+	Use(ctx context.Context, id string) (bool, error)
+	// RevokeFamily revoke every refresh token of the family
+	RevokeFamily(ctx context.Context, familyID string) error
+	// RevokeSubject revoke every refresh token issued to the subject until now
+	RevokeSubject(ctx context.Context, subject string) error
+}
+
+// RedisRefreshTokenStore keep the refresh tokens in redis under the key prefix
+type RedisRefreshTokenStore struct {
+	cache  IRedisCache
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisRefreshTokenStore return new RedisRefreshTokenStore, ttl is the RefreshTTL of the issuer,
+// it is the lifetime of the revocation marks
+func NewRedisRefreshTokenStore(cache IRedisCache, prefix string, ttl time.Duration) *RedisRefreshTokenStore {
+	if prefix == "" {
+		prefix = DefaultRefreshTokenKeyPrefix
+	}
+	if ttl <= 0 {
+		ttl = DefaultRefreshTokenTTL
+	}
+	return &RedisRefreshTokenStore{
+		cache:  cache,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+func (store *RedisRefreshTokenStore) key(kind string, value string) string {
+	return fmt.Sprintf("%s:%s:%s", store.prefix, kind, value)
+}
+
+func (store *RedisRefreshTokenStore) Save(ctx context.Context, token *RefreshToken) error {
+	raw, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+	return store.cache.SetS(store.key("token", token.ID), string(raw), time.Until(token.ExpiresAt))
+}
+
+func (store *RedisRefreshTokenStore) Find(ctx context.Context, id string) (*RefreshToken, error) {
+	raw, err := store.cache.Get(store.key("token", id))
+	if err != nil || raw == "" {
+		return nil, err
+	}
+	token := &RefreshToken{}
+	if err := json.Unmarshal([]byte(raw), token); err != nil {
+		return nil, err
+	}
+	if revoked, err := store.cache.Exists(store.key("family", token.FamilyID)); err != nil {
+		return nil, err
+	} else if revoked {
+		token.Revoked = true
+		return token, nil
+	}
+	revokedAt, err := store.cache.Get(store.key("subject", token.Subject))
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt != "" {
+		nanos, _ := strconv.ParseInt(revokedAt, 10, 64)
+		token.Revoked = !token.FamilyCreatedAt.After(time.Unix(0, nanos))
+	}
+	return token, nil
+}
+
+func (store *RedisRefreshTokenStore) Use(ctx context.Context, id string) (bool, error) {
+	key := store.key("used", id)
+	count, err := store.cache.Incr(key)
+	if err != nil {
+		return false, err
+	}
+	if count == 1 {
+		if err := store.cache.Expire(key, store.ttl); err != nil {
+			return false, err
+		}
+	}
+	return count == 1, nil
+}
+
+func (store *RedisRefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	return store.cache.SetS(store.key("family", familyID), "1", store.ttl)
+}
+
+func (store *RedisRefreshTokenStore) RevokeSubject(ctx context.Context, subject string) error {
+	return store.cache.SetS(store.key("subject", subject), strconv.FormatInt(time.Now().UnixNano(), 10), store.ttl)
+}
+
+// DBRefreshTokenStore keep the refresh tokens in a database table, see RefreshTokenSchema
+type DBRefreshTokenStore struct {
+	db    IDBStore
+	table string
+}
+
+// NewDBRefreshTokenStore return new DBRefreshTokenStore of the table, default DefaultRefreshTokenTable
+func NewDBRefreshTokenStore(db IDBStore, table string) *DBRefreshTokenStore {
+	if table == "" {
+		table = DefaultRefreshTokenTable
+	}
+	return &DBRefreshTokenStore{
+		db:    db,
+		table: table,
+	}
+}
+
+// RefreshTokenSchema return the statements creating the refresh token table for the database provider
+func RefreshTokenSchema(provider string, table string) ([]string, error) {
+	prefix := strings.ReplaceAll(table, ".", "_")
+	columns := map[string][2]string{
+		POSTGRES: {"VARCHAR(64)", "TIMESTAMP"},
+		MYSQL:    {"VARCHAR(64)", "DATETIME(6)"},
+		SQLITE:   {"TEXT", "TIMESTAMP"},
+	}
+	types, found := columns[provider]
+	if !found {
+		return nil, ErrInvalidDBProvider(provider)
+	}
+	statements := []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    id                %[2]s PRIMARY KEY,
+    family_id         %[2]s NOT NULL,
+    subject           VARCHAR(255) NOT NULL,
+    hash              TEXT NOT NULL,
+    claims            TEXT NOT NULL,
+    family_created_at %[3]s NOT NULL,
+    created_at        %[3]s NOT NULL,
+    expires_at        %[3]s NOT NULL,
+    used_at           %[3]s NULL,
+    revoked_at        %[3]s NULL
+)`, table, types[0], types[1]),
+	}
+	indexes := [][2]string{{prefix + "_family_idx", "family_id"}, {prefix + "_subject_idx", "subject"}}
+	for _, index := range indexes {
+		if provider == MYSQL {
+			statements = append(statements, fmt.Sprintf("CREATE INDEX %s ON %s (%s)", index[0], table, index[1]))
+			continue
+		}
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", index[0], table, index[1]))
+	}
+	return statements, nil
+}
+
+// CreateTable create the refresh token table when it does not exist
+func (store *DBRefreshTokenStore) CreateTable(ctx context.Context) error {
+	statements, err := RefreshTokenSchema(store.db.Provider(), store.table)
+	if err != nil {
+		return err
+	}
+	for idx, statement := range statements {
+		if _, err := store.db.ExecContext(ctx, statement); err != nil {
+			// mysql has no CREATE INDEX IF NOT EXISTS, the index of an existing table is kept
+			if idx > 0 && store.db.Provider() == MYSQL && strings.Contains(err.Error(), "Duplicate key name") {
+				continue
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+type refreshTokenRow struct {
+	ID              string       `db:"id"`
+	FamilyID        string       `db:"family_id"`
+	Subject         string       `db:"subject"`
+	Hash            string       `db:"hash"`
+	Claims          string       `db:"claims"`
+	FamilyCreatedAt time.Time    `db:"family_created_at"`
+	CreatedAt       time.Time    `db:"created_at"`
+	ExpiresAt       time.Time    `db:"expires_at"`
+	RevokedAt       sql.NullTime `db:"revoked_at"`
+}
+
+func (store *DBRefreshTokenStore) Save(ctx context.Context, token *RefreshToken) error {
+	claims, err := json.Marshal(token.Claims)
+	if err != nil {
+		return err
+	}
+	_, err = Exec(ctx, store.db, fmt.Sprintf(
+		`INSERT INTO %s (id, family_id, subject, hash, claims, family_created_at, created_at, expires_at)
+VALUES (:id, :family_id, :subject, :hash, :claims, :family_created_at, :created_at, :expires_at)`, store.table),
+		refreshTokenRow{
+			ID:              token.ID,
+			FamilyID:        token.FamilyID,
+			Subject:         token.Subject,
+			Hash:            token.Hash,
+			Claims:          string(claims),
+			FamilyCreatedAt: token.FamilyCreatedAt.UTC(),
+			CreatedAt:       token.CreatedAt.UTC(),
+			ExpiresAt:       token.ExpiresAt.UTC(),
+		})
+	return err
+}
+
+func (store *DBRefreshTokenStore) Find(ctx context.Context, id string) (*RefreshToken, error) {
+	row, err := QueryOne[refreshTokenRow](ctx, store.db, fmt.Sprintf(
+		"SELECT id, family_id, subject, hash, claims, family_created_at, created_at, expires_at, revoked_at FROM %s WHERE id = :id",
+		store.table), map[string]any{"id": id})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	token := &RefreshToken{
+		ID:              row.ID,
+		FamilyID:        row.FamilyID,
+		Subject:         row.Subject,
+		Hash:            row.Hash,
+		FamilyCreatedAt: row.FamilyCreatedAt,
+		CreatedAt:       row.CreatedAt,
+		ExpiresAt:       row.ExpiresAt,
+		Revoked:         row.RevokedAt.Valid,
+	}
+	if err := json.Unmarshal([]byte(row.Claims), &token.Claims); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (store *DBRefreshTokenStore) Use(ctx context.Context, id string) (bool, error) {
+	result, err := Exec(ctx, store.db, fmt.Sprintf(
+		"UPDATE %s SET used_at = :now WHERE id = :id AND used_at IS NULL", store.table),
+		map[string]any{"id": id, "now": time.Now().UTC()})
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (store *DBRefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	_, err := Exec(ctx, store.db, fmt.Sprintf(
+		"UPDATE %s SET revoked_at = :now WHERE family_id = :family_id AND revoked_at IS NULL", store.table),
+		map[string]any{"family_id": familyID, "now": time.Now().UTC()})
+	return err
+}
+
+func (store *DBRefreshTokenStore) RevokeSubject(ctx context.Context, subject string) error {
+	_, err := Exec(ctx, store.db, fmt.Sprintf(
+		"UPDATE %s SET revoked_at = :now WHERE subject = :subject AND revoked_at IS NULL", store.table),
+		map[string]any{"subject": subject, "now": time.Now().UTC()})
+	return err
+}
+
+// Purge delete the refresh tokens expired before now and return the number deleted
+func (store *DBRefreshTokenStore) Purge(ctx context.Context) (int64, error) {
+	result, err := Exec(ctx, store.db, fmt.Sprintf("DELETE FROM %s WHERE expires_at < :now", store.table),
+		map[string]any{"now": time.Now().UTC()})
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,358 @@
+package ihttp
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gitkeng/ihttp/util/cryptutil"
+	"github.com/gitkeng/ihttp/util/cryptutil/rsautil"
+	"github.com/gitkeng/ihttp/util/id"
+	"github.com/golang-jwt/jwt"
+)
+
+// SigningKey is a key of TokenIssuer, ID is the kid header of the tokens it sign
+type SigningKey struct {
+	ID string
+	// Algorithm is HS256, RS256 or ES256
+	Algorithm string
+	// Key is the []byte secret of HS256, the *rsa.PrivateKey of RS256 or the *ecdsa.PrivateKey of ES256
+	Key any
+}
+
+func (key SigningKey) method() (jwt.SigningMethod, error) {
+	switch key.Algorithm {
+	case "HS256":
+		if _, ok := key.Key.([]byte); ok {
+			return jwt.SigningMethodHS256, nil
+		}
+	case "RS256":
+		if _, ok := key.Key.(*rsa.PrivateKey); ok {
+			return jwt.SigningMethodRS256, nil
+		}
+	case "ES256":
+		if _, ok := key.Key.(*ecdsa.PrivateKey); ok {
+			return jwt.SigningMethodES256, nil
+		}
+	}
+	return nil, ErrSigningKeyInvalid(key.ID)
+}
+
+// verificationKey return the key verifying the tokens of the key
+func (key SigningKey) verificationKey() any {
+	switch private := key.Key.(type) {
+	case *rsa.PrivateKey:
+		return &private.PublicKey
+	case *ecdsa.PrivateKey:
+		return &private.PublicKey
+	}
+	return key.Key
+}
+
+// LoadPrivateKeyFile load the RSA or ECDSA private key of a PEM file,
+// the file hold a PKCS1 RSA PRIVATE KEY, an EC PRIVATE KEY or a PKCS8 PRIVATE KEY
+func LoadPrivateKeyFile(file string) (any, error) {
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, ErrJWTKeyInvalid(file)
+	}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		return x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		return x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		if key, err := rsautil.PrivateKeyFrom(block.Bytes); err == nil {
+			return key, nil
+		}
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		if key, ok := key.(*ecdsa.PrivateKey); ok {
+			return key, nil
+		}
+	}
+	return nil, ErrJWTKeyInvalid(file)
+}
+
+// TokenIssuerConfig is the config of TokenIssuer
+type TokenIssuerConfig struct {
+	// Issuer is the iss claim
+	Issuer string
+	// Audience is the aud claim
+	Audience []string
+	// AccessTTL is the lifetime of the access tokens, default DefaultAccessTokenTTL
+	AccessTTL time.Duration
+	// RefreshTTL is the lifetime of a refresh token family from its login, default DefaultRefreshTokenTTL,
+	// rotation does not extend it
+	RefreshTTL time.Duration
+	// Keys is the signing keys, the last one sign the new tokens
+	Keys []SigningKey
+	// Store keep the refresh tokens, see NewRedisRefreshTokenStore and NewDBRefreshTokenStore
+	Store IRefreshTokenStore
+}
+
+// TokenPair is the tokens returned by login and refresh
+type TokenPair struct {
+	AccessToken      string    `json:"access_token"`
+	TokenType        string    `json:"token_type"`
+	ExpiresIn        int64     `json:"expires_in"`
+	ExpiresAt        time.Time `json:"-"`
+	RefreshToken     string    `json:"refresh_token,omitempty"`
+	RefreshExpiresAt time.Time `json:"-"`
+}
+
+// TokenIssuer sign access tokens and rotate the opaque refresh tokens.
+//
+// A refresh token is "<id>.<secret>", the store keep the id and the bcrypt hash of the secret only.
+// Every refresh token can be used once, using it again revoke its whole family since either the
+// client or an attacker hold a stolen copy. The access tokens already issued stay valid until they expire.
+type TokenIssuer struct {
+	config TokenIssuerConfig
+
+	mutex  sync.RWMutex
+	keys   map[string]SigningKey
+	active string
+}
+
+// NewTokenIssuer return new TokenIssuer
+func NewTokenIssuer(cfg TokenIssuerConfig) (*TokenIssuer, error) {
+	if len(cfg.Keys) == 0 {
+		return nil, ErrJWTKeyIsRequire
+	}
+	if cfg.AccessTTL <= 0 {
+		cfg.AccessTTL = DefaultAccessTokenTTL
+	}
+	if cfg.RefreshTTL <= 0 {
+		cfg.RefreshTTL = DefaultRefreshTokenTTL
+	}
+	issuer := &TokenIssuer{
+		config: cfg,
+		keys:   make(map[string]SigningKey, len(cfg.Keys)),
+	}
+	for _, key := range cfg.Keys {
+		if err := issuer.RotateKey(key); err != nil {
+			return nil, err
+		}
+	}
+	return issuer, nil
+}
+
+// RotateKey add the key and sign the new tokens with it,
+// the previous keys still verify the tokens they signed until RetireKey
+func (issuer *TokenIssuer) RotateKey(key SigningKey) error {
+	if _, err := key.method(); err != nil {
+		return err
+	}
+	issuer.mutex.Lock()
+	defer issuer.mutex.Unlock()
+	issuer.keys[key.ID] = key
+	issuer.active = key.ID
+	return nil
+}
+
+// RetireKey remove the key, the tokens it signed are no longer valid
+func (issuer *TokenIssuer) RetireKey(kid string) error {
+	issuer.mutex.Lock()
+	defer issuer.mutex.Unlock()
+	if kid == issuer.active {
+		return ErrSigningKeyActive(kid)
+	}
+	delete(issuer.keys, kid)
+	return nil
+}
+
+// JWKS return the JWKS document of the RS256 and ES256 keys for the verifiers in other services
+func (issuer *TokenIssuer) JWKS() ([]byte, error) {
+	issuer.mutex.RLock()
+	defer issuer.mutex.RUnlock()
+	encode := func(raw []byte) string {
+		return base64.RawURLEncoding.EncodeToString(raw)
+	}
+	keys := make([]map[string]string, 0, len(issuer.keys))
+	for _, key := range issuer.keys {
+		switch public := key.verificationKey().(type) {
+		case *rsa.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "RSA", "kid": key.ID, "use": "sig", "alg": key.Algorithm,
+				"n": encode(public.N.Bytes()),
+				"e": encode(big.NewInt(int64(public.E)).Bytes()),
+			})
+		case *ecdsa.PublicKey:
+			size := (public.Curve.Params().BitSize + 7) / 8
+			keys = append(keys, map[string]string{
+				"kty": "EC", "kid": key.ID, "use": "sig", "alg": key.Algorithm, "crv": public.Curve.Params().Name,
+				"x": encode(public.X.FillBytes(make([]byte, size))),
+				"y": encode(public.Y.FillBytes(make([]byte, size))),
+			})
+		}
+	}
+	return json.Marshal(map[string]any{"keys": keys})
+}
+
+// key return the key of kid for the JWT middleware
+func (issuer *TokenIssuer) key(kid string, family string) (any, error) {
+	issuer.mutex.RLock()
+	defer issuer.mutex.RUnlock()
+	key, found := issuer.keys[kid]
+	if !found || key.Algorithm[:2] != family {
+		return nil, ErrJWTKeyNotfound(kid)
+	}
+	return key.verificationKey(), nil
+}
+
+// AccessToken sign an access token of the subject, claims are added to the registered claims
+func (issuer *TokenIssuer) AccessToken(subject string, claims map[string]any) (string, time.Time, error) {
+	issuer.mutex.RLock()
+	key := issuer.keys[issuer.active]
+	issuer.mutex.RUnlock()
+	method, err := key.method()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(issuer.config.AccessTTL)
+	mapClaims := jwt.MapClaims{}
+	for name, value := range claims {
+		mapClaims[name] = value
+	}
+	mapClaims["sub"] = subject
+	mapClaims["iat"] = now.Unix()
+	mapClaims["nbf"] = now.Unix()
+	mapClaims["exp"] = expiresAt.Unix()
+	mapClaims["jti"] = id.UUID()
+	if issuer.config.Issuer != "" {
+		mapClaims["iss"] = issuer.config.Issuer
+	}
+	if len(issuer.config.Audience) > 0 {
+		mapClaims["aud"] = issuer.config.Audience
+	}
+	token := jwt.NewWithClaims(method, mapClaims)
+	token.Header["kid"] = key.ID
+	signed, err := token.SignedString(key.Key)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return signed, expiresAt, nil
+}
+
+// Issue return the access token and a refresh token starting a new token family,
+// it is called after the login of the subject
+func (issuer *TokenIssuer) Issue(ctx context.Context, subject string, claims map[string]any) (*TokenPair, error) {
+	now := time.Now()
+	return issuer.issue(ctx, &RefreshToken{
+		FamilyID:        id.UUID(),
+		Subject:         subject,
+		Claims:          claims,
+		FamilyCreatedAt: now,
+		ExpiresAt:       now.Add(issuer.config.RefreshTTL),
+	})
+}
+
+func (issuer *TokenIssuer) issue(ctx context.Context, refresh *RefreshToken) (*TokenPair, error) {
+	if issuer.config.Store == nil {
+		return nil, ErrRefreshTokenStoreIsRequire
+	}
+	accessToken, expiresAt, err := issuer.AccessToken(refresh.Subject, refresh.Claims)
+	if err != nil {
+		return nil, err
+	}
+	hashToken, err := cryptutil.IssueHashToken()
+	if err != nil {
+		return nil, err
+	}
+	refresh.ID = id.UUID()
+	refresh.Hash = hashToken.Hash
+	refresh.CreatedAt = time.Now()
+	if err := issuer.config.Store.Save(ctx, refresh); err != nil {
+		return nil, err
+	}
+	return &TokenPair{
+		AccessToken:      accessToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        int64(time.Until(expiresAt).Seconds()),
+		ExpiresAt:        expiresAt,
+		RefreshToken:     refresh.ID + "." + hashToken.Token,
+		RefreshExpiresAt: refresh.ExpiresAt,
+	}, nil
+}
+
+// Refresh rotate the refresh token and return new tokens, a refresh token used twice revoke
+// its family and return an error matching ErrRefreshTokenReused
+func (issuer *TokenIssuer) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	refresh, err := issuer.find(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+	first, err := issuer.config.Store.Use(ctx, refresh.ID)
+	if err != nil {
+		return nil, err
+	}
+	if !first {
+		if err := issuer.config.Store.RevokeFamily(ctx, refresh.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReusedBy(refresh.Subject)
+	}
+	return issuer.issue(ctx, &RefreshToken{
+		FamilyID:        refresh.FamilyID,
+		Subject:         refresh.Subject,
+		Claims:          refresh.Claims,
+		FamilyCreatedAt: refresh.FamilyCreatedAt,
+		ExpiresAt:       refresh.ExpiresAt,
+	})
+}
+
+// Revoke revoke the family of the refresh token, it is the logout of one session
+func (issuer *TokenIssuer) Revoke(ctx context.Context, refreshToken string) error {
+	refresh, err := issuer.find(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+	return issuer.config.Store.RevokeFamily(ctx, refresh.FamilyID)
+}
+
+// RevokeSubject revoke every refresh token of the subject, it is the logout everywhere
+func (issuer *TokenIssuer) RevokeSubject(ctx context.Context, subject string) error {
+	if issuer.config.Store == nil {
+		return ErrRefreshTokenStoreIsRequire
+	}
+	return issuer.config.Store.RevokeSubject(ctx, subject)
+}
+
+// find return the valid refresh token, the used tokens are returned for reuse detection
+func (issuer *TokenIssuer) find(ctx context.Context, refreshToken string) (*RefreshToken, error) {
+	if issuer.config.Store == nil {
+		return nil, ErrRefreshTokenStoreIsRequire
+	}
+	tokenID, secret, found := strings.Cut(refreshToken, ".")
+	if !found || tokenID == "" || secret == "" {
+		return nil, ErrRefreshTokenInvalid
+	}
+	refresh, err := issuer.config.Store.Find(ctx, tokenID)
+	if err != nil {
+		return nil, err
+	}
+	if refresh == nil || !cryptutil.ValidHashToken(&cryptutil.HashToken{Token: secret, Hash: refresh.Hash}) {
+		return nil, ErrRefreshTokenInvalid
+	}
+	if refresh.Revoked || time.Now().After(refresh.ExpiresAt) {
+		return nil, ErrRefreshTokenInvalid
+	}
+	return refresh, nil
+}
@@ -0,0 +1,182 @@
+package ihttp_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gitkeng/ihttp"
+)
+
+func TestTokenIssuerRefreshRotation(t *testing.T) {
+	dbCfg := &ihttp.DBConfig{
+		ContextName:  "local",
+		Provider:     ihttp.SQLITE,
+		DatabaseName: ":memory:",
+	}
+	if err := dbCfg.Bind(); err != nil {
+		t.Error(err)
+		return
+	}
+	db, err := ihttp.NewDBStore(dbCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.Close()
+	dbStore := ihttp.NewDBRefreshTokenStore(db, "")
+	if err := dbStore.CreateTable(context.Background()); err != nil {
+		t.Error(err)
+		return
+	}
+
+	stores := map[string]ihttp.IRefreshTokenStore{
+		"redis": ihttp.NewRedisRefreshTokenStore(newMemoryCache(), "", 0),
+		"db":    dbStore,
+	}
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			issuer, err := ihttp.NewTokenIssuer(ihttp.TokenIssuerConfig{
+				Issuer: "https://auth.example.com",
+				Keys:   []ihttp.SigningKey{{ID: "hs-1", Algorithm: "HS256", Key: []byte("s3cret")}},
+				Store:  store,
+			})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			login, err := issuer.Issue(ctx, "somchai", map[string]any{"roles": []string{"staff"}})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			rotated, err := issuer.Refresh(ctx, login.RefreshToken)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if rotated.RefreshToken == login.RefreshToken || rotated.AccessToken == "" {
+				t.Errorf("expect new tokens, got %+v", rotated)
+			}
+
+			// the stolen first token is replayed, the whole family including the rotated token is revoked
+			if _, err := issuer.Refresh(ctx, login.RefreshToken); !errors.Is(err, ihttp.ErrRefreshTokenReused) {
+				t.Errorf("expect reuse detected, got %v", err)
+			}
+			if _, err := issuer.Refresh(ctx, rotated.RefreshToken); !errors.Is(err, ihttp.ErrRefreshTokenInvalid) {
+				t.Errorf("expect family revoked, got %v", err)
+			}
+			if _, err := issuer.Refresh(ctx, login.RefreshToken[:len(login.RefreshToken)-4]+"AAA="); !errors.Is(err, ihttp.ErrRefreshTokenInvalid) {
+				t.Errorf("expect tampered token rejected, got %v", err)
+			}
+
+			// logout everywhere revoke the sessions of the subject only
+			phone, _ := issuer.Issue(ctx, "somchai", nil)
+			laptop, _ := issuer.Issue(ctx, "somchai", nil)
+			other, _ := issuer.Issue(ctx, "somsri", nil)
+			if err := issuer.RevokeSubject(ctx, "somchai"); err != nil {
+				t.Error(err)
+				return
+			}
+			for _, pair := range []*ihttp.TokenPair{phone, laptop} {
+				if _, err := issuer.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ihttp.ErrRefreshTokenInvalid) {
+					t.Errorf("expect revoked session, got %v", err)
+				}
+			}
+			if _, err := issuer.Refresh(ctx, other.RefreshToken); err != nil {
+				t.Errorf("expect other subject unaffected, got %v", err)
+			}
+			relogin, _ := issuer.Issue(ctx, "somchai", nil)
+			if _, err := issuer.Refresh(ctx, relogin.RefreshToken); err != nil {
+				t.Errorf("expect login after logout everywhere valid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTokenIssuerKeyRotation(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer, err := ihttp.NewTokenIssuer(ihttp.TokenIssuerConfig{
+		Keys:  []ihttp.SigningKey{{ID: "hs-1", Algorithm: "HS256", Key: []byte("s3cret")}},
+		Store: ihttp.NewRedisRefreshTokenStore(newMemoryCache(), "", 0),
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err := issuer.RotateKey(ihttp.SigningKey{ID: "es-1", Algorithm: "RS256", Key: ecKey}); err == nil {
+		t.Error("expect key not matching its algorithm rejected")
+	}
+
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer ms.Cleanup()
+	auth, err := ms.JWT(ihttp.JWTConfig{TokenIssuer: issuer})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ms.GET("/me", func(ctx ihttp.IContext) error {
+		principal, _ := ctx.Principal()
+		return ctx.WebContext().String(http.StatusOK, principal.Subject)
+	}, auth)
+	server := httptest.NewServer(ms.GetEngine())
+	defer server.Close()
+
+	status := func(token string) int {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/me", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	oldToken, _, err := issuer.AccessToken("somchai", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err := issuer.RotateKey(ihttp.SigningKey{ID: "es-1", Algorithm: "ES256", Key: ecKey}); err != nil {
+		t.Error(err)
+		return
+	}
+	newToken, _, _ := issuer.AccessToken("somchai", nil)
+	if status(oldToken) != http.StatusOK || status(newToken) != http.StatusOK {
+		t.Error("expect tokens of the previous and the active key valid")
+	}
+	if err := issuer.RetireKey("es-1"); err == nil {
+		t.Error("expect active key cannot be retired")
+	}
+	if err := issuer.RetireKey("hs-1"); err != nil {
+		t.Error(err)
+	}
+	if status(oldToken) != http.StatusUnauthorized || status(newToken) != http.StatusOK {
+		t.Error("expect tokens of the retired key rejected")
+	}
+
+	jwks, err := issuer.JWKS()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	keys, err := ihttp.ParseJWKS(jwks)
+	if err != nil || keys["es-1"] == nil || len(keys) != 1 {
+		t.Errorf("expect JWKS publish the ES256 key only, got %s", jwks)
+	}
+}
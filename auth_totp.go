@@ -0,0 +1,321 @@
+package ihttp
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/base32"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gitkeng/ihttp/util/cryptutil"
+	"github.com/gitkeng/ihttp/util/cryptutil/google2fa"
+	"github.com/skip2/go-qrcode"
+)
+
+// TOTPConfig is the config of TOTPService
+type TOTPConfig struct {
+	// Issuer is the account issuer shown by the authenticator apps
+	Issuer string
+	// EncryptionKey encrypt the secrets in the store and key the recovery code hashes
+	EncryptionKey string
+	// Store keep the enrollments, see NewRedisTOTPStore and NewDBTOTPStore
+	Store ITOTPStore
+	// Cache keep the used time steps and the failed attempts
+	Cache IRedisCache
+	// KeyPrefix is the redis key prefix of the used time steps and the attempts, default DefaultTOTPKeyPrefix
+	KeyPrefix string
+	// WindowSize is the number of 30 seconds time steps accepted around now, default DefaultTOTPWindowSize
+	WindowSize int
+	// RecoveryCodes is the number of recovery codes, default DefaultTOTPRecoveryCodes
+	RecoveryCodes int
+	// MaxAttempts is the failed attempts of a user allowed within AttemptWindow,
+	// default DefaultTOTPMaxAttempts and DefaultTOTPAttemptWindow
+	MaxAttempts   int
+	AttemptWindow time.Duration
+	// QRCodeSize is the width and height of the QR code PNG, default DefaultTOTPQRCodeSize
+	QRCodeSize int
+}
+
+// TOTPEnrollment is the stored enrollment of a user, Secret is encrypted
+// and RecoveryCodes are the hashes of the unused recovery codes
+type TOTPEnrollment struct {
+	UserID        string     `json:"user_id"`
+	Secret        string     `json:"secret"`
+	RecoveryCodes []string   `json:"recovery_codes"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ConfirmedAt   *time.Time `json:"confirmed_at,omitempty"`
+}
+
+// Confirmed report whether the user confirmed the enrollment with a code
+func (enrollment *TOTPEnrollment) Confirmed() bool {
+	return enrollment.ConfirmedAt != nil
+}
+
+// TOTPSetup is the secret shown to the user while enrolling
+type TOTPSetup struct {
+	// Secret is the base32 secret for manual entry
+	Secret string `json:"secret"`
+	// URI is the otpauth provisioning URI
+	URI string `json:"uri"`
+	// QRCode is the PNG of the URI
+	QRCode []byte `json:"-"`
+}
+
+// TOTPService enroll the users in TOTP two-factor authentication and verify their codes.
+//
+// Enroll create an unconfirmed enrollment, Confirm activate it with the first code and return the
+// recovery codes once. Verify accept a TOTP code or a recovery code, a time step is accepted once per
+// user and a user is locked out for AttemptWindow after MaxAttempts failures.
+type TOTPService struct {
+	config TOTPConfig
+}
+
+// NewTOTPService return new TOTPService
+func NewTOTPService(cfg TOTPConfig) (*TOTPService, error) {
+	if cfg.Store == nil {
+		return nil, ErrTOTPStoreIsRequire
+	}
+	if cfg.Cache == nil {
+		return nil, ErrTOTPCacheIsRequire
+	}
+	if cfg.EncryptionKey == "" {
+		return nil, ErrTOTPEncryptionKeyIsRequire
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = DefaultTOTPKeyPrefix
+	}
+	if cfg.WindowSize <= 0 {
+		cfg.WindowSize = DefaultTOTPWindowSize
+	}
+	if cfg.RecoveryCodes <= 0 {
+		cfg.RecoveryCodes = DefaultTOTPRecoveryCodes
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultTOTPMaxAttempts
+	}
+	if cfg.AttemptWindow <= 0 {
+		cfg.AttemptWindow = DefaultTOTPAttemptWindow
+	}
+	if cfg.QRCodeSize <= 0 {
+		cfg.QRCodeSize = DefaultTOTPQRCodeSize
+	}
+	return &TOTPService{config: cfg}, nil
+}
+
+// Enroll generate a new secret of the user, account is the label shown by the authenticator apps.
+// An unconfirmed enrollment is replaced, a confirmed one must be disabled first.
+func (service *TOTPService) Enroll(ctx context.Context, userID string, account string) (*TOTPSetup, error) {
+	enrollment, err := service.config.Store.Find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment != nil && enrollment.Confirmed() {
+		return nil, ErrTOTPAlreadyEnrolled(userID)
+	}
+
+	raw := make([]byte, DefaultTOTPSecretSize)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+	secret := base32.StdEncoding.EncodeToString(raw)
+	encrypted, err := cryptutil.EncryptString(secret, service.config.EncryptionKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := service.config.Store.Save(ctx, &TOTPEnrollment{
+		UserID:    userID,
+		Secret:    encrypted,
+		CreatedAt: time.Now().UTC(),
+	}); err != nil {
+		return nil, err
+	}
+
+	otp := &google2fa.OTPConfig{Secret: secret}
+	uri := otp.ProvisionURIWithIssuer(url.PathEscape(account), service.config.Issuer)
+	png, err := qrcode.Encode(uri, qrcode.Medium, service.config.QRCodeSize)
+	if err != nil {
+		return nil, err
+	}
+	return &TOTPSetup{
+		Secret: secret,
+		URI:    uri,
+		QRCode: png,
+	}, nil
+}
+
+// Confirm activate the enrollment with the first code of the user and return the recovery codes,
+// they are stored hashed and cannot be read again
+func (service *TOTPService) Confirm(ctx context.Context, userID string, code string) ([]string, error) {
+	enrollment, err := service.enrollment(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment.Confirmed() {
+		return nil, ErrTOTPAlreadyEnrolled(userID)
+	}
+	if err := service.attempt(userID, func() (bool, error) {
+		return service.checkCode(enrollment, code)
+	}); err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	enrollment.ConfirmedAt = &now
+	return service.issueRecoveryCodes(ctx, enrollment)
+}
+
+// Verify check the TOTP code or recovery code of the confirmed enrollment, a recovery code is consumed
+func (service *TOTPService) Verify(ctx context.Context, userID string, code string) error {
+	enrollment, err := service.enrollment(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !enrollment.Confirmed() {
+		return ErrTOTPNotEnrolled(userID)
+	}
+	return service.attempt(userID, func() (bool, error) {
+		if len(code) == 6 {
+			return service.checkCode(enrollment, code)
+		}
+		return service.useRecoveryCode(ctx, enrollment, code)
+	})
+}
+
+// RegenerateRecoveryCodes replace the recovery codes of the confirmed enrollment
+func (service *TOTPService) RegenerateRecoveryCodes(ctx context.Context, userID string) ([]string, error) {
+	enrollment, err := service.enrollment(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !enrollment.Confirmed() {
+		return nil, ErrTOTPNotEnrolled(userID)
+	}
+	return service.issueRecoveryCodes(ctx, enrollment)
+}
+
+// Disable delete the enrollment of the user
+func (service *TOTPService) Disable(ctx context.Context, userID string) error {
+	return service.config.Store.Delete(ctx, userID)
+}
+
+func (service *TOTPService) enrollment(ctx context.Context, userID string) (*TOTPEnrollment, error) {
+	enrollment, err := service.config.Store.Find(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if enrollment == nil {
+		return nil, ErrTOTPNotEnrolled(userID)
+	}
+	return enrollment, nil
+}
+
+// attempt run check unless the user is locked out, every attempt is counted before the check
+// so concurrent attempts cannot pass the limit, and a success reset the count
+func (service *TOTPService) attempt(userID string, check func() (bool, error)) error {
+	key := fmt.Sprintf("%s:attempts:%s", service.config.KeyPrefix, userID)
+	count, err := service.config.Cache.Incr(key)
+	if err != nil {
+		return err
+	}
+	if count == 1 {
+		if err := service.config.Cache.Expire(key, service.config.AttemptWindow); err != nil {
+			return err
+		}
+	}
+	if count > service.config.MaxAttempts {
+		return ErrTOTPTooManyAttempts(userID)
+	}
+	valid, err := check()
+	if err != nil {
+		return err
+	}
+	if valid {
+		return service.config.Cache.Del(key)
+	}
+	return ErrTOTPCodeInvalid
+}
+
+// checkCode report whether the code match a time step in the window that the user did not use yet
+func (service *TOTPService) checkCode(enrollment *TOTPEnrollment, code string) (bool, error) {
+	value, err := strconv.Atoi(code)
+	if err != nil || len(code) != 6 {
+		return false, nil
+	}
+	secret, err := cryptutil.DecryptString(enrollment.Secret, service.config.EncryptionKey)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now().Unix() / 30
+	for step := now - int64(service.config.WindowSize/2); step <= now+int64(service.config.WindowSize/2); step++ {
+		if google2fa.ComputeCode(secret, step) != value {
+			continue
+		}
+		key := fmt.Sprintf("%s:used:%s:%d", service.config.KeyPrefix, enrollment.UserID, step)
+		used, err := service.config.Cache.Incr(key)
+		if err != nil {
+			return false, err
+		}
+		if used > 1 {
+			return false, nil
+		}
+		// the step is accepted until it leaves the window
+		return true, service.config.Cache.Expire(key, time.Duration(service.config.WindowSize+1)*30*time.Second)
+	}
+	return false, nil
+}
+
+// useRecoveryCode consume the recovery code with an Incr marker so concurrent requests cannot use it twice,
+// the marker does not expire because a save of a stale enrollment can bring the code back in the store
+func (service *TOTPService) useRecoveryCode(ctx context.Context, enrollment *TOTPEnrollment, code string) (bool, error) {
+	hash, err := cryptutil.HashSHA256(normalizeRecoveryCode(code), service.config.EncryptionKey)
+	if err != nil {
+		return false, err
+	}
+	for idx, stored := range enrollment.RecoveryCodes {
+		if !hmac.Equal([]byte(hash), []byte(stored)) {
+			continue
+		}
+		key := fmt.Sprintf("%s:recovery:%s:%s", service.config.KeyPrefix, enrollment.UserID, hash)
+		used, err := service.config.Cache.Incr(key)
+		if err != nil {
+			return false, err
+		}
+		if used > 1 {
+			return false, nil
+		}
+		enrollment.RecoveryCodes = append(enrollment.RecoveryCodes[:idx], enrollment.RecoveryCodes[idx+1:]...)
+		return true, service.config.Store.Save(ctx, enrollment)
+	}
+	return false, nil
+}
+
+func (service *TOTPService) issueRecoveryCodes(ctx context.Context, enrollment *TOTPEnrollment) ([]string, error) {
+	codes := make([]string, 0, service.config.RecoveryCodes)
+	hashes := make([]string, 0, service.config.RecoveryCodes)
+	for len(codes) < service.config.RecoveryCodes {
+		raw := make([]byte, 5)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		// 8 base32 characters are 40 random bits, shown as xxxx-xxxx
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(raw))
+		hash, err := cryptutil.HashSHA256(code, service.config.EncryptionKey)
+		if err != nil {
+			return nil, err
+		}
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, hash)
+	}
+	enrollment.RecoveryCodes = hashes
+	if err := service.config.Store.Save(ctx, enrollment); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
@@ -0,0 +1,184 @@
+package ihttp
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ITOTPStore keep the TOTP enrollments of TOTPService
+type ITOTPStore interface {
+	// Save insert or replace the enrollment of the user
+	Save(ctx context.Context, enrollment *TOTPEnrollment) error
+	// Find return the enrollment of the user, nil when not found
+	Find(ctx context.Context, userID string) (*TOTPEnrollment, error)
+	// Delete remove the enrollment of the user
+	Delete(ctx context.Context, userID string) error
+}
+
+// RedisTOTPStore keep the TOTP enrollments as the fields of a redis hash
+type RedisTOTPStore struct {
+	cache IRedisCache
+	key   string
+}
+
+// NewRedisTOTPStore return new RedisTOTPStore of the hash key, default DefaultTOTPHashKey
+func NewRedisTOTPStore(cache IRedisCache, key string) *RedisTOTPStore {
+	if key == "" {
+		key = DefaultTOTPHashKey
+	}
+	return &RedisTOTPStore{
+		cache: cache,
+		key:   key,
+	}
+}
+
+func (store *RedisTOTPStore) Save(ctx context.Context, enrollment *TOTPEnrollment) error {
+	raw, err := json.Marshal(enrollment)
+	if err != nil {
+		return err
+	}
+	return store.cache.HSetSNoExpire(store.key, enrollment.UserID, string(raw))
+}
+
+func (store *RedisTOTPStore) Find(ctx context.Context, userID string) (*TOTPEnrollment, error) {
+	raw, err := store.cache.HGet(store.key, userID)
+	if err != nil || raw == "" {
+		return nil, err
+	}
+	enrollment := &TOTPEnrollment{}
+	if err := json.Unmarshal([]byte(raw), enrollment); err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+func (store *RedisTOTPStore) Delete(ctx context.Context, userID string) error {
+	return store.cache.HDel(store.key, userID)
+}
+
+// DBTOTPStore keep the TOTP enrollments in a database table, see TOTPSchema
+type DBTOTPStore struct {
+	db    IDBStore
+	table string
+}
+
+// NewDBTOTPStore return new DBTOTPStore of the table, default DefaultTOTPTable
+func NewDBTOTPStore(db IDBStore, table string) *DBTOTPStore {
+	if table == "" {
+		table = DefaultTOTPTable
+	}
+	return &DBTOTPStore{
+		db:    db,
+		table: table,
+	}
+}
+
+// TOTPSchema return the statements creating the TOTP enrollment table for the database provider
+func TOTPSchema(provider string, table string) ([]string, error) {
+	columns := map[string][2]string{
+		POSTGRES: {"VARCHAR(255)", "TIMESTAMP"},
+		MYSQL:    {"VARCHAR(255)", "DATETIME(6)"},
+		SQLITE:   {"TEXT", "TIMESTAMP"},
+	}
+	types, found := columns[provider]
+	if !found {
+		return nil, ErrInvalidDBProvider(provider)
+	}
+	return []string{
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    user_id        %[2]s PRIMARY KEY,
+    secret         TEXT NOT NULL,
+    recovery_codes TEXT NOT NULL,
+    created_at     %[3]s NOT NULL,
+    confirmed_at   %[3]s NULL
+)`, table, types[0], types[1]),
+	}, nil
+}
+
+// CreateTable create the TOTP enrollment table when it does not exist
+func (store *DBTOTPStore) CreateTable(ctx context.Context) error {
+	statements, err := TOTPSchema(store.db.Provider(), store.table)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err := store.db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type totpRow struct {
+	UserID        string       `db:"user_id"`
+	Secret        string       `db:"secret"`
+	RecoveryCodes string       `db:"recovery_codes"`
+	CreatedAt     time.Time    `db:"created_at"`
+	ConfirmedAt   sql.NullTime `db:"confirmed_at"`
+}
+
+// Save delete and insert the enrollment in a transaction, it is portable across the providers
+func (store *DBTOTPStore) Save(ctx context.Context, enrollment *TOTPEnrollment) error {
+	codes, err := json.Marshal(enrollment.RecoveryCodes)
+	if err != nil {
+		return err
+	}
+	row := totpRow{
+		UserID:        enrollment.UserID,
+		Secret:        enrollment.Secret,
+		RecoveryCodes: string(codes),
+		CreatedAt:     enrollment.CreatedAt.UTC(),
+	}
+	if enrollment.ConfirmedAt != nil {
+		row.ConfirmedAt = sql.NullTime{Time: enrollment.ConfirmedAt.UTC(), Valid: true}
+	}
+
+	tx, err := store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if _, err := Exec(ctx, tx, fmt.Sprintf("DELETE FROM %s WHERE user_id = :user_id", store.table), row); err != nil {
+		return err
+	}
+	if _, err := Exec(ctx, tx, fmt.Sprintf(
+		`INSERT INTO %s (user_id, secret, recovery_codes, created_at, confirmed_at)
+VALUES (:user_id, :secret, :recovery_codes, :created_at, :confirmed_at)`, store.table), row); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (store *DBTOTPStore) Find(ctx context.Context, userID string) (*TOTPEnrollment, error) {
+	row, err := QueryOne[totpRow](ctx, store.db, fmt.Sprintf(
+		"SELECT user_id, secret, recovery_codes, created_at, confirmed_at FROM %s WHERE user_id = :user_id",
+		store.table), map[string]any{"user_id": userID})
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	enrollment := &TOTPEnrollment{
+		UserID:    row.UserID,
+		Secret:    row.Secret,
+		CreatedAt: row.CreatedAt,
+	}
+	if row.ConfirmedAt.Valid {
+		enrollment.ConfirmedAt = &row.ConfirmedAt.Time
+	}
+	if err := json.Unmarshal([]byte(row.RecoveryCodes), &enrollment.RecoveryCodes); err != nil {
+		return nil, err
+	}
+	return enrollment, nil
+}
+
+func (store *DBTOTPStore) Delete(ctx context.Context, userID string) error {
+	_, err := Exec(ctx, store.db, fmt.Sprintf("DELETE FROM %s WHERE user_id = :user_id", store.table),
+		map[string]any{"user_id": userID})
+	return err
+}
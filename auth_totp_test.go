@@ -0,0 +1,140 @@
+package ihttp_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gitkeng/ihttp"
+	"github.com/gitkeng/ihttp/util/cryptutil/google2fa"
+)
+
+func TestTOTPEnrollment(t *testing.T) {
+	ctx := context.Background()
+	store := ihttp.NewRedisTOTPStore(newMemoryCache(), "")
+	service, err := ihttp.NewTOTPService(ihttp.TOTPConfig{
+		Issuer:        "ihttp",
+		EncryptionKey: "k3y",
+		Store:         store,
+		Cache:         newMemoryCache(),
+		MaxAttempts:   3,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	setup, err := service.Enroll(ctx, "u1", "somchai@example.com")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !bytes.HasPrefix(setup.QRCode, []byte("\x89PNG")) {
+		t.Error("expect QR code PNG")
+	}
+	if setup.URI != fmt.Sprintf("otpauth://totp/ihttp:somchai@example.com?issuer=ihttp&secret=%s", setup.Secret) {
+		t.Errorf("unexpected provisioning uri %s", setup.URI)
+	}
+	stored, _ := store.Find(ctx, "u1")
+	if stored == nil || stored.Secret == setup.Secret {
+		t.Errorf("expect secret stored encrypted, got %+v", stored)
+	}
+	if err := service.Verify(ctx, "u1", "000000"); err == nil {
+		t.Error("expect unconfirmed enrollment rejected")
+	}
+
+	code := func(offset int64) string {
+		return fmt.Sprintf("%06d", google2fa.ComputeCode(setup.Secret, time.Now().Unix()/30+offset))
+	}
+	recoveryCodes, err := service.Confirm(ctx, "u1", code(0))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(recoveryCodes) != ihttp.DefaultTOTPRecoveryCodes {
+		t.Errorf("expect %d recovery codes, got %v", ihttp.DefaultTOTPRecoveryCodes, recoveryCodes)
+	}
+	if _, err := service.Enroll(ctx, "u1", "somchai@example.com"); err == nil {
+		t.Error("expect confirmed enrollment not replaced")
+	}
+
+	// the step used by Confirm cannot be replayed, the previous step is still in the window
+	if err := service.Verify(ctx, "u1", code(0)); !errors.Is(err, ihttp.ErrTOTPCodeInvalid) {
+		t.Errorf("expect replayed code rejected, got %v", err)
+	}
+	if err := service.Verify(ctx, "u1", code(-1)); err != nil {
+		t.Errorf("expect code of the previous step accepted, got %v", err)
+	}
+
+	stale, _ := store.Find(ctx, "u1")
+	if err := service.Verify(ctx, "u1", recoveryCodes[0]); err != nil {
+		t.Errorf("expect recovery code accepted, got %v", err)
+	}
+	if err := service.Verify(ctx, "u1", recoveryCodes[0]); !errors.Is(err, ihttp.ErrTOTPCodeInvalid) {
+		t.Errorf("expect used recovery code rejected, got %v", err)
+	}
+	// a concurrent request saving the enrollment it read before the code was used bring the code back
+	if err := store.Save(ctx, stale); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := service.Verify(ctx, "u1", recoveryCodes[0]); !errors.Is(err, ihttp.ErrTOTPCodeInvalid) {
+		t.Errorf("expect recovery code of stale enrollment rejected, got %v", err)
+	}
+
+	// the 2 failures above count, 1 more lock the user out even with a valid recovery code
+	service.Verify(ctx, "u1", "123")
+	if err := service.Verify(ctx, "u1", recoveryCodes[1]); err == nil || errors.Is(err, ihttp.ErrTOTPCodeInvalid) {
+		t.Errorf("expect too many attempts, got %v", err)
+	}
+}
+
+func TestTOTPConcurrentAttempts(t *testing.T) {
+	ctx := context.Background()
+	service, err := ihttp.NewTOTPService(ihttp.TOTPConfig{
+		Issuer:        "ihttp",
+		EncryptionKey: "k3y",
+		Store:         ihttp.NewRedisTOTPStore(newMemoryCache(), ""),
+		Cache:         newMemoryCache(),
+		MaxAttempts:   3,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	setup, err := service.Enroll(ctx, "u1", "somchai@example.com")
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := service.Confirm(ctx, "u1", fmt.Sprintf("%06d", google2fa.ComputeCode(setup.Secret, time.Now().Unix()/30))); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// every attempt is counted before the check, only MaxAttempts of them reach it
+	var wg sync.WaitGroup
+	errs := make(chan error, 10)
+	for idx := 0; idx < cap(errs); idx++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- service.Verify(ctx, "u1", "aaaa-bbbb")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	checked := 0
+	for err := range errs {
+		if errors.Is(err, ihttp.ErrTOTPCodeInvalid) {
+			checked++
+		}
+	}
+	if checked != 3 {
+		t.Errorf("got %d checked attempts want 3", checked)
+	}
+}
@@ -0,0 +1,303 @@
+package ihttp
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+)
+
+// Permission is a "resource:action" permission, "*" match any resource or action
+// and the ":own" suffix grant it only on the resources owned by the principal
+type Permission struct {
+	Resource string
+	Action   string
+	Own      bool
+}
+
+// ParsePermission parse "resource:action" or "resource:action:own"
+func ParsePermission(permission string) (Permission, error) {
+	parts := strings.Split(strings.TrimSpace(permission), ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" || (len(parts) == 3 && parts[2] != "own") {
+		return Permission{}, ErrPermissionInvalid(permission)
+	}
+	return Permission{
+		Resource: parts[0],
+		Action:   parts[1],
+		Own:      len(parts) == 3,
+	}, nil
+}
+
+func (permission Permission) match(action string, resource string) bool {
+	return (permission.Resource == "*" || permission.Resource == resource) &&
+		(permission.Action == "*" || permission.Action == action)
+}
+
+func (permission Permission) String() string {
+	if permission.Own {
+		return permission.Resource + ":" + permission.Action + ":own"
+	}
+	return permission.Resource + ":" + permission.Action
+}
+
+// IPolicySource load the permissions of each role
+type IPolicySource interface {
+	LoadPolicy(ctx context.Context) (map[string][]string, error)
+}
+
+// PolicySourceFunc adapt a function to IPolicySource
+type PolicySourceFunc func(ctx context.Context) (map[string][]string, error)
+
+func (source PolicySourceFunc) LoadPolicy(ctx context.Context) (map[string][]string, error) {
+	return source(ctx)
+}
+
+// ConfigPolicySource return the IPolicySource of the policy config
+func ConfigPolicySource(config IPolicyConfig) IPolicySource {
+	return PolicySourceFunc(func(ctx context.Context) (map[string][]string, error) {
+		return config.GetRoles(), nil
+	})
+}
+
+// OwnershipFunc report whether the principal own the resource of the request,
+// it usually compare the owner of the record of ctx.Param("id") with principal.Subject
+type OwnershipFunc func(ctx IContext, principal *Principal, resource string) (bool, error)
+
+// AuthzDecision is an audited authorization decision
+type AuthzDecision struct {
+	RequestID string `json:"request_id,omitempty"`
+	Subject   string `json:"subject"`
+	Action    string `json:"action"`
+	Resource  string `json:"resource"`
+	Allowed   bool   `json:"allowed"`
+	// Permission is the permission granting the action, empty when denied
+	Permission string `json:"permission,omitempty"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// AuthorizerConfig is the config of Authorizer
+type AuthorizerConfig struct {
+	// Source load the policy, see ConfigPolicySource and NewDBPolicySource
+	Source IPolicySource
+	// Cache share the loaded policy across the replicas, nil load it from Source in each replica
+	Cache IRedisCache
+	// CacheKey is the redis key of the policy, default DefaultPolicyCacheKey
+	CacheKey string
+	// CacheTTL is the lifetime of the policy in redis, default DefaultPolicyCacheTTL
+	CacheTTL time.Duration
+	// LocalTTL is the lifetime of the policy in the memory of the replica, default DefaultPolicyLocalTTL,
+	// a replica see the invalidation of another replica after it
+	LocalTTL time.Duration
+	// Ownership decide the ":own" permissions, they are denied when it is nil
+	Ownership OwnershipFunc
+	// Audit receive every decision in addition to the service log
+	Audit func(ctx IContext, decision AuthzDecision)
+}
+
+// Authorizer decide whether the roles of the principal grant an action on a resource
+type Authorizer struct {
+	config AuthorizerConfig
+
+	mutex    sync.RWMutex
+	roles    map[string][]Permission
+	loadedAt time.Time
+}
+
+// NewAuthorizer return new Authorizer
+func NewAuthorizer(cfg AuthorizerConfig) (*Authorizer, error) {
+	if cfg.Source == nil {
+		return nil, ErrPolicySourceIsRequire
+	}
+	if cfg.CacheKey == "" {
+		cfg.CacheKey = DefaultPolicyCacheKey
+	}
+	if cfg.CacheTTL <= 0 {
+		cfg.CacheTTL = DefaultPolicyCacheTTL
+	}
+	if cfg.LocalTTL <= 0 {
+		cfg.LocalTTL = DefaultPolicyLocalTTL
+	}
+	return &Authorizer{config: cfg}, nil
+}
+
+// Invalidate drop the cached policy, it is called after the policy in the source changed
+func (authorizer *Authorizer) Invalidate() error {
+	authorizer.mutex.Lock()
+	authorizer.roles = nil
+	authorizer.mutex.Unlock()
+	if authorizer.config.Cache != nil {
+		return authorizer.config.Cache.Del(authorizer.config.CacheKey)
+	}
+	return nil
+}
+
+func (authorizer *Authorizer) policy(ctx context.Context) (map[string][]Permission, error) {
+	authorizer.mutex.RLock()
+	roles, loadedAt := authorizer.roles, authorizer.loadedAt
+	authorizer.mutex.RUnlock()
+	if roles != nil && time.Since(loadedAt) < authorizer.config.LocalTTL {
+		return roles, nil
+	}
+
+	var raw map[string][]string
+	if authorizer.config.Cache != nil {
+		if cached, err := authorizer.config.Cache.Get(authorizer.config.CacheKey); err != nil {
+			return nil, err
+		} else if cached != "" {
+			if err := json.Unmarshal([]byte(cached), &raw); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if raw == nil {
+		loaded, err := authorizer.config.Source.LoadPolicy(ctx)
+		if err != nil {
+			return nil, err
+		}
+		raw = loaded
+		if authorizer.config.Cache != nil {
+			cached, err := json.Marshal(raw)
+			if err != nil {
+				return nil, err
+			}
+			if err := authorizer.config.Cache.SetS(authorizer.config.CacheKey, string(cached), authorizer.config.CacheTTL); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	roles = make(map[string][]Permission, len(raw))
+	for role, permissions := range raw {
+		for _, value := range permissions {
+			permission, err := ParsePermission(value)
+			if err != nil {
+				return nil, err
+			}
+			roles[role] = append(roles[role], permission)
+		}
+	}
+	authorizer.mutex.Lock()
+	authorizer.roles, authorizer.loadedAt = roles, time.Now()
+	authorizer.mutex.Unlock()
+	return roles, nil
+}
+
+// Decide return the decision of the action on the resource for the principal and audit it,
+// the ownership of ":own" permissions is checked with ctx
+func (authorizer *Authorizer) Decide(ctx IContext, principal *Principal, action string, resource string) (AuthzDecision, error) {
+	decision := AuthzDecision{
+		Action:   action,
+		Resource: resource,
+	}
+	if web := ctx.WebContext(); web != nil {
+		decision.RequestID = web.Response().Header().Get(echo.HeaderXRequestID)
+	}
+	if principal == nil {
+		decision.Reason = "unauthenticated"
+		authorizer.audit(ctx, decision)
+		return decision, nil
+	}
+	decision.Subject = principal.Subject
+
+	roles, err := authorizer.policy(ctx.Context())
+	if err != nil {
+		return decision, err
+	}
+	ownChecked, owner := false, false
+	for _, role := range principal.Roles {
+		for _, permission := range roles[role] {
+			if !permission.match(action, resource) {
+				continue
+			}
+			if permission.Own {
+				if authorizer.config.Ownership == nil {
+					continue
+				}
+				if !ownChecked {
+					if owner, err = authorizer.config.Ownership(ctx, principal, resource); err != nil {
+						return decision, err
+					}
+					ownChecked = true
+				}
+				if !owner {
+					continue
+				}
+			}
+			decision.Allowed = true
+			decision.Permission = permission.String()
+			authorizer.audit(ctx, decision)
+			return decision, nil
+		}
+	}
+	decision.Reason = "no role grant the permission"
+	if ownChecked {
+		decision.Reason = "not the owner of the resource"
+	}
+	authorizer.audit(ctx, decision)
+	return decision, nil
+}
+
+func (authorizer *Authorizer) audit(ctx IContext, decision AuthzDecision) {
+	level, result := InfoLevel, "allow"
+	if !decision.Allowed {
+		level, result = WarnLevel, "deny"
+	}
+	ctx.Log(level, "[AUTHZ] "+result+" "+decision.Resource+":"+decision.Action,
+		zap.String("subject", decision.Subject),
+		zap.String("permission", decision.Permission),
+		zap.String("reason", decision.Reason),
+	)
+	if authorizer.config.Audit != nil {
+		authorizer.config.Audit(ctx, decision)
+	}
+}
+
+// Can report whether the principal of the request may do the action on the resource,
+// it is false when the service has no Authorizer or the decision fail
+func (ctx *HTTPContext) Can(action string, resource string) bool {
+	if ctx.ms == nil || ctx.ms.authorizer == nil {
+		return false
+	}
+	principal, _ := ctx.Principal()
+	decision, err := ctx.ms.authorizer.Decide(ctx, principal, action, resource)
+	if err != nil {
+		ctx.Log(ErrorLevel, "[AUTHZ] decision failed", zap.Error(err))
+		return false
+	}
+	return decision.Allowed
+}
+
+// RequirePermission return the middleware answering 403 unless the principal has the "resource:action"
+// permission, it must be used after an authentication middleware and WithAuthorizer
+func (ms *Microservice) RequirePermission(permission string) echo.MiddlewareFunc {
+	required, err := ParsePermission(permission)
+	if err != nil || required.Own {
+		panic(ErrPermissionInvalid(permission))
+	}
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			ctx := NewHTTPContext(ms, c)
+			if ms.authorizer == nil {
+				return ctx.Response(ErrorLevel, "AUTHZ", http.StatusInternalServerError, "INTERNAL_ERROR", "authorizer is not configured", ErrAuthorizerNotfound)
+			}
+			principal, found := ctx.Principal()
+			if !found {
+				c.Response().Header().Set(echo.HeaderWWWAuthenticate, `Bearer`)
+				return ctx.Response(WarnLevel, "AUTHZ", http.StatusUnauthorized, "UNAUTHORIZED", "authentication is required", ErrJWTMissing)
+			}
+			decision, err := ms.authorizer.Decide(ctx, principal, required.Action, required.Resource)
+			if err != nil {
+				return ctx.Response(ErrorLevel, "AUTHZ", http.StatusInternalServerError, "INTERNAL_ERROR", "cannot decide permission", err)
+			}
+			if !decision.Allowed {
+				return ctx.Response(WarnLevel, "AUTHZ", http.StatusForbidden, "FORBIDDEN", "permission "+permission+" is required", ErrPrincipalForbidden("permission"))
+			}
+			return next(c)
+		}
+	}
+}
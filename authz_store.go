@@ -0,0 +1,89 @@
+package ihttp
+
+import (
+	"context"
+	"fmt"
+)
+
+// DBPolicySource load the policy from a role permission table, see PolicySchema
+type DBPolicySource struct {
+	db    IDBStore
+	table string
+}
+
+// NewDBPolicySource return new DBPolicySource of the table, default DefaultPolicyTable
+func NewDBPolicySource(db IDBStore, table string) *DBPolicySource {
+	if table == "" {
+		table = DefaultPolicyTable
+	}
+	return &DBPolicySource{
+		db:    db,
+		table: table,
+	}
+}
+
+// PolicySchema return the statements creating the role permission table for the database provider
+func PolicySchema(provider string, table string) ([]string, error) {
+	switch provider {
+	case POSTGRES, MYSQL, SQLITE:
+		return []string{
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    role       VARCHAR(255) NOT NULL,
+    permission VARCHAR(255) NOT NULL,
+    PRIMARY KEY (role, permission)
+)`, table),
+		}, nil
+	}
+	return nil, ErrInvalidDBProvider(provider)
+}
+
+// CreateTable create the role permission table when it does not exist
+func (source *DBPolicySource) CreateTable(ctx context.Context) error {
+	statements, err := PolicySchema(source.db.Provider(), source.table)
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err := source.db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type rolePermissionRow struct {
+	Role       string `db:"role"`
+	Permission string `db:"permission"`
+}
+
+func (source *DBPolicySource) LoadPolicy(ctx context.Context) (map[string][]string, error) {
+	rows, err := QueryAll[rolePermissionRow](ctx, source.db, fmt.Sprintf(
+		"SELECT role, permission FROM %s ORDER BY role, permission", source.table), nil)
+	if err != nil {
+		return nil, err
+	}
+	roles := make(map[string][]string)
+	for _, row := range rows {
+		roles[row.Role] = append(roles[row.Role], row.Permission)
+	}
+	return roles, nil
+}
+
+// Grant add the permission to the role, the authorizer must be invalidated to apply it
+func (source *DBPolicySource) Grant(ctx context.Context, role string, permission string) error {
+	if _, err := ParsePermission(permission); err != nil {
+		return err
+	}
+	_, err := Exec(ctx, source.db, fmt.Sprintf(
+		"INSERT INTO %s (role, permission) VALUES (:role, :permission)", source.table),
+		rolePermissionRow{Role: role, Permission: permission})
+	return err
+}
+
+// Revoke remove the permission from the role, the authorizer must be invalidated to apply it
+func (source *DBPolicySource) Revoke(ctx context.Context, role string, permission string) error {
+	_, err := Exec(ctx, source.db, fmt.Sprintf(
+		"DELETE FROM %s WHERE role = :role AND permission = :permission", source.table),
+		rolePermissionRow{Role: role, Permission: permission})
+	return err
+}
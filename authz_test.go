@@ -0,0 +1,192 @@
+package ihttp_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gitkeng/ihttp"
+	"github.com/golang-jwt/jwt"
+)
+
+func TestAuthorizerRequirePermission(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.yaml")
+	os.WriteFile(file, []byte(`
+policy-roles:
+  - name: customer
+    permissions: ["order:read:own", "order:write:own"]
+  - name: staff
+    permissions: ["order:read"]
+  - name: admin
+    permissions: ["order:*"]
+    inherits: ["staff"]
+`), 0o600)
+	policyCfg := &ihttp.PolicyConfig{}
+	if err := ihttp.ReadConfigFile(file, policyCfg); err != nil {
+		t.Error(err)
+		return
+	}
+
+	owners := map[string]string{"o1": "somchai", "o2": "somsri"}
+	var auditMutex sync.Mutex
+	decisions := make([]ihttp.AuthzDecision, 0)
+	cache := newMemoryCache()
+	authorizer, err := ihttp.NewAuthorizer(ihttp.AuthorizerConfig{
+		Source: ihttp.ConfigPolicySource(policyCfg),
+		Cache:  cache,
+		Ownership: func(ctx ihttp.IContext, principal *ihttp.Principal, resource string) (bool, error) {
+			return owners[ctx.Param("id")] == principal.Subject, nil
+		},
+		Audit: func(ctx ihttp.IContext, decision ihttp.AuthzDecision) {
+			auditMutex.Lock()
+			defer auditMutex.Unlock()
+			decisions = append(decisions, decision)
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}), ihttp.WithAuthorizer(authorizer))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer ms.Cleanup()
+	auth, err := ms.JWT(ihttp.JWTConfig{Secret: "s3cret"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ms.PUT("/orders/:id", func(ctx ihttp.IContext) error {
+		return ctx.WebContext().NoContent(http.StatusNoContent)
+	}, auth, ms.RequirePermission("order:write"))
+	ms.GET("/orders/:id", func(ctx ihttp.IContext) error {
+		if !ctx.Can("read", "order") {
+			return ctx.WebContext().NoContent(http.StatusNotFound)
+		}
+		return ctx.WebContext().NoContent(http.StatusOK)
+	}, auth)
+	server := httptest.NewServer(ms.GetEngine())
+	defer server.Close()
+
+	call := func(method string, path string, subject string, roles ...string) int {
+		token, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub":   subject,
+			"roles": roles,
+			"exp":   time.Now().Add(time.Hour).Unix(),
+		}).SignedString([]byte("s3cret"))
+		req, _ := http.NewRequest(method, server.URL+path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		subject string
+		roles   []string
+		status  int
+	}{
+		{"owner write", http.MethodPut, "/orders/o1", "somchai", []string{"customer"}, http.StatusNoContent},
+		{"not owner write", http.MethodPut, "/orders/o2", "somchai", []string{"customer"}, http.StatusForbidden},
+		{"staff write", http.MethodPut, "/orders/o1", "manee", []string{"staff"}, http.StatusForbidden},
+		{"admin wildcard", http.MethodPut, "/orders/o2", "boss", []string{"admin"}, http.StatusNoContent},
+		{"no role", http.MethodPut, "/orders/o1", "somchai", nil, http.StatusForbidden},
+		{"owner can read", http.MethodGet, "/orders/o1", "somchai", []string{"customer"}, http.StatusOK},
+		{"not owner cannot read", http.MethodGet, "/orders/o2", "somchai", []string{"customer"}, http.StatusNotFound},
+		{"admin inherit staff", http.MethodGet, "/orders/o2", "boss", []string{"admin"}, http.StatusOK},
+	}
+	for _, tc := range tests {
+		if status := call(tc.method, tc.path, tc.subject, tc.roles...); status != tc.status {
+			t.Errorf("%s: expect status %d, got %d", tc.name, tc.status, status)
+		}
+	}
+
+	auditMutex.Lock()
+	if len(decisions) != len(tests) || decisions[0].RequestID == "" || !decisions[0].Allowed || decisions[1].Allowed {
+		t.Errorf("expect every decision audited with request id, got %+v", decisions)
+	}
+	auditMutex.Unlock()
+	if cached, _ := cache.Get(ihttp.DefaultPolicyCacheKey); cached == "" {
+		t.Error("expect policy cached in redis")
+	}
+}
+
+func TestDBPolicySource(t *testing.T) {
+	dbCfg := &ihttp.DBConfig{
+		ContextName:  "local",
+		Provider:     ihttp.SQLITE,
+		DatabaseName: ":memory:",
+	}
+	if err := dbCfg.Bind(); err != nil {
+		t.Error(err)
+		return
+	}
+	db, err := ihttp.NewDBStore(dbCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer db.Close()
+	ctx := context.Background()
+	source := ihttp.NewDBPolicySource(db, "")
+	if err := source.CreateTable(ctx); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := source.Grant(ctx, "staff", "order:read"); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := source.Grant(ctx, "staff", "order"); err == nil {
+		t.Error("expect invalid permission rejected")
+	}
+
+	authorizer, err := ihttp.NewAuthorizer(ihttp.AuthorizerConfig{Source: source, Cache: newMemoryCache()})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer ms.Cleanup()
+	httpCtx := ihttp.NewHTTPContext(ms, ms.GetEngine().NewContext(httptest.NewRequest(http.MethodGet, "/", nil), httptest.NewRecorder()))
+	staff := &ihttp.Principal{Subject: "manee", Roles: []string{"staff"}}
+	can := func(action string) bool {
+		decision, err := authorizer.Decide(httpCtx, staff, action, "order")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decision.Allowed
+	}
+
+	if !can("read") || can("write") {
+		t.Error("expect staff read only")
+	}
+	source.Grant(ctx, "staff", "order:write")
+	if can("write") {
+		t.Error("expect cached policy until invalidated")
+	}
+	if err := authorizer.Invalidate(); err != nil {
+		t.Error(err)
+	}
+	if !can("write") {
+		t.Error("expect granted permission after invalidation")
+	}
+}
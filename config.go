@@ -23,16 +23,24 @@ type Config struct {
 	apiConf       IAPIConfig
 	dbConfs       map[string]IDBConfig
 	redisConfs    map[string]IRedisConfig
+	outboxConfs   map[string]IOutboxConfig
+	clientConfs   map[string]IClientConfig
+	policyConf    IPolicyConfig
 	dbConfsMutex  sync.RWMutex
 	logCfgMutex   sync.RWMutex
 	apiCfgMutex   sync.RWMutex
 	redisCfgMutex sync.RWMutex
+	outboxMutex   sync.RWMutex
+	clientMutex   sync.RWMutex
+	policyMutex   sync.RWMutex
 }
 
 func NewConfig(confFile string) (*Config, error) {
 	conf := &Config{
 		dbConfs:       make(map[string]IDBConfig),
 		redisConfs:    make(map[string]IRedisConfig),
+		outboxConfs:   make(map[string]IOutboxConfig),
+		clientConfs:   make(map[string]IClientConfig),
 		dbConfsMutex:  sync.RWMutex{},
 		logCfgMutex:   sync.RWMutex{},
 		redisCfgMutex: sync.RWMutex{},
@@ -59,6 +67,21 @@ func NewConfig(confFile string) (*Config, error) {
 		return nil, err
 	}
 
+	err = conf.outboxConfigLoader(confFile)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conf.clientConfigLoader(confFile)
+	if err != nil {
+		return nil, err
+	}
+
+	err = conf.policyConfigLoader(confFile)
+	if err != nil {
+		return nil, err
+	}
+
 	return conf, nil
 }
 
@@ -91,6 +114,28 @@ func (conf *Config) Validate() error {
 		}
 	}
 
+	if len(conf.outboxConfs) > 0 {
+		for _, outboxConf := range conf.outboxConfs {
+			if err := outboxConf.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(conf.clientConfs) > 0 {
+		for _, clientConf := range conf.clientConfs {
+			if err := clientConf.Validate(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if conf.policyConf != nil {
+		if err := conf.policyConf.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -286,6 +331,28 @@ func (conf *Config) Bind() error {
 		}
 	}
 
+	if len(conf.outboxConfs) > 0 {
+		for _, outboxConf := range conf.outboxConfs {
+			if err := outboxConf.Bind(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(conf.clientConfs) > 0 {
+		for _, clientConf := range conf.clientConfs {
+			if err := clientConf.Bind(); err != nil {
+				return err
+			}
+		}
+	}
+
+	if conf.policyConf != nil {
+		if err := conf.policyConf.Bind(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -532,3 +599,97 @@ func (conf *Config) redisConfigLoader(fileLocation string) error {
 	}
 	return nil
 }
+
+func (conf *Config) GetOutboxConfig(contextName string) (IOutboxConfig, bool) {
+	conf.outboxMutex.RLock()
+	defer conf.outboxMutex.RUnlock()
+	cfg, ok := conf.outboxConfs[contextName]
+	if !ok {
+		return nil, false
+	}
+	return cfg, ok
+}
+
+func (conf *Config) GetOutboxConfigs() ([]IOutboxConfig, bool) {
+	conf.outboxMutex.RLock()
+	defer conf.outboxMutex.RUnlock()
+	if len(conf.outboxConfs) == 0 {
+		return nil, false
+	}
+	configs := make([]IOutboxConfig, 0)
+	for idx, _ := range conf.outboxConfs {
+		configs = append(configs, conf.outboxConfs[idx])
+	}
+	return configs, true
+}
+
+func (conf *Config) outboxConfigLoader(fileLocation string) error {
+	conf.outboxMutex.Lock()
+	defer conf.outboxMutex.Unlock()
+	outboxCfgs := &OutboxConfigs{}
+	if err := ReadConfigFile(fileLocation, outboxCfgs); err != nil {
+		return err
+	}
+	for idx, _ := range outboxCfgs.Outboxes {
+		conf.outboxConfs[outboxCfgs.Outboxes[idx].GetContextName()] = outboxCfgs.Outboxes[idx]
+	}
+	return nil
+}
+
+func (conf *Config) GetClientConfig(contextName string) (IClientConfig, bool) {
+	conf.clientMutex.RLock()
+	defer conf.clientMutex.RUnlock()
+	cfg, ok := conf.clientConfs[contextName]
+	if !ok {
+		return nil, false
+	}
+	return cfg, ok
+}
+
+func (conf *Config) GetClientConfigs() ([]IClientConfig, bool) {
+	conf.clientMutex.RLock()
+	defer conf.clientMutex.RUnlock()
+	if len(conf.clientConfs) == 0 {
+		return nil, false
+	}
+	configs := make([]IClientConfig, 0)
+	for idx, _ := range conf.clientConfs {
+		configs = append(configs, conf.clientConfs[idx])
+	}
+	return configs, true
+}
+
+func (conf *Config) clientConfigLoader(fileLocation string) error {
+	conf.clientMutex.Lock()
+	defer conf.clientMutex.Unlock()
+	clientCfgs := &ClientConfigs{}
+	if err := ReadConfigFile(fileLocation, clientCfgs); err != nil {
+		return err
+	}
+	for idx, _ := range clientCfgs.Clients {
+		conf.clientConfs[clientCfgs.Clients[idx].GetContextName()] = clientCfgs.Clients[idx]
+	}
+	return nil
+}
+
+func (conf *Config) GetPolicyConfig() (IPolicyConfig, bool) {
+	conf.policyMutex.RLock()
+	defer conf.policyMutex.RUnlock()
+	if conf.policyConf == nil {
+		return nil, false
+	}
+	return conf.policyConf, true
+}
+
+func (conf *Config) policyConfigLoader(fileLocation string) error {
+	conf.policyMutex.Lock()
+	defer conf.policyMutex.Unlock()
+	policyCfg := &PolicyConfig{}
+	if err := ReadConfigFile(fileLocation, policyCfg); err != nil {
+		return err
+	}
+	if len(policyCfg.Roles) > 0 {
+		conf.policyConf = policyCfg
+	}
+	return nil
+}
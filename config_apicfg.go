@@ -1,6 +1,9 @@
 package ihttp
 
 import (
+	"net/http"
+	"strings"
+
 	"github.com/gitkeng/ihttp/util/convutil"
 	"github.com/gitkeng/ihttp/util/fileutil"
 	"github.com/gitkeng/ihttp/util/stringutil"
@@ -23,6 +26,9 @@ type IAPIConfig interface {
 	// GetSSLKeyFile is the option for setting ssl key file
 	GetSSLKeyFile() string
 	GetCORS() IAPICorsConfig
+	// GetPropagateHeaders is the inbound headers forwarded by requesters from IContext,
+	// X-Request-ID and the trace context headers are always forwarded
+	GetPropagateHeaders() []string
 }
 
 type SSLType string
@@ -43,6 +49,8 @@ type APIConfig struct {
 	// SSLKeyFile is the option for setting ssl key file
 	SSLKeyFile string        `mapstructure:"ssl-key-file" json:"ssl_key_file"`
 	Cors       APICorsConfig `mapstructure:"CORS" json:"cors"`
+	// PropagateHeaders is the inbound headers forwarded by requesters from IContext
+	PropagateHeaders []string `mapstructure:"propagate-headers" json:"propagate_headers"`
 }
 
 func (apiCfg *APIConfig) Bind() error {
@@ -58,6 +66,13 @@ func (apiCfg *APIConfig) Bind() error {
 		}
 	}
 	apiCfg.Cors.Bind()
+	propagateHeaders := make([]string, 0, len(apiCfg.PropagateHeaders))
+	for _, header := range apiCfg.PropagateHeaders {
+		if header = strings.TrimSpace(header); header != "" {
+			propagateHeaders = append(propagateHeaders, http.CanonicalHeaderKey(header))
+		}
+	}
+	apiCfg.PropagateHeaders = propagateHeaders
 	return nil
 }
 
@@ -131,3 +146,7 @@ func (apiCfg *APIConfig) IsHttpsOnly() bool {
 func (apiCfg *APIConfig) GetCORS() IAPICorsConfig {
 	return &apiCfg.Cors
 }
+
+func (apiCfg *APIConfig) GetPropagateHeaders() []string {
+	return apiCfg.PropagateHeaders
+}
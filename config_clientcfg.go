@@ -0,0 +1,161 @@
+package ihttp
+
+import (
+	"crypto/tls"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gitkeng/ihttp/util/convutil"
+	"github.com/gitkeng/ihttp/util/stringutil"
+)
+
+var clientTLSVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// IClientConfig is the configuration interface of a named downstream client
+type IClientConfig interface {
+	IConfig
+	// GetContextName is the option for setting client context name
+	GetContextName() string
+	// GetBaseURL is the base url of the downstream
+	GetBaseURL() string
+	// GetRequesterOptions return the requester options of the client
+	GetRequesterOptions() RequesterOptions
+}
+
+type ClientConfig struct {
+	ContextName string `mapstructure:"context-name" json:"context_name"`
+	BaseURL     string `mapstructure:"base-url" json:"base_url"`
+	// Timeout in milliseconds of each attempt
+	Timeout int `mapstructure:"timeout" json:"timeout"`
+	// DeadlineBudget in milliseconds of a call including retries, 0 is unlimited
+	DeadlineBudget int `mapstructure:"deadline-budget" json:"deadline_budget"`
+	// Headers is set on every call
+	Headers          map[string]string `mapstructure:"headers" json:"headers"`
+	PropagateHeaders []string          `mapstructure:"propagate-headers" json:"propagate_headers"`
+	// RetryMaxAttempts is the attempts of idempotent calls, 0 disable retry
+	RetryMaxAttempts     int  `mapstructure:"retry-max-attempts" json:"retry_max_attempts"`
+	CircuitBreakerEnable bool `mapstructure:"circuit-breaker-enable" json:"circuit_breaker_enable"`
+
+	// CertFiles is the additional CA certificate files
+	CertFiles      []string `mapstructure:"cert-files" json:"cert_files"`
+	ClientCertFile string   `mapstructure:"client-cert-file" json:"client_cert_file"`
+	ClientKeyFile  string   `mapstructure:"client-key-file" json:"client_key_file"`
+	ServerName     string   `mapstructure:"server-name" json:"server_name"`
+	// MinTLSVersion is 1.0, 1.1, 1.2 or 1.3, default 1.2
+	MinTLSVersion      string   `mapstructure:"min-tls-version" json:"min_tls_version"`
+	PinnedSPKIHashes   []string `mapstructure:"pinned-spki-hashes" json:"pinned_spki_hashes"`
+	InsecureSkipVerify bool     `mapstructure:"insecure-skip-verify" json:"insecure_skip_verify"`
+
+	MaxIdleConns        int `mapstructure:"max-idle-conns" json:"max_idle_conns"`
+	MaxIdleConnsPerHost int `mapstructure:"max-idle-conns-per-host" json:"max_idle_conns_per_host"`
+	// MaxConnsPerHost limit the connections per host including active ones, 0 is unlimited
+	MaxConnsPerHost int `mapstructure:"max-conns-per-host" json:"max_conns_per_host"`
+	// IdleConnTimeout in seconds
+	IdleConnTimeout int `mapstructure:"idle-conn-timeout" json:"idle_conn_timeout"`
+}
+
+func (client *ClientConfig) Bind() error {
+	client.ContextName = strings.TrimSpace(client.ContextName)
+	client.BaseURL = strings.TrimRight(strings.TrimSpace(client.BaseURL), "/")
+	if client.Timeout <= 0 {
+		client.Timeout = DefaultClientTimeout
+	}
+	// viper lower the map keys, the header names are canonicalized
+	headers := make(map[string]string, len(client.Headers))
+	for name, value := range client.Headers {
+		if name = strings.TrimSpace(name); name != "" {
+			headers[http.CanonicalHeaderKey(name)] = value
+		}
+	}
+	client.Headers = headers
+	propagateHeaders := make([]string, 0, len(client.PropagateHeaders))
+	for _, header := range client.PropagateHeaders {
+		if header = strings.TrimSpace(header); header != "" {
+			propagateHeaders = append(propagateHeaders, http.CanonicalHeaderKey(header))
+		}
+	}
+	client.PropagateHeaders = propagateHeaders
+	client.MinTLSVersion = strings.TrimSpace(client.MinTLSVersion)
+	if client.MaxIdleConns <= 0 {
+		client.MaxIdleConns = DefaultRequesterMaxIdleConns
+	}
+	if client.MaxIdleConnsPerHost <= 0 {
+		client.MaxIdleConnsPerHost = DefaultRequesterMaxIdleConnsPerHost
+	}
+	if client.IdleConnTimeout <= 0 {
+		client.IdleConnTimeout = int(DefaultRequesterIdleConnTimeout / time.Second)
+	}
+	return nil
+}
+
+func (client *ClientConfig) Validate() error {
+	if stringutil.IsEmptyString(client.ContextName) {
+		return ErrClientContextNameIsRequire
+	}
+	if stringutil.IsEmptyString(client.BaseURL) {
+		return ErrClientBaseURLIsRequire
+	}
+	if client.MinTLSVersion != "" {
+		if _, ok := clientTLSVersions[client.MinTLSVersion]; !ok {
+			return ErrInvalidClientTLSVersion(client.MinTLSVersion)
+		}
+	}
+	if client.ClientCertFile != "" && client.ClientKeyFile == "" {
+		return ErrSSLKeyFileRequire
+	}
+	if client.ClientKeyFile != "" && client.ClientCertFile == "" {
+		return ErrSSLCertificateFileRequire
+	}
+	return nil
+}
+
+func (client *ClientConfig) String() string {
+	return stringutil.Json(*client)
+}
+
+func (client *ClientConfig) ToMap() map[string]any {
+	return convutil.Obj2Map(*client)
+}
+
+func (client *ClientConfig) GetContextName() string {
+	return client.ContextName
+}
+
+func (client *ClientConfig) GetBaseURL() string {
+	return client.BaseURL
+}
+
+func (client *ClientConfig) GetRequesterOptions() RequesterOptions {
+	options := RequesterOptions{
+		Timeout:          time.Duration(client.Timeout) * time.Millisecond,
+		DeadlineBudget:   time.Duration(client.DeadlineBudget) * time.Millisecond,
+		Headers:          client.Headers,
+		PropagateHeaders: client.PropagateHeaders,
+		CertFiles:        client.CertFiles,
+		TLS: RequesterTLSOptions{
+			ClientCertFile:     client.ClientCertFile,
+			ClientKeyFile:      client.ClientKeyFile,
+			ServerName:         client.ServerName,
+			MinVersion:         clientTLSVersions[client.MinTLSVersion],
+			PinnedSPKIHashes:   client.PinnedSPKIHashes,
+			InsecureSkipVerify: client.InsecureSkipVerify,
+		},
+		MaxIdleConns:        client.MaxIdleConns,
+		MaxIdleConnsPerHost: client.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     client.MaxConnsPerHost,
+		IdleConnTimeout:     time.Duration(client.IdleConnTimeout) * time.Second,
+	}
+	if client.RetryMaxAttempts > 0 {
+		options.Retry = &RetryPolicy{MaxAttempts: client.RetryMaxAttempts}
+	}
+	if client.CircuitBreakerEnable {
+		options.CircuitBreaker = &CircuitBreakerConfig{}
+	}
+	return options
+}
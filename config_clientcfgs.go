@@ -0,0 +1,43 @@
+package ihttp
+
+import (
+	"github.com/gitkeng/ihttp/util/convutil"
+	"github.com/gitkeng/ihttp/util/stringutil"
+)
+
+type ClientConfigs struct {
+	IConfig
+	Clients []*ClientConfig `mapstructure:"clients" json:"clients"`
+}
+
+func (client *ClientConfigs) Bind() error {
+	for _, clientConf := range client.Clients {
+		if err := clientConf.Bind(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (client *ClientConfigs) Validate() error {
+	checkDuplicate := make(map[string]bool)
+	for _, clientConf := range client.Clients {
+		if err := clientConf.Validate(); err != nil {
+			return err
+		}
+		if _, ok := checkDuplicate[clientConf.ContextName]; ok {
+			return ErrDuplicateClientContextName(clientConf.ContextName)
+		} else {
+			checkDuplicate[clientConf.ContextName] = true
+		}
+	}
+	return nil
+}
+
+func (client *ClientConfigs) String() string {
+	return stringutil.Json(*client)
+}
+
+func (client *ClientConfigs) ToMap() map[string]any {
+	return convutil.Obj2Map(*client)
+}
@@ -2,13 +2,23 @@ package ihttp
 
 import (
 	"github.com/gitkeng/ihttp/util/convutil"
+	"github.com/gitkeng/ihttp/util/fileutil"
 	"github.com/gitkeng/ihttp/util/stringutil"
 	"strings"
 )
 
+const (
+	DBSSLModeDisable    = "disable"
+	DBSSLModeRequire    = "require"
+	DBSSLModeVerifyCA   = "verify-ca"
+	DBSSLModeVerifyFull = "verify-full"
+)
+
 const (
 	POSTGRES = "postgres"
 	MYSQL    = "mysql"
+	// SQLITE use DatabaseName as the database file path or ":memory:"
+	SQLITE = "sqlite"
 )
 
 type IDBConfig interface {
@@ -27,6 +37,17 @@ type IDBConfig interface {
 	GetDatabaseName() string
 	// GetRetryLimits is the option for setting database retry limits
 	GetRetryLimits() int
+	// GetRetryInitialInterval is the first backoff interval in milliseconds between connect attempts
+	GetRetryInitialInterval() int
+	// GetRetryMaxInterval is the max backoff interval in milliseconds between connect attempts
+	GetRetryMaxInterval() int
+	// GetRetryMaxElapsedTime is the max time in seconds spent on connect attempts before giving up
+	GetRetryMaxElapsedTime() int
+	// IsDegradedStart is the option for starting the service without the database,
+	// the store keep reconnecting in background and report not ready until it succeed
+	IsDegradedStart() bool
+	// GetSlowQueryThreshold is the time in milliseconds a statement must take to be logged as slow query
+	GetSlowQueryThreshold() int
 	// GetInitialScripts is the option for setting database initial scripts
 	GetInitialScripts() []string
 	// GetConnectionMaxLifeTime is the maximum amount of time a dbConn may be reused.
@@ -55,20 +76,74 @@ type IDBConfig interface {
 	// If n <= 0, then there is no limit on the number of open connections.
 	// The default is 0 (unlimited).
 	GetMaxOpenConns() int
+	// GetReplicaURLs is the option for setting read replica urls, replicas use the same user, password and database name
+	GetReplicaURLs() []string
+	// GetReplicaHealthCheckInterval is the interval in seconds for pinging replicas
+	GetReplicaHealthCheckInterval() int
+	// GetReplicaEvictionTime is the time in seconds a failed replica is not used
+	GetReplicaEvictionTime() int
+	// IsReadYourWrites is the option for routing reads to the primary after a write within the same request
+	IsReadYourWrites() bool
+	// GetSSLMode is the option for setting ssl mode: disable, require, verify-ca or verify-full
+	GetSSLMode() string
+	// GetSSLRootCert is the option for setting the CA certificate file to verify the server
+	GetSSLRootCert() string
+	// GetSSLCert is the option for setting the client certificate file
+	GetSSLCert() string
+	// GetSSLKey is the option for setting the client key file
+	GetSSLKey() string
+	// GetConnectTimeout is the option for setting connect timeout in seconds
+	GetConnectTimeout() int
+	// GetStatementTimeout is the option for setting statement timeout in milliseconds,
+	// mysql apply it to SELECT statements only
+	GetStatementTimeout() int
+	// GetApplicationName is the option for setting application name, postgres only
+	GetApplicationName() string
+	// GetTimezone is the option for setting session timezone such as Asia/Bangkok
+	GetTimezone() string
+	// GetOptions is the free-form driver parameters appended to the connection string,
+	// they override the parameters built from the other options
+	GetOptions() map[string]string
 }
 
 type DBConfig struct {
-	ContextName           string   `mapstructure:"context-name" json:"context_name"`
-	Provider              string   `mapstructure:"provider" json:"provider"`
-	URL                   string   `mapstructure:"url" json:"url"`
-	User                  string   `mapstructure:"user" json:"user"`
-	Password              string   `mapstructure:"password" json:"password"`
-	DatabaseName          string   `mapstructure:"database-name" json:"database_name"`
-	RetryLimits           int      `mapstructure:"retry-limits" json:"retry_limits"`
+	ContextName  string `mapstructure:"context-name" json:"context_name"`
+	Provider     string `mapstructure:"provider" json:"provider"`
+	URL          string `mapstructure:"url" json:"url"`
+	User         string `mapstructure:"user" json:"user"`
+	Password     string `mapstructure:"password" json:"password"`
+	DatabaseName string `mapstructure:"database-name" json:"database_name"`
+	RetryLimits  int    `mapstructure:"retry-limits" json:"retry_limits"`
+	// RetryInitialInterval in milliseconds
+	RetryInitialInterval int `mapstructure:"retry-initial-interval" json:"retry_initial_interval"`
+	// RetryMaxInterval in milliseconds
+	RetryMaxInterval int `mapstructure:"retry-max-interval" json:"retry_max_interval"`
+	// RetryMaxElapsedTime in seconds
+	RetryMaxElapsedTime int  `mapstructure:"retry-max-elapsed-time" json:"retry_max_elapsed_time"`
+	DegradedStart       bool `mapstructure:"degraded-start" json:"degraded_start"`
+	// SlowQueryThreshold in milliseconds
+	SlowQueryThreshold    int      `mapstructure:"slow-query-threshold" json:"slow_query_threshold"`
 	ConnectionMaxLifeTime int      `mapstructure:"dbConn-max-life-time" json:"connection_max_life_time"`
 	MaxIdleConns          int      `mapstructure:"max-idle-conns" json:"max_idle_conns"`
 	MaxOpenConns          int      `mapstructure:"max-open-conns" json:"max_open_conns"`
 	InitialScripts        []string `mapstructure:"initial-scripts" json:"initial_scripts"`
+	ReplicaURLs           []string `mapstructure:"replica-urls" json:"replica_urls"`
+	// ReplicaHealthCheckInterval in seconds
+	ReplicaHealthCheckInterval int `mapstructure:"replica-health-check-interval" json:"replica_health_check_interval"`
+	// ReplicaEvictionTime in seconds
+	ReplicaEvictionTime int    `mapstructure:"replica-eviction-time" json:"replica_eviction_time"`
+	ReadYourWrites      bool   `mapstructure:"read-your-writes" json:"read_your_writes"`
+	SSLMode             string `mapstructure:"ssl-mode" json:"ssl_mode"`
+	SSLRootCert         string `mapstructure:"ssl-root-cert" json:"ssl_root_cert"`
+	SSLCert             string `mapstructure:"ssl-cert" json:"ssl_cert"`
+	SSLKey              string `mapstructure:"ssl-key" json:"ssl_key"`
+	// ConnectTimeout in seconds
+	ConnectTimeout int `mapstructure:"connect-timeout" json:"connect_timeout"`
+	// StatementTimeout in milliseconds
+	StatementTimeout int               `mapstructure:"statement-timeout" json:"statement_timeout"`
+	ApplicationName  string            `mapstructure:"application-name" json:"application_name"`
+	Timezone         string            `mapstructure:"timezone" json:"timezone"`
+	Options          map[string]string `mapstructure:"options" json:"options"`
 }
 
 func (db *DBConfig) Bind() error {
@@ -77,6 +152,21 @@ func (db *DBConfig) Bind() error {
 	if db.RetryLimits <= 0 {
 		db.RetryLimits = DefaultDBGetRetryLimits
 	}
+	if db.RetryInitialInterval <= 0 {
+		db.RetryInitialInterval = DefaultDBRetryInitialInterval
+	}
+	if db.RetryMaxInterval <= 0 {
+		db.RetryMaxInterval = DefaultDBRetryMaxInterval
+	}
+	if db.RetryMaxInterval < db.RetryInitialInterval {
+		db.RetryMaxInterval = db.RetryInitialInterval
+	}
+	if db.RetryMaxElapsedTime <= 0 {
+		db.RetryMaxElapsedTime = DefaultDBRetryMaxElapsedTime
+	}
+	if db.SlowQueryThreshold <= 0 {
+		db.SlowQueryThreshold = DefaultDBSlowQueryThreshold
+	}
 	if db.ConnectionMaxLifeTime <= 0 {
 		db.ConnectionMaxLifeTime = DefaultDBConnectionMaxLifeTime
 	}
@@ -86,6 +176,27 @@ func (db *DBConfig) Bind() error {
 	if db.MaxOpenConns <= 0 {
 		db.MaxOpenConns = DefaultDBConnectionMaxOpenConns
 	}
+	replicaURLs := make([]string, 0, len(db.ReplicaURLs))
+	for _, url := range db.ReplicaURLs {
+		if url = strings.TrimSpace(url); url != "" {
+			replicaURLs = append(replicaURLs, url)
+		}
+	}
+	db.ReplicaURLs = replicaURLs
+	if db.ReplicaHealthCheckInterval <= 0 {
+		db.ReplicaHealthCheckInterval = DefaultDBReplicaHealthCheckInterval
+	}
+	if db.ReplicaEvictionTime <= 0 {
+		db.ReplicaEvictionTime = DefaultDBReplicaEvictionTime
+	}
+	db.SSLMode = strings.ToLower(strings.TrimSpace(db.SSLMode))
+	if db.SSLMode == "" {
+		db.SSLMode = DBSSLModeDisable
+	}
+	db.SSLRootCert = strings.TrimSpace(db.SSLRootCert)
+	db.SSLCert = strings.TrimSpace(db.SSLCert)
+	db.SSLKey = strings.TrimSpace(db.SSLKey)
+	db.Timezone = strings.TrimSpace(db.Timezone)
 	return nil
 }
 
@@ -94,10 +205,21 @@ func (db *DBConfig) Validate() error {
 		return ErrDBContextNameIsRequire
 	}
 
-	if db.Provider != POSTGRES && db.Provider != MYSQL {
+	if db.Provider != POSTGRES && db.Provider != MYSQL && db.Provider != SQLITE {
 		return ErrInvalidDBProvider(db.Provider)
 	}
 
+	if db.Provider == SQLITE {
+		// sqlite is a local file, there is no server to authenticate
+		if stringutil.IsEmptyString(db.DatabaseName) {
+			return ErrDBNameRequire
+		}
+		if len(db.ReplicaURLs) > 0 {
+			return ErrDBReplicaNotSupport(db.Provider)
+		}
+		return nil
+	}
+
 	if stringutil.IsEmptyString(db.URL) {
 		return ErrDBURLRequire
 	}
@@ -114,6 +236,39 @@ func (db *DBConfig) Validate() error {
 		return ErrDBNameRequire
 	}
 
+	switch db.SSLMode {
+	case DBSSLModeDisable, DBSSLModeRequire, DBSSLModeVerifyCA, DBSSLModeVerifyFull:
+	default:
+		return ErrInvalidDBSSLMode(db.SSLMode)
+	}
+	if stringutil.IsNotEmptyString(db.SSLRootCert) {
+		if found, _ := fileutil.IsFileExist(db.SSLRootCert); !found {
+			return ErrSSLCertificateFileNotfound(db.SSLRootCert)
+		}
+	}
+	if stringutil.IsNotEmptyString(db.SSLCert) || stringutil.IsNotEmptyString(db.SSLKey) {
+		if stringutil.IsEmptyString(db.SSLCert) {
+			return ErrSSLCertificateFileRequire
+		}
+		if found, _ := fileutil.IsFileExist(db.SSLCert); !found {
+			return ErrSSLCertificateFileNotfound(db.SSLCert)
+		}
+		if stringutil.IsEmptyString(db.SSLKey) {
+			return ErrSSLKeyFileRequire
+		}
+		if found, _ := fileutil.IsFileExist(db.SSLKey); !found {
+			return ErrSSLKeyFileNotfound(db.SSLKey)
+		}
+	}
+
+	if db.Provider == POSTGRES {
+		for _, url := range db.ReplicaURLs {
+			if len(strings.Split(url, ":")) != 2 {
+				return ErrDBURLPattern(url)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -153,6 +308,26 @@ func (db *DBConfig) GetRetryLimits() int {
 	return db.RetryLimits
 }
 
+func (db *DBConfig) GetRetryInitialInterval() int {
+	return db.RetryInitialInterval
+}
+
+func (db *DBConfig) GetRetryMaxInterval() int {
+	return db.RetryMaxInterval
+}
+
+func (db *DBConfig) GetRetryMaxElapsedTime() int {
+	return db.RetryMaxElapsedTime
+}
+
+func (db *DBConfig) IsDegradedStart() bool {
+	return db.DegradedStart
+}
+
+func (db *DBConfig) GetSlowQueryThreshold() int {
+	return db.SlowQueryThreshold
+}
+
 func (db *DBConfig) GetInitialScripts() []string {
 	return db.InitialScripts
 }
@@ -168,3 +343,55 @@ func (db *DBConfig) GetMaxIdleConns() int {
 func (db *DBConfig) GetMaxOpenConns() int {
 	return db.MaxOpenConns
 }
+
+func (db *DBConfig) GetReplicaURLs() []string {
+	return db.ReplicaURLs
+}
+
+func (db *DBConfig) GetReplicaHealthCheckInterval() int {
+	return db.ReplicaHealthCheckInterval
+}
+
+func (db *DBConfig) GetReplicaEvictionTime() int {
+	return db.ReplicaEvictionTime
+}
+
+func (db *DBConfig) IsReadYourWrites() bool {
+	return db.ReadYourWrites
+}
+
+func (db *DBConfig) GetSSLMode() string {
+	return db.SSLMode
+}
+
+func (db *DBConfig) GetSSLRootCert() string {
+	return db.SSLRootCert
+}
+
+func (db *DBConfig) GetSSLCert() string {
+	return db.SSLCert
+}
+
+func (db *DBConfig) GetSSLKey() string {
+	return db.SSLKey
+}
+
+func (db *DBConfig) GetConnectTimeout() int {
+	return db.ConnectTimeout
+}
+
+func (db *DBConfig) GetStatementTimeout() int {
+	return db.StatementTimeout
+}
+
+func (db *DBConfig) GetApplicationName() string {
+	return db.ApplicationName
+}
+
+func (db *DBConfig) GetTimezone() string {
+	return db.Timezone
+}
+
+func (db *DBConfig) GetOptions() map[string]string {
+	return db.Options
+}
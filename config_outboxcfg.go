@@ -0,0 +1,199 @@
+package ihttp
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gitkeng/ihttp/util/convutil"
+	"github.com/gitkeng/ihttp/util/stringutil"
+)
+
+const (
+	// OutboxModePubSub publish outbox events with PUBLISH
+	OutboxModePubSub = "pubsub"
+	// OutboxModeStream append outbox events to a stream with XADD
+	OutboxModeStream = "stream"
+)
+
+var outboxTablePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// IOutboxConfig is Outbox configuration interface
+type IOutboxConfig interface {
+	IConfig
+	// GetContextName is the option for setting outbox context name
+	GetContextName() string
+	// GetDBContextName is the database context name of the outbox table
+	GetDBContextName() string
+	// GetCacheContextName is the redis context name the events are published to
+	GetCacheContextName() string
+	// GetTable is the outbox table name
+	GetTable() string
+	// GetMode is the publish mode: pubsub or stream
+	GetMode() string
+	// GetStreamMaxLen is the approximate max length of the stream, 0 is unlimited
+	GetStreamMaxLen() int64
+	// GetBatchSize is the max number of events relayed per poll
+	GetBatchSize() int
+	// GetPollInterval is the interval in milliseconds between polls
+	GetPollInterval() int
+	// GetMaxAttempts is the number of publish attempts before an event is left as failed
+	GetMaxAttempts() int
+	// GetRetryInitialInterval is the first retry backoff in milliseconds of a failed event
+	GetRetryInitialInterval() int
+	// GetRetryMaxInterval is the max retry backoff in milliseconds of a failed event
+	GetRetryMaxInterval() int
+	// GetRetention is the time in seconds delivered events are kept
+	GetRetention() int
+	// GetCleanupInterval is the interval in seconds between deleting delivered events
+	GetCleanupInterval() int
+	// IsAutoCreateTable is the option for creating the outbox table at start
+	IsAutoCreateTable() bool
+}
+
+type OutboxConfig struct {
+	ContextName      string `mapstructure:"context-name" json:"context_name"`
+	DBContextName    string `mapstructure:"db-context-name" json:"db_context_name"`
+	CacheContextName string `mapstructure:"cache-context-name" json:"cache_context_name"`
+	Table            string `mapstructure:"table" json:"table"`
+	Mode             string `mapstructure:"mode" json:"mode"`
+	StreamMaxLen     int64  `mapstructure:"stream-max-len" json:"stream_max_len"`
+	BatchSize        int    `mapstructure:"batch-size" json:"batch_size"`
+	// PollInterval in milliseconds
+	PollInterval int `mapstructure:"poll-interval" json:"poll_interval"`
+	MaxAttempts  int `mapstructure:"max-attempts" json:"max_attempts"`
+	// RetryInitialInterval in milliseconds
+	RetryInitialInterval int `mapstructure:"retry-initial-interval" json:"retry_initial_interval"`
+	// RetryMaxInterval in milliseconds
+	RetryMaxInterval int `mapstructure:"retry-max-interval" json:"retry_max_interval"`
+	// Retention in seconds
+	Retention int `mapstructure:"retention" json:"retention"`
+	// CleanupInterval in seconds
+	CleanupInterval int  `mapstructure:"cleanup-interval" json:"cleanup_interval"`
+	AutoCreateTable bool `mapstructure:"auto-create-table" json:"auto_create_table"`
+}
+
+func (outbox *OutboxConfig) Bind() error {
+	outbox.ContextName = strings.TrimSpace(outbox.ContextName)
+	outbox.DBContextName = strings.TrimSpace(outbox.DBContextName)
+	outbox.CacheContextName = strings.TrimSpace(outbox.CacheContextName)
+	if outbox.ContextName == "" {
+		outbox.ContextName = outbox.DBContextName
+	}
+	outbox.Table = strings.TrimSpace(outbox.Table)
+	if outbox.Table == "" {
+		outbox.Table = DefaultOutboxTable
+	}
+	outbox.Mode = strings.ToLower(strings.TrimSpace(outbox.Mode))
+	if outbox.Mode == "" {
+		outbox.Mode = OutboxModePubSub
+	}
+	if outbox.BatchSize <= 0 {
+		outbox.BatchSize = DefaultOutboxBatchSize
+	}
+	if outbox.PollInterval <= 0 {
+		outbox.PollInterval = DefaultOutboxPollInterval
+	}
+	if outbox.MaxAttempts <= 0 {
+		outbox.MaxAttempts = DefaultOutboxMaxAttempts
+	}
+	if outbox.RetryInitialInterval <= 0 {
+		outbox.RetryInitialInterval = DefaultOutboxRetryInitialInterval
+	}
+	if outbox.RetryMaxInterval <= 0 {
+		outbox.RetryMaxInterval = DefaultOutboxRetryMaxInterval
+	}
+	if outbox.RetryMaxInterval < outbox.RetryInitialInterval {
+		outbox.RetryMaxInterval = outbox.RetryInitialInterval
+	}
+	if outbox.Retention <= 0 {
+		outbox.Retention = DefaultOutboxRetention
+	}
+	if outbox.CleanupInterval <= 0 {
+		outbox.CleanupInterval = DefaultOutboxCleanupInterval
+	}
+	return nil
+}
+
+func (outbox *OutboxConfig) Validate() error {
+	if stringutil.IsEmptyString(outbox.ContextName) {
+		return ErrOutboxContextNameIsRequire
+	}
+	if stringutil.IsEmptyString(outbox.DBContextName) {
+		return ErrOutboxDBContextNameIsRequire
+	}
+	if stringutil.IsEmptyString(outbox.CacheContextName) {
+		return ErrOutboxCacheContextNameIsRequire
+	}
+	// the table name is written into the SQL text
+	if !outboxTablePattern.MatchString(outbox.Table) {
+		return ErrInvalidOutboxTable(outbox.Table)
+	}
+	if outbox.Mode != OutboxModePubSub && outbox.Mode != OutboxModeStream {
+		return ErrInvalidOutboxMode(outbox.Mode)
+	}
+	return nil
+}
+
+func (outbox *OutboxConfig) String() string {
+	return stringutil.Json(*outbox)
+}
+
+func (outbox *OutboxConfig) ToMap() map[string]any {
+	return convutil.Obj2Map(*outbox)
+}
+
+func (outbox *OutboxConfig) GetContextName() string {
+	return outbox.ContextName
+}
+
+func (outbox *OutboxConfig) GetDBContextName() string {
+	return outbox.DBContextName
+}
+
+func (outbox *OutboxConfig) GetCacheContextName() string {
+	return outbox.CacheContextName
+}
+
+func (outbox *OutboxConfig) GetTable() string {
+	return outbox.Table
+}
+
+func (outbox *OutboxConfig) GetMode() string {
+	return outbox.Mode
+}
+
+func (outbox *OutboxConfig) GetStreamMaxLen() int64 {
+	return outbox.StreamMaxLen
+}
+
+func (outbox *OutboxConfig) GetBatchSize() int {
+	return outbox.BatchSize
+}
+
+func (outbox *OutboxConfig) GetPollInterval() int {
+	return outbox.PollInterval
+}
+
+func (outbox *OutboxConfig) GetMaxAttempts() int {
+	return outbox.MaxAttempts
+}
+
+func (outbox *OutboxConfig) GetRetryInitialInterval() int {
+	return outbox.RetryInitialInterval
+}
+
+func (outbox *OutboxConfig) GetRetryMaxInterval() int {
+	return outbox.RetryMaxInterval
+}
+
+func (outbox *OutboxConfig) GetRetention() int {
+	return outbox.Retention
+}
+
+func (outbox *OutboxConfig) GetCleanupInterval() int {
+	return outbox.CleanupInterval
+}
+
+func (outbox *OutboxConfig) IsAutoCreateTable() bool {
+	return outbox.AutoCreateTable
+}
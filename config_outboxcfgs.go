@@ -0,0 +1,43 @@
+package ihttp
+
+import (
+	"github.com/gitkeng/ihttp/util/convutil"
+	"github.com/gitkeng/ihttp/util/stringutil"
+)
+
+type OutboxConfigs struct {
+	IConfig
+	Outboxes []*OutboxConfig `mapstructure:"outboxes" json:"outboxes"`
+}
+
+func (outbox *OutboxConfigs) Bind() error {
+	for _, outboxConf := range outbox.Outboxes {
+		if err := outboxConf.Bind(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (outbox *OutboxConfigs) Validate() error {
+	checkDuplicate := make(map[string]bool)
+	for _, outboxConf := range outbox.Outboxes {
+		if err := outboxConf.Validate(); err != nil {
+			return err
+		}
+		if _, ok := checkDuplicate[outboxConf.ContextName]; ok {
+			return ErrDuplicateOutboxContextName(outboxConf.ContextName)
+		} else {
+			checkDuplicate[outboxConf.ContextName] = true
+		}
+	}
+	return nil
+}
+
+func (outbox *OutboxConfigs) String() string {
+	return stringutil.Json(*outbox)
+}
+
+func (outbox *OutboxConfigs) ToMap() map[string]any {
+	return convutil.Obj2Map(*outbox)
+}
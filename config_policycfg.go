@@ -0,0 +1,107 @@
+package ihttp
+
+import (
+	"strings"
+
+	"github.com/gitkeng/ihttp/util/convutil"
+	"github.com/gitkeng/ihttp/util/stringutil"
+)
+
+// IPolicyConfig is the configuration interface of the authorization roles
+type IPolicyConfig interface {
+	IConfig
+	// GetRoles return the permissions of each role, the inherited permissions included
+	GetRoles() map[string][]string
+}
+
+// RoleConfig is a role and its "resource:action" permissions
+type RoleConfig struct {
+	Name        string   `mapstructure:"name" json:"name"`
+	Permissions []string `mapstructure:"permissions" json:"permissions"`
+	// Inherits is the roles whose permissions the role also has
+	Inherits []string `mapstructure:"inherits" json:"inherits"`
+}
+
+type PolicyConfig struct {
+	Roles []*RoleConfig `mapstructure:"policy-roles" json:"policy_roles"`
+}
+
+func (policy *PolicyConfig) Bind() error {
+	for _, role := range policy.Roles {
+		role.Name = strings.TrimSpace(role.Name)
+		permissions := make([]string, 0, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			if permission = strings.TrimSpace(permission); permission != "" {
+				permissions = append(permissions, permission)
+			}
+		}
+		role.Permissions = permissions
+	}
+	return nil
+}
+
+func (policy *PolicyConfig) Validate() error {
+	roles := make(map[string]*RoleConfig, len(policy.Roles))
+	for _, role := range policy.Roles {
+		if stringutil.IsEmptyString(role.Name) {
+			return ErrPolicyRoleNameIsRequire
+		}
+		if _, found := roles[role.Name]; found {
+			return ErrDuplicatePolicyRole(role.Name)
+		}
+		roles[role.Name] = role
+		for _, permission := range role.Permissions {
+			if _, err := ParsePermission(permission); err != nil {
+				return err
+			}
+		}
+	}
+	for _, role := range policy.Roles {
+		for _, inherit := range role.Inherits {
+			if _, found := roles[inherit]; !found {
+				return ErrPolicyRoleNotfound(inherit)
+			}
+		}
+	}
+	return nil
+}
+
+func (policy *PolicyConfig) GetRoles() map[string][]string {
+	inherits := make(map[string][]string, len(policy.Roles))
+	permissions := make(map[string][]string, len(policy.Roles))
+	for _, role := range policy.Roles {
+		inherits[role.Name] = role.Inherits
+		permissions[role.Name] = role.Permissions
+	}
+	return expandRoles(permissions, inherits)
+}
+
+func (policy *PolicyConfig) String() string {
+	return stringutil.Json(*policy)
+}
+
+func (policy *PolicyConfig) ToMap() map[string]any {
+	return convutil.Obj2Map(*policy)
+}
+
+// expandRoles add the permissions of the inherited roles, cycles are ignored
+func expandRoles(permissions map[string][]string, inherits map[string][]string) map[string][]string {
+	roles := make(map[string][]string, len(permissions))
+	for role := range permissions {
+		seen := map[string]bool{}
+		pending := []string{role}
+		expanded := make([]string, 0)
+		for len(pending) > 0 {
+			current := pending[0]
+			pending = pending[1:]
+			if seen[current] {
+				continue
+			}
+			seen[current] = true
+			expanded = append(expanded, permissions[current]...)
+			pending = append(pending, inherits[current]...)
+		}
+		roles[role] = expanded
+	}
+	return roles
+}
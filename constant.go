@@ -17,12 +17,30 @@ const (
 
 	// DefaultDBGetRetryLimits is the default timeout for creating db dbConn loop
 	DefaultDBGetRetryLimits int = 30
+	// DefaultDBRetryInitialInterval is the default milliseconds before the first connect retry
+	DefaultDBRetryInitialInterval int = 500
+	// DefaultDBRetryMaxInterval is the default max milliseconds between connect retries
+	DefaultDBRetryMaxInterval int = 10000
+	// DefaultDBRetryMaxElapsedTime is the default seconds spent on connect retries before giving up
+	DefaultDBRetryMaxElapsedTime int = 30
+	// DefaultDBPingTimeout is the default timeout of a connect attempt
+	DefaultDBPingTimeout = 5 * time.Second
+	// DefaultDBSlowQueryThreshold is the default milliseconds a statement must take to be logged as slow query
+	DefaultDBSlowQueryThreshold int = 1000
+	// DefaultDBMaxStatementStats is the max number of distinct statements a store keep metrics for
+	DefaultDBMaxStatementStats int = 1000
 	//DefaultDBConnectionMaxOpenConns 0 (unlimited).
 	DefaultDBConnectionMaxOpenConns int = 0
 	//DefaultDBConnectionMaxIdleConns <= 0, no idle connections are retained. MaxIdleConns will be reduced to match the MaxOpenConns limit.
 	DefaultDBConnectionMaxIdleConns int = 0
 	//DefaultDBConnectionMaxLifeTime <= 0, connections are not closed due to a dbConn's age.
 	DefaultDBConnectionMaxLifeTime int = 0
+	// DefaultDBReplicaHealthCheckInterval is the default seconds between replica pings
+	DefaultDBReplicaHealthCheckInterval int = 10
+	// DefaultSQLiteBusyTimeout is the default milliseconds sqlite wait for a locked database
+	DefaultSQLiteBusyTimeout int = 5000
+	// DefaultDBReplicaEvictionTime is the default seconds a failed replica is not used
+	DefaultDBReplicaEvictionTime int = 30
 
 	//	Default for redis cache setting
 	DefaultRedisCacheDB              int = 0
@@ -35,6 +53,94 @@ const (
 	DefaultRedisCacheReadTimeout         = 3 * time.Second
 	DefaultRedisCachePoolTimeout         = DefaultRedisCacheReadTimeout + time.Second
 	DefaultRedisCacheWriteTimeout        = 3 * time.Second
+
+	//	Default for requester retry and circuit breaker
+	DefaultRequesterRetryMaxAttempts     int = 3
+	DefaultRequesterRetryInitialInterval     = 100 * time.Millisecond
+	DefaultRequesterRetryMaxInterval         = 5 * time.Second
+	DefaultCircuitFailureThreshold       int = 5
+	DefaultCircuitSuccessThreshold       int = 2
+	DefaultCircuitOpenTimeout                = 30 * time.Second
+	DefaultCircuitHalfOpenMaxCalls       int = 1
+	DefaultCircuitIdleTimeout                = 10 * time.Minute
+
+	//	Default for requester connection pool
+	DefaultRequesterMaxIdleConns        int = 100
+	DefaultRequesterMaxIdleConnsPerHost int = 10
+	DefaultRequesterIdleConnTimeout         = 90 * time.Second
+	// DefaultRequesterPoolSize is the max requesters kept by the requester pool of IContext.RequesterWithOptions
+	DefaultRequesterPoolSize int = 256
+	// DefaultRequesterMaxErrorBodySize is the bytes of a streamed error response kept for the status error
+	DefaultRequesterMaxErrorBodySize int64 = 64 << 10
+	// DefaultOAuth2ExpiryDelta refresh the OAuth2 token before it expire
+	DefaultOAuth2ExpiryDelta  = 30 * time.Second
+	DefaultOAuth2TokenTimeout = 10 * time.Second
+	// DefaultHMACMaxSkew is the default accepted clock difference of the signed requests
+	DefaultHMACMaxSkew = 5 * time.Minute
+	// DefaultHMACMaxBodySize is the default max bytes of the signed request body, 10 MB
+	DefaultHMACMaxBodySize int64 = 10 << 20
+	// DefaultJWKSRefreshInterval is the default interval the JWKS document is fetched again
+	DefaultJWKSRefreshInterval = time.Hour
+	// DefaultJWKSMinRefreshInterval limit the JWKS fetches caused by unknown key ids
+	DefaultJWKSMinRefreshInterval = time.Minute
+	DefaultJWKSFetchTimeout       = 10 * time.Second
+	// DefaultAccessTokenTTL is the default lifetime of the issued access tokens
+	DefaultAccessTokenTTL = 15 * time.Minute
+	// DefaultRefreshTokenTTL is the default lifetime of a refresh token family
+	DefaultRefreshTokenTTL       = 30 * 24 * time.Hour
+	DefaultRefreshTokenKeyPrefix = "refresh_token"
+	DefaultRefreshTokenTable     = "refresh_tokens"
+	// DefaultAPIKeyCacheTTL is the default time a verified API key is cached in memory
+	DefaultAPIKeyCacheTTL = time.Minute
+	// DefaultAPIKeyQuotaWindow is the default quota window of the API keys with quota
+	DefaultAPIKeyQuotaWindow    = time.Hour
+	DefaultAPIKeySecretSize     = 32
+	DefaultAPIKeyLookup         = "header:X-API-Key"
+	DefaultAPIKeyHashKey        = "api_keys"
+	DefaultAPIKeyQuotaKeyPrefix = "api_key_quota"
+	DefaultAPIKeyTable          = "api_keys"
+	// DefaultTOTPWindowSize is the default number of 30 seconds time steps accepted around now
+	DefaultTOTPWindowSize = 3
+	// DefaultTOTPSecretSize is the random bytes of a TOTP secret, 20 bytes encode to 32 base32 characters
+	DefaultTOTPSecretSize    = 20
+	DefaultTOTPRecoveryCodes = 10
+	DefaultTOTPMaxAttempts   = 5
+	DefaultTOTPAttemptWindow = 15 * time.Minute
+	DefaultTOTPQRCodeSize    = 256
+	DefaultTOTPKeyPrefix     = "totp"
+	DefaultTOTPHashKey       = "totp_enrollments"
+	DefaultTOTPTable         = "totp_enrollments"
+	// DefaultPolicyCacheTTL is the default lifetime of the authorization policy in redis
+	DefaultPolicyCacheTTL = time.Hour
+	// DefaultPolicyLocalTTL is the default lifetime of the authorization policy in memory
+	DefaultPolicyLocalTTL = 30 * time.Second
+	DefaultPolicyCacheKey = "authz_policy"
+	DefaultPolicyTable    = "role_permissions"
+	// DefaultCSPReportMaxBodySize is the max body read by the CSP report endpoint
+	DefaultCSPReportMaxBodySize = 64 * 1024
+	// DefaultResponseCacheTTL is the default lifetime of a cached response
+	DefaultResponseCacheTTL         = 5 * time.Minute
+	DefaultResponseCacheKeyPrefix   = "httpcache"
+	DefaultResponseCacheMaxBodySize = 1024 * 1024
+	// DefaultClientTimeout is the default milliseconds of each call of a configured client
+	DefaultClientTimeout int = 30000
+
+	//	Default for outbox setting
+	DefaultOutboxTable string = "outbox"
+	// DefaultOutboxBatchSize is the default max events relayed per poll
+	DefaultOutboxBatchSize int = 100
+	// DefaultOutboxPollInterval is the default milliseconds between polls
+	DefaultOutboxPollInterval int = 1000
+	// DefaultOutboxMaxAttempts is the default publish attempts before an event is left as failed
+	DefaultOutboxMaxAttempts int = 10
+	// DefaultOutboxRetryInitialInterval is the default milliseconds before retrying a failed event
+	DefaultOutboxRetryInitialInterval int = 1000
+	// DefaultOutboxRetryMaxInterval is the default max milliseconds between retries of a failed event
+	DefaultOutboxRetryMaxInterval int = 300000
+	// DefaultOutboxRetention is the default seconds delivered events are kept, 7 days
+	DefaultOutboxRetention int = 604800
+	// DefaultOutboxCleanupInterval is the default seconds between deleting delivered events
+	DefaultOutboxCleanupInterval int = 3600
 )
 
 const (
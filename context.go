@@ -1,6 +1,7 @@
 package ihttp
 
 import (
+	"context"
 	"github.com/gitkeng/ihttp/log"
 	"github.com/labstack/echo/v4"
 	"time"
@@ -16,6 +17,8 @@ type IContext interface {
 	ReadRequest() string
 	ReadRequests() []string
 	WebContext() echo.Context
+	// Context return the request context, it carry the database session for read-your-writes
+	Context() context.Context
 	Bind(request any) error
 
 	// Now return current time
@@ -26,9 +29,22 @@ type IContext interface {
 	DB(contextName string) (IDBStore, bool)
 	//Cache return the RedisCache
 	Cache(contextName string) (IRedisCache, bool)
+	//Outbox return the Outbox
+	Outbox(contextName string) (IOutbox, bool)
+
+	//Principal return the caller authenticated by the JWT middleware
+	Principal() (*Principal, bool)
+	//Can report whether the principal may do the action on the resource, see WithAuthorizer
+	Can(action string, resource string) bool
+	//CSPNonce return the Content-Security-Policy nonce of the request for the templates
+	CSPNonce() string
 
 	//Requester return Requester
 	Requester(baseURL string, timeout time.Duration, certFiles ...string) (IRequester, error)
+	//RequesterWithOptions return Requester with retry, circuit breaker and deadline budget
+	RequesterWithOptions(baseURL string, options RequesterOptions) (IRequester, error)
+	//Client return the configured client, it share its connection pool across requests
+	Client(clientContextName string) (IRequester, bool)
 	//Config return config
 
 	//APIConfig return APIConfig
@@ -62,3 +78,19 @@ type IContextLogger interface {
 	Panicf(format string, args ...any)
 	Panicj(message string, key string, j log.JSON)
 }
+
+type requestIDKey struct{}
+
+// WithRequestID return ctx carrying the request id, it is logged with slow queries
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext return the request id carried by ctx or empty string
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
@@ -1,6 +1,7 @@
 package ihttp
 
 import (
+	"context"
 	"fmt"
 	"github.com/gitkeng/ihttp/util/dateutil"
 	"github.com/gitkeng/ihttp/util/stringutil"
@@ -32,6 +33,35 @@ func (ctx *HTTPContext) WebContext() echo.Context {
 	return ctx.ctx
 }
 
+// Context return the request context with a database session,
+// the session is stored back into the request so every handler and middleware share it
+func (ctx *HTTPContext) Context() context.Context {
+	if ctx.ctx == nil {
+		return WithDBSession(context.Background())
+	}
+	req := ctx.ctx.Request()
+	reqCtx := WithDBSession(req.Context())
+	if id := ctx.requestID(); id != "" && RequestIDFromContext(reqCtx) != id {
+		reqCtx = WithRequestID(reqCtx, id)
+	}
+	if reqCtx != req.Context() {
+		ctx.ctx.SetRequest(req.WithContext(reqCtx))
+	}
+	return reqCtx
+}
+
+// requestID return the request id from the request header or the one generated by the middleware
+func (ctx *HTTPContext) requestID() string {
+	if ctx.ctx == nil {
+		return ""
+	}
+	id := ctx.ctx.Request().Header.Get(echo.HeaderXRequestID)
+	if stringutil.IsEmptyString(id) {
+		id = ctx.ctx.Response().Header().Get(echo.HeaderXRequestID)
+	}
+	return id
+}
+
 // Param return parameter by name
 func (ctx *HTTPContext) Param(name string) string {
 	if ctx.ctx != nil {
@@ -93,16 +123,8 @@ func (ctx *HTTPContext) Response(logLevel LogLevel, logTag string, httpStatus in
 		}
 	}
 
-	if ctx.WebContext() != nil && stringutil.IsEmptyString(requestId) {
-		req := ctx.WebContext().Request()
-		res := ctx.WebContext().Response()
-		id := req.Header.Get(echo.HeaderXRequestID)
-		if stringutil.IsEmptyString(id) {
-			id = res.Header().Get(echo.HeaderXRequestID)
-		}
-		if stringutil.IsNotEmptyString(id) {
-			requestId = id
-		}
+	if stringutil.IsEmptyString(requestId) {
+		requestId = ctx.requestID()
 	}
 
 	var response Response
@@ -153,6 +175,13 @@ func (ctx *HTTPContext) DB(dbContextName string) (IDBStore, bool) {
 
 }
 
+func (ctx *HTTPContext) Outbox(outboxContextName string) (IOutbox, bool) {
+	if ctx.ms != nil {
+		return ctx.ms.Outbox(outboxContextName)
+	}
+	return nil, false
+}
+
 func (ctx *HTTPContext) Cache(cacheContextName string) (IRedisCache, bool) {
 	if ctx.ms != nil && len(ctx.ms.redisCaches) > 0 {
 		redis, found := ctx.ms.redisCaches[cacheContextName]
@@ -200,5 +229,36 @@ func (ctx *HTTPContext) RedisConfig(contextName string) (IRedisConfig, bool) {
 
 // Requester return Requester
 func (ctx *HTTPContext) Requester(baseURL string, timeout time.Duration, certFiles ...string) (IRequester, error) {
-	return NewRequester(ctx.ms, baseURL, timeout, certFiles...)
+	return ctx.RequesterWithOptions(baseURL, RequesterOptions{
+		Timeout:   timeout,
+		CertFiles: certFiles,
+	})
+}
+
+// RequesterWithOptions return Requester, the requesters with the same base URL and options
+// share one connection pool for the lifetime of the service
+func (ctx *HTTPContext) RequesterWithOptions(baseURL string, options RequesterOptions) (IRequester, error) {
+	var rqt *Requester
+	var err error
+	if ctx.ms != nil && ctx.ms.requesters != nil {
+		rqt, err = ctx.ms.requesters.get(ctx.ms, baseURL, options)
+	} else {
+		rqt, err = NewRequesterWithOptions(nil, baseURL, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return ctx.bindRequester(rqt), nil
+}
+
+// Client return the configured client bound to the request
+func (ctx *HTTPContext) Client(clientContextName string) (IRequester, bool) {
+	if ctx.ms == nil {
+		return nil, false
+	}
+	rqt, found := ctx.ms.clients[clientContextName]
+	if !found {
+		return nil, false
+	}
+	return ctx.bindRequester(rqt), true
 }
@@ -0,0 +1,75 @@
+package ihttp
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+const (
+	HeaderTraceParent = "Traceparent"
+	HeaderTraceState  = "Tracestate"
+	HeaderBaggage     = "Baggage"
+)
+
+// traceContextHeaders is the W3C trace context headers always forwarded to downstream
+var traceContextHeaders = []string{HeaderTraceParent, HeaderTraceState, HeaderBaggage}
+
+// bindRequester return a copy of the shared requester bound to the request context so the calls are
+// cancelled with the request, and forward the request id, the trace context and the configured headers
+func (ctx *HTTPContext) bindRequester(shared *Requester) *Requester {
+	rqt := shared.clone()
+	rqt.parent = ctx.Context()
+	rqt.logger = ctx
+	if ctx.ctx == nil {
+		return rqt
+	}
+
+	propagateHeaders := rqt.propagateHeaders
+	names := make([]string, 0, len(traceContextHeaders)+len(propagateHeaders))
+	names = append(names, traceContextHeaders...)
+	if ctx.ms != nil && ctx.ms.apiConfig != nil {
+		names = append(names, ctx.ms.apiConfig.GetPropagateHeaders()...)
+	}
+	names = append(names, propagateHeaders...)
+
+	inbound := ctx.ctx.Request().Header
+	for _, name := range names {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		value := inbound.Get(name)
+		if value == "" {
+			continue
+		}
+		if name == HeaderTraceParent {
+			var ok bool
+			if value, ok = childTraceParent(value); !ok {
+				continue
+			}
+		}
+		rqt.headers[name] = value
+	}
+	return rqt
+}
+
+// childTraceParent keep the trace id and flags of the W3C traceparent and replace the parent id
+// with a new span id, it return false when the header is malformed
+func childTraceParent(traceParent string) (string, bool) {
+	parts := strings.Split(strings.TrimSpace(traceParent), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return "", false
+	}
+	if parts[0] == "ff" || parts[1] == strings.Repeat("0", 32) {
+		return "", false
+	}
+	for _, part := range parts[:4] {
+		if _, err := hex.DecodeString(part); err != nil {
+			return "", false
+		}
+	}
+	spanID := make([]byte, 8)
+	if _, err := rand.Read(spanID); err != nil {
+		return "", false
+	}
+	return strings.Join([]string{parts[0], parts[1], hex.EncodeToString(spanID), parts[3]}, "-"), true
+}
@@ -1,82 +1,191 @@
 package ihttp
 
 import (
+	"context"
 	"database/sql"
-	"fmt"
 	"github.com/gitkeng/ihttp/log"
-	"github.com/gitkeng/ihttp/util/stringutil"
 	_ "github.com/go-sql-driver/mysql"
 	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 	"strings"
+	"sync"
 	"time"
 )
 
 type IDBStore interface {
+	IDBExecutor
 	// Conn return dbConn to database
 	Conn() *sql.DB
 	// Close close database dbConn
 	Close() error
 	// Config return database config
 	Config() IDBConfig
+	// Reader return dbConn for read, it is load-balanced across healthy replicas
+	Reader() *sql.DB
+	// Writer return dbConn to the primary database
+	Writer() *sql.DB
+	// BeginTx start a transaction
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*DBTx, error)
+	// Ready return nil when the database is connected and the initial scripts are done,
+	// otherwise the reason it is not ready
+	Ready() error
+	// Stats return the pool statistics and the per-statement latency and error metrics
+	Stats() DBStoreStats
+	// ResetStats clear the per-statement metrics
+	ResetStats()
+}
+
+// IDBExecutor is implemented by IDBStore and DBTx, it is the target of QueryOne, QueryAll and Exec
+type IDBExecutor interface {
+	// Provider return database provider of the executor
+	Provider() string
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// DBTx is the transaction from IDBStore.BeginTx
+type DBTx struct {
+	*sql.Tx
+	provider string
+	metrics  *dbMetrics
+}
+
+func (tx *DBTx) Provider() string {
+	return tx.provider
+}
+
+// QueryContext run the query in the transaction and record its metrics
+func (tx *DBTx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := tx.Tx.QueryContext(ctx, query, args...)
+	tx.metrics.record(ctx, query, len(args), start, err)
+	return rows, err
+}
+
+// ExecContext run the statement in the transaction and record its metrics
+func (tx *DBTx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	result, err := tx.Tx.ExecContext(ctx, query, args...)
+	tx.metrics.record(ctx, query, len(args), start, err)
+	return result, err
 }
 
 type DBStore struct {
-	dbConn *sql.DB
-	config IDBConfig
+	dbConn   *sql.DB
+	config   IDBConfig
+	replicas *dbReplicaSet
+	metrics  *dbMetrics
+	state    dbConnState
+	stop     chan struct{}
+	stopOnce sync.Once
 }
 
+// NewDBStore open the database of cfg, it retry the connect with exponential backoff
+// until RetryLimits attempts or RetryMaxElapsedTime is reached and then run the initial scripts.
+//
+// When cfg is degraded start, a failed first attempt does not return an error,
+// the store keep reconnecting in background and Ready report the error until it succeed.
 func NewDBStore(cfg IDBConfig) (IDBStore, error) {
-	retries := -1
-	for {
-		retries++
-		if retries > cfg.GetRetryLimits()-1 {
-			return nil, fmt.Errorf("create dbConn retry exceed limits")
-		}
-		var conn *sql.DB
-		var err error
-		switch cfg.GetProvider() {
-		case POSTGRES:
-			conn, err = pqOpen(cfg.GetURL(), cfg.GetUser(), cfg.GetPassword(), cfg.GetDatabaseName())
-		case MYSQL:
-			conn, err = mySqlOpen(cfg.GetURL(), cfg.GetUser(), cfg.GetPassword(), cfg.GetDatabaseName())
-		}
+	conn, err := openDBConn(cfg, cfg.GetURL())
+	if err != nil {
+		return nil, err
+	}
+	replicas, err := newDBReplicaSet(cfg)
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	db := &DBStore{
+		dbConn:   conn,
+		config:   cfg,
+		replicas: replicas,
+		metrics:  newDBMetrics(cfg),
+		stop:     make(chan struct{}),
+	}
 
-		if err != nil {
-			log.Warnf("database context name %s connect fail with err %s", cfg.GetContextName(), err.Error())
-			time.Sleep(time.Second * 1)
-			continue
+	if cfg.IsDegradedStart() {
+		if err := db.ping(); err != nil {
+			log.Warnf("database context name %s start in degraded mode, ping fail with err %s", cfg.GetContextName(), err.Error())
+			db.state.set(ErrDBNotReady(cfg.GetContextName(), err))
+			go db.reconnect()
+			return db, nil
 		}
-		if conn != nil {
-			conn.SetConnMaxLifetime(time.Second * time.Duration(cfg.GetConnectionMaxLifeTime()))
-			conn.SetMaxIdleConns(cfg.GetMaxIdleConns())
-			conn.SetMaxOpenConns(cfg.GetMaxOpenConns())
-			//ping for check err
-			err = conn.Ping()
-			if err != nil {
-				log.Warnf("database context name %s ping fail with err %s", cfg.GetContextName(), err.Error())
-				time.Sleep(time.Second * 1)
-				continue
-			}
-		} else {
-			log.Warnf("database context name %s ping fail not found dbConn", cfg.GetContextName())
-			time.Sleep(time.Second * 1)
-			continue
+		if err := db.initialize(); err != nil {
+			db.Close()
+			return nil, err
 		}
+		return db, nil
+	}
 
-		return &DBStore{
-			dbConn: conn,
-			config: cfg,
-		}, nil
+	maxElapsed := time.Second * time.Duration(cfg.GetRetryMaxElapsedTime())
+	if err := db.waitConnect(cfg.GetRetryLimits(), maxElapsed); err != nil {
+		db.Close()
+		return nil, err
+	}
+	if err := db.initialize(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
 
+// openDBConn open the connection pool to the database at host with the pool settings from cfg
+func openDBConn(cfg IDBConfig, host string) (*sql.DB, error) {
+	var conn *sql.DB
+	var err error
+	switch cfg.GetProvider() {
+	case POSTGRES:
+		conn, err = pqOpen(cfg, host)
+	case MYSQL:
+		conn, err = mySqlOpen(cfg, host)
+	case SQLITE:
+		conn, err = sqliteOpen(cfg)
+	default:
+		err = ErrInvalidDBProvider(cfg.GetProvider())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if cfg.GetProvider() == SQLITE && isSQLiteMemory(cfg.GetDatabaseName()) {
+		// every connection to :memory: is a new empty database, keep exactly one connection forever
+		conn.SetConnMaxLifetime(0)
+		conn.SetMaxIdleConns(1)
+		conn.SetMaxOpenConns(1)
+		return conn, nil
 	}
+	conn.SetConnMaxLifetime(time.Second * time.Duration(cfg.GetConnectionMaxLifeTime()))
+	conn.SetMaxIdleConns(cfg.GetMaxIdleConns())
+	conn.SetMaxOpenConns(cfg.GetMaxOpenConns())
+	return conn, nil
 }
 
+// Conn return the primary dbConn, same as Writer
 func (db *DBStore) Conn() *sql.DB {
 	return db.dbConn
 
 }
 
+// Writer return the primary dbConn
+func (db *DBStore) Writer() *sql.DB {
+	return db.dbConn
+}
+
+// Reader return a healthy replica dbConn in round-robin order,
+// it return the primary when there is no replica or all replicas are evicted
+func (db *DBStore) Reader() *sql.DB {
+	if replica := db.replicas.next(); replica != nil {
+		return replica.conn
+	}
+	return db.dbConn
+}
+
 func (db *DBStore) Close() error {
+	db.stopOnce.Do(func() {
+		close(db.stop)
+	})
+	if err := db.replicas.close(); err != nil {
+		log.Warnf("database context name %s close replicas fail with err %s", db.config.GetContextName(), err.Error())
+	}
 	return db.dbConn.Close()
 }
 
@@ -84,27 +193,90 @@ func (db *DBStore) Config() IDBConfig {
 	return db.config
 }
 
-func pqOpen(url string, user string, password string, dbName string) (*sql.DB, error) {
-	if stringutil.IsEmptyString(url) {
-		return nil, ErrDBURLRequire
+func (db *DBStore) Provider() string {
+	return db.config.GetProvider()
+}
+
+// QueryContext run the query on a replica, it use the primary when the ctx session
+// has written to this store and read-your-writes is enabled.
+// The latency is recorded until the rows are returned, not until they are read.
+func (db *DBStore) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	rows, err := db.query(ctx, query, args...)
+	db.metrics.record(ctx, query, len(args), start, err)
+	return rows, err
+}
+
+func (db *DBStore) query(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	replica := db.readReplica(ctx)
+	if replica == nil {
+		return db.dbConn.QueryContext(ctx, query, args...)
+	}
+	rows, err := replica.conn.QueryContext(ctx, query, args...)
+	if err != nil && isDBConnectionError(err) {
+		db.replicas.evict(replica, err)
+		return db.dbConn.QueryContext(ctx, query, args...)
+	}
+	return rows, err
+}
+
+// ExecContext run the statement on the primary and pin the ctx session to the primary
+func (db *DBStore) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	markDBSessionWrote(ctx, db.config.GetContextName())
+	start := time.Now()
+	result, err := db.dbConn.ExecContext(ctx, query, args...)
+	db.metrics.record(ctx, query, len(args), start, err)
+	return result, err
+}
+
+// BeginTx start a transaction on the primary, a read only transaction run on a replica
+func (db *DBStore) BeginTx(ctx context.Context, opts *sql.TxOptions) (*DBTx, error) {
+	var tx *sql.Tx
+	var err error
+	if opts != nil && opts.ReadOnly {
+		tx, err = db.beginReadOnly(ctx, opts)
+	} else {
+		markDBSessionWrote(ctx, db.config.GetContextName())
+		tx, err = db.dbConn.BeginTx(ctx, opts)
 	}
-	if stringutil.IsEmptyString(user) {
-		return nil, ErrDBUserRequire
+	if err != nil {
+		return nil, err
 	}
-	if stringutil.IsEmptyString(password) {
-		return nil, ErrDBPasswordRequire
+	return &DBTx{
+		Tx:       tx,
+		provider: db.config.GetProvider(),
+		metrics:  db.metrics,
+	}, nil
+}
+
+// beginReadOnly start the transaction on a replica unless the ctx session is pinned to the primary
+func (db *DBStore) beginReadOnly(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error) {
+	replica := db.readReplica(ctx)
+	if replica == nil {
+		return db.dbConn.BeginTx(ctx, opts)
 	}
-	if stringutil.IsEmptyString(dbName) {
-		return nil, ErrDBNameRequire
+	tx, err := replica.conn.BeginTx(ctx, opts)
+	if err != nil && isDBConnectionError(err) {
+		db.replicas.evict(replica, err)
+		return db.dbConn.BeginTx(ctx, opts)
 	}
+	return tx, err
+}
 
-	urls := strings.Split(url, ":")
-	if len(urls) != 2 {
-		return nil, ErrDBURLPattern(url)
+// readReplica return the replica for a read, nil when the read must go to the primary
+func (db *DBStore) readReplica(ctx context.Context) *dbReplica {
+	if db.config.IsReadYourWrites() && dbSessionWrote(ctx, db.config.GetContextName()) {
+		return nil
 	}
+	return db.replicas.next()
+}
 
-	urlStr := fmt.Sprintf("postgres://%s:%s@%s/%s?sslmode=disable", user, password, url, dbName)
-	db, err := sql.Open("postgres", urlStr)
+func pqOpen(cfg IDBConfig, host string) (*sql.DB, error) {
+	dsn, err := DataSourceName(cfg, host)
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, err
 	}
@@ -112,25 +284,32 @@ func pqOpen(url string, user string, password string, dbName string) (*sql.DB, e
 
 }
 
-func mySqlOpen(url string, user string, password string, dbName string) (*sql.DB, error) {
-	if stringutil.IsEmptyString(url) {
-		return nil, ErrDBURLRequire
-	}
-	if stringutil.IsEmptyString(user) {
-		return nil, ErrDBUserRequire
-	}
-	if stringutil.IsEmptyString(password) {
-		return nil, ErrDBPasswordRequire
+func mySqlOpen(cfg IDBConfig, host string) (*sql.DB, error) {
+	dsn, err := DataSourceName(cfg, host)
+	if err != nil {
+		return nil, err
 	}
-	if stringutil.IsEmptyString(dbName) {
-		return nil, ErrDBNameRequire
+	db, err := sql.Open("mysql", dsn)
+	if err != nil {
+		return nil, err
 	}
+	return db, nil
+
+}
 
-	urlStr := fmt.Sprintf("%s:%s@tcp(%s)/%s?charset=utf8mb4&parseTime=True&multiStatements=true", user, password, url, dbName)
-	db, err := sql.Open("mysql", urlStr)
+func sqliteOpen(cfg IDBConfig) (*sql.DB, error) {
+	dsn, err := DataSourceName(cfg, "")
+	if err != nil {
+		return nil, err
+	}
+	db, err := sql.Open("sqlite3", dsn)
 	if err != nil {
 		return nil, err
 	}
 	return db, nil
 
 }
+
+func isSQLiteMemory(path string) bool {
+	return strings.TrimSpace(path) == ":memory:"
+}
@@ -0,0 +1,103 @@
+package ihttp
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/gitkeng/ihttp/log"
+	"github.com/gitkeng/ihttp/util/dbutil"
+	"github.com/gitkeng/ihttp/util/retryutil"
+)
+
+// dbConnState hold the reason the store is not ready, nil means ready
+type dbConnState struct {
+	mu  sync.RWMutex
+	err error
+}
+
+func (state *dbConnState) set(err error) {
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	state.err = err
+}
+
+func (state *dbConnState) get() error {
+	state.mu.RLock()
+	defer state.mu.RUnlock()
+	return state.err
+}
+
+// Ready return nil when the database is connected and the initial scripts are done
+func (db *DBStore) Ready() error {
+	return db.state.get()
+}
+
+func (db *DBStore) backoff() *retryutil.Backoff {
+	return retryutil.NewBackoff(
+		time.Millisecond*time.Duration(db.config.GetRetryInitialInterval()),
+		time.Millisecond*time.Duration(db.config.GetRetryMaxInterval()),
+	)
+}
+
+func (db *DBStore) ping() error {
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultDBPingTimeout)
+	defer cancel()
+	return db.dbConn.PingContext(ctx)
+}
+
+// waitConnect ping the database with exponential backoff, maxAttempts or maxElapsed <= 0 mean no limit
+func (db *DBStore) waitConnect(maxAttempts int, maxElapsed time.Duration) error {
+	backoff := db.backoff()
+	start := time.Now()
+	for attempt := 0; ; attempt++ {
+		err := db.ping()
+		if err == nil {
+			return nil
+		}
+		log.Warnf("database context name %s ping attempt %d fail with err %s", db.config.GetContextName(), attempt+1, err.Error())
+		if (maxAttempts > 0 && attempt+1 >= maxAttempts) || (maxElapsed > 0 && time.Since(start) >= maxElapsed) {
+			return ErrDBConnectRetryExceed(db.config.GetContextName(), attempt+1, err)
+		}
+		if !backoff.Sleep(attempt, db.stop) {
+			return ErrDBStoreClosed
+		}
+	}
+}
+
+// reconnect keep connecting in background until it succeed or the store is closed
+func (db *DBStore) reconnect() {
+	if err := db.waitConnect(0, 0); err != nil {
+		if !errors.Is(err, ErrDBStoreClosed) {
+			db.state.set(ErrDBNotReady(db.config.GetContextName(), err))
+		}
+		return
+	}
+	if err := db.initialize(); err != nil {
+		// a failed script is not fixed by retrying, keep the store not ready
+		log.Errorf("database context name %s initialize fail with err %s", db.config.GetContextName(), err.Error())
+		db.state.set(ErrDBNotReady(db.config.GetContextName(), err))
+		return
+	}
+	log.Infof("database context name %s is connected", db.config.GetContextName())
+}
+
+// initialize run the initial scripts one statement at a time and mark the store ready
+func (db *DBStore) initialize() error {
+	dialect := dbutil.Dialect(db.config.GetProvider())
+	for _, initScript := range db.config.GetInitialScripts() {
+		statements, err := dbutil.SQLStatementLoader(initScript, dialect)
+		if err != nil {
+			return err
+		}
+		// run one statement at a time so a failure can be traced back to its line
+		for _, statement := range statements {
+			if _, err := db.dbConn.Exec(statement.SQL); err != nil {
+				return ErrDBInitialScript(statement.File, statement.Line, err)
+			}
+		}
+	}
+	db.state.set(nil)
+	return nil
+}
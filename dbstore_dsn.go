@@ -0,0 +1,259 @@
+package ihttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gitkeng/ihttp/util/stringutil"
+	"github.com/go-sql-driver/mysql"
+)
+
+// DataSourceName return the driver connection string of cfg for host,
+// host is the primary url or one of the replica urls and is ignored by sqlite
+func DataSourceName(cfg IDBConfig, host string) (string, error) {
+	switch cfg.GetProvider() {
+	case POSTGRES:
+		return pqDSN(cfg, host)
+	case MYSQL:
+		return mySqlDSN(cfg, host)
+	case SQLITE:
+		return sqliteDSN(cfg)
+	}
+	return "", ErrInvalidDBProvider(cfg.GetProvider())
+}
+
+func validateDSN(cfg IDBConfig, host string) error {
+	if stringutil.IsEmptyString(host) {
+		return ErrDBURLRequire
+	}
+	if stringutil.IsEmptyString(cfg.GetUser()) {
+		return ErrDBUserRequire
+	}
+	if stringutil.IsEmptyString(cfg.GetPassword()) {
+		return ErrDBPasswordRequire
+	}
+	if stringutil.IsEmptyString(cfg.GetDatabaseName()) {
+		return ErrDBNameRequire
+	}
+	return nil
+}
+
+func pqDSN(cfg IDBConfig, host string) (string, error) {
+	if err := validateDSN(cfg, host); err != nil {
+		return "", err
+	}
+	hosts := strings.Split(host, ":")
+	if len(hosts) != 2 {
+		return "", ErrDBURLPattern(host)
+	}
+
+	params := url.Values{}
+	params.Set("sslmode", cfg.GetSSLMode())
+	if rootCert := cfg.GetSSLRootCert(); rootCert != "" {
+		params.Set("sslrootcert", rootCert)
+	}
+	if cert := cfg.GetSSLCert(); cert != "" {
+		params.Set("sslcert", cert)
+		params.Set("sslkey", cfg.GetSSLKey())
+	}
+	if timeout := cfg.GetConnectTimeout(); timeout > 0 {
+		params.Set("connect_timeout", strconv.Itoa(timeout))
+	}
+	// unknown parameters are sent by lib/pq as run-time parameters
+	if timeout := cfg.GetStatementTimeout(); timeout > 0 {
+		params.Set("statement_timeout", strconv.Itoa(timeout))
+	}
+	if name := cfg.GetApplicationName(); name != "" {
+		params.Set("application_name", name)
+	}
+	if timezone := cfg.GetTimezone(); timezone != "" {
+		params.Set("timezone", timezone)
+	}
+	for key, value := range cfg.GetOptions() {
+		params.Set(key, value)
+	}
+
+	dsn := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(cfg.GetUser(), cfg.GetPassword()),
+		Host:     host,
+		Path:     "/" + cfg.GetDatabaseName(),
+		RawQuery: params.Encode(),
+	}
+	return dsn.String(), nil
+}
+
+func mySqlDSN(cfg IDBConfig, host string) (string, error) {
+	if err := validateDSN(cfg, host); err != nil {
+		return "", err
+	}
+
+	mysqlCfg := mysql.NewConfig()
+	mysqlCfg.User = cfg.GetUser()
+	mysqlCfg.Passwd = cfg.GetPassword()
+	mysqlCfg.Net = "tcp"
+	mysqlCfg.Addr = host
+	mysqlCfg.DBName = cfg.GetDatabaseName()
+	mysqlCfg.ParseTime = true
+	// the initial scripts are split into statements, multiStatements is opt-in through the options
+	mysqlCfg.Params = map[string]string{
+		"charset": "utf8mb4",
+	}
+	if timeout := cfg.GetConnectTimeout(); timeout > 0 {
+		mysqlCfg.Timeout = time.Duration(timeout) * time.Second
+	}
+	if timeout := cfg.GetStatementTimeout(); timeout > 0 {
+		mysqlCfg.Params["max_execution_time"] = strconv.Itoa(timeout)
+	}
+	if timezone := cfg.GetTimezone(); timezone != "" {
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return "", err
+		}
+		mysqlCfg.Loc = loc
+		mysqlCfg.Params["time_zone"] = fmt.Sprintf("'%s'", timezone)
+	}
+	tlsName, err := mySqlTLSConfigName(cfg)
+	if err != nil {
+		return "", err
+	}
+	mysqlCfg.TLSConfig = tlsName
+
+	return appendDSNOptions(mysqlCfg.FormatDSN(), cfg.GetOptions()), nil
+}
+
+func sqliteDSN(cfg IDBConfig) (string, error) {
+	path := cfg.GetDatabaseName()
+	if stringutil.IsEmptyString(path) {
+		return "", ErrDBNameRequire
+	}
+
+	params := url.Values{}
+	params.Set("_foreign_keys", "on")
+	params.Set("_busy_timeout", strconv.Itoa(DefaultSQLiteBusyTimeout))
+	if !isSQLiteMemory(path) {
+		params.Set("_journal_mode", "WAL")
+		params.Set("_synchronous", "NORMAL")
+	}
+	for key, value := range cfg.GetOptions() {
+		params.Set(key, value)
+	}
+	return fmt.Sprintf("file:%s?%s", path, params.Encode()), nil
+}
+
+// appendDSNOptions append the options to the dsn query in key order
+func appendDSNOptions(dsn string, options map[string]string) string {
+	if len(options) == 0 {
+		return dsn
+	}
+	keys := make([]string, 0, len(options))
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var sb strings.Builder
+	sb.WriteString(dsn)
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	for _, key := range keys {
+		sb.WriteString(separator)
+		sb.WriteString(url.QueryEscape(key))
+		sb.WriteString("=")
+		sb.WriteString(url.QueryEscape(options[key]))
+		separator = "&"
+	}
+	return sb.String()
+}
+
+// mySqlTLSConfigName register the tls config of cfg to the mysql driver and return its name
+func mySqlTLSConfigName(cfg IDBConfig) (string, error) {
+	sslMode := cfg.GetSSLMode()
+	if sslMode == DBSSLModeDisable {
+		return "false", nil
+	}
+	if sslMode == DBSSLModeRequire && cfg.GetSSLCert() == "" {
+		return "skip-verify", nil
+	}
+
+	tlsConfig, err := dbTLSConfig(cfg)
+	if err != nil {
+		return "", err
+	}
+	name := "ihttp-" + cfg.GetContextName()
+	if err := mysql.RegisterTLSConfig(name, tlsConfig); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// dbTLSConfig build tls config by ssl mode, it follow postgres sslmode semantic:
+// require encrypt only, verify-ca verify the server certificate chain, verify-full verify the chain and host name
+func dbTLSConfig(cfg IDBConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+	}
+
+	if cert := cfg.GetSSLCert(); cert != "" {
+		clientCert, err := tls.LoadX509KeyPair(cert, cfg.GetSSLKey())
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	rootCAs, err := x509.SystemCertPool()
+	if err != nil {
+		return nil, err
+	}
+	if rootCert := cfg.GetSSLRootCert(); rootCert != "" {
+		certRaw, err := os.ReadFile(rootCert)
+		if err != nil {
+			return nil, err
+		}
+		if ok := rootCAs.AppendCertsFromPEM(certRaw); !ok {
+			return nil, ErrDBSSLRootCertInvalid(rootCert)
+		}
+	}
+	tlsConfig.RootCAs = rootCAs
+
+	switch cfg.GetSSLMode() {
+	case DBSSLModeRequire:
+		tlsConfig.InsecureSkipVerify = true
+	case DBSSLModeVerifyCA:
+		// verify the chain ourselves because the host name check can not be turned off separately
+		tlsConfig.InsecureSkipVerify = true
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			certs := make([]*x509.Certificate, 0, len(rawCerts))
+			for _, raw := range rawCerts {
+				cert, err := x509.ParseCertificate(raw)
+				if err != nil {
+					return err
+				}
+				certs = append(certs, cert)
+			}
+			if len(certs) == 0 {
+				return ErrDBSSLNoServerCert
+			}
+			intermediates := x509.NewCertPool()
+			for _, cert := range certs[1:] {
+				intermediates.AddCert(cert)
+			}
+			_, err := certs[0].Verify(x509.VerifyOptions{
+				Roots:         rootCAs,
+				Intermediates: intermediates,
+			})
+			return err
+		}
+	}
+	return tlsConfig, nil
+}
@@ -0,0 +1,221 @@
+package ihttp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gitkeng/ihttp/log"
+	"github.com/gitkeng/ihttp/util/dbutil"
+	"go.uber.org/zap"
+)
+
+// DBLatencyBuckets is the upper bounds of the statement latency histogram
+var DBLatencyBuckets = []time.Duration{
+	time.Millisecond,
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// DBOtherStatement is the statement key used when the store track DefaultDBMaxStatementStats statements already
+const DBOtherStatement = "<other>"
+
+// DBStoreStats is the snapshot returned by IDBStore.Stats
+type DBStoreStats struct {
+	ContextName string `json:"context_name"`
+	// Pool is the primary connection pool statistics
+	Pool sql.DBStats `json:"pool"`
+	// Replicas is the replica connection pool statistics by replica url
+	Replicas map[string]sql.DBStats `json:"replicas,omitempty"`
+	// Statements is the per-statement metrics sorted by total time, most expensive first
+	Statements []DBStatementStats `json:"statements"`
+}
+
+// DBStatementStats is the metrics of one normalized statement
+type DBStatementStats struct {
+	SQL    string        `json:"sql"`
+	Count  uint64        `json:"count"`
+	Errors uint64        `json:"errors"`
+	Total  time.Duration `json:"total"`
+	Max    time.Duration `json:"max"`
+	// Buckets is the cumulative latency histogram, the last bucket count every call
+	Buckets []DBLatencyBucket `json:"buckets"`
+}
+
+// DBLatencyBucket count the calls that take at most UpperBound, a zero UpperBound is +Inf
+type DBLatencyBucket struct {
+	UpperBound time.Duration `json:"upper_bound"`
+	Count      uint64        `json:"count"`
+}
+
+type dbStatementMetrics struct {
+	count   uint64
+	errors  uint64
+	total   time.Duration
+	max     time.Duration
+	buckets []uint64
+}
+
+// dbMetrics record latency and errors of the statements run through a DBStore
+type dbMetrics struct {
+	contextName   string
+	dialect       dbutil.Dialect
+	slowThreshold time.Duration
+	mutex         sync.Mutex
+	statements    map[string]*dbStatementMetrics
+	// normalized cache the normalized form of raw statements, it is bounded by the same limit
+	normalized map[string]string
+}
+
+func newDBMetrics(cfg IDBConfig) *dbMetrics {
+	return &dbMetrics{
+		contextName:   cfg.GetContextName(),
+		dialect:       dbutil.Dialect(cfg.GetProvider()),
+		slowThreshold: time.Millisecond * time.Duration(cfg.GetSlowQueryThreshold()),
+		statements:    make(map[string]*dbStatementMetrics),
+		normalized:    make(map[string]string),
+	}
+}
+
+// record add the call to the statement metrics and log it when it is slower than the threshold,
+// the arguments are never logged, only their count
+func (metrics *dbMetrics) record(ctx context.Context, query string, argCount int, start time.Time, err error) {
+	if metrics == nil {
+		return
+	}
+	elapsed := time.Since(start)
+	failed := err != nil && !errors.Is(err, sql.ErrNoRows)
+
+	metrics.mutex.Lock()
+	statement, ok := metrics.normalized[query]
+	if !ok {
+		statement = dbutil.NormalizeSQL(query, metrics.dialect)
+		if len(metrics.normalized) < DefaultDBMaxStatementStats {
+			metrics.normalized[query] = statement
+		}
+	}
+	stats, ok := metrics.statements[statement]
+	if !ok {
+		if len(metrics.statements) >= DefaultDBMaxStatementStats {
+			statement = DBOtherStatement
+			stats = metrics.statements[statement]
+		}
+		if stats == nil {
+			stats = &dbStatementMetrics{buckets: make([]uint64, len(DBLatencyBuckets)+1)}
+			metrics.statements[statement] = stats
+		}
+	}
+	stats.count++
+	if failed {
+		stats.errors++
+	}
+	stats.total += elapsed
+	if elapsed > stats.max {
+		stats.max = elapsed
+	}
+	bucket := sort.Search(len(DBLatencyBuckets), func(idx int) bool {
+		return elapsed <= DBLatencyBuckets[idx]
+	})
+	stats.buckets[bucket]++
+	metrics.mutex.Unlock()
+
+	if metrics.slowThreshold > 0 && elapsed >= metrics.slowThreshold {
+		fields := []zap.Field{
+			zap.String("db_context_name", metrics.contextName),
+			zap.String("sql", statement),
+			zap.Int("args", argCount),
+			zap.Duration("duration", elapsed),
+		}
+		if id := RequestIDFromContext(ctx); id != "" {
+			fields = append(fields, zap.String(RequestIdField, id))
+		}
+		if err != nil {
+			fields = append(fields, zap.Error(err))
+		}
+		// the global logger is the microservice logger once New has run
+		log.Warn("slow query", fields...)
+	}
+}
+
+func (metrics *dbMetrics) snapshot() []DBStatementStats {
+	if metrics == nil {
+		return nil
+	}
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+
+	result := make([]DBStatementStats, 0, len(metrics.statements))
+	for statement, stats := range metrics.statements {
+		buckets := make([]DBLatencyBucket, 0, len(stats.buckets))
+		var cumulative uint64
+		for idx, count := range stats.buckets {
+			cumulative += count
+			var upperBound time.Duration
+			if idx < len(DBLatencyBuckets) {
+				upperBound = DBLatencyBuckets[idx]
+			}
+			buckets = append(buckets, DBLatencyBucket{
+				UpperBound: upperBound,
+				Count:      cumulative,
+			})
+		}
+		result = append(result, DBStatementStats{
+			SQL:     statement,
+			Count:   stats.count,
+			Errors:  stats.errors,
+			Total:   stats.total,
+			Max:     stats.max,
+			Buckets: buckets,
+		})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Total == result[j].Total {
+			return result[i].SQL < result[j].SQL
+		}
+		return result[i].Total > result[j].Total
+	})
+	return result
+}
+
+func (metrics *dbMetrics) reset() {
+	if metrics == nil {
+		return
+	}
+	metrics.mutex.Lock()
+	defer metrics.mutex.Unlock()
+	metrics.statements = make(map[string]*dbStatementMetrics)
+	metrics.normalized = make(map[string]string)
+}
+
+// Stats return the pool statistics and the per-statement metrics of the store
+func (db *DBStore) Stats() DBStoreStats {
+	stats := DBStoreStats{
+		ContextName: db.config.GetContextName(),
+		Pool:        db.dbConn.Stats(),
+		Statements:  db.metrics.snapshot(),
+	}
+	if db.replicas != nil && len(db.replicas.replicas) > 0 {
+		stats.Replicas = make(map[string]sql.DBStats, len(db.replicas.replicas))
+		for _, replica := range db.replicas.replicas {
+			stats.Replicas[replica.url] = replica.conn.Stats()
+		}
+	}
+	return stats
+}
+
+// ResetStats clear the per-statement metrics, the pool statistics are kept by database/sql
+func (db *DBStore) ResetStats() {
+	db.metrics.reset()
+}
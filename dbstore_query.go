@@ -0,0 +1,371 @@
+package ihttp
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gitkeng/ihttp/util/stringutil"
+)
+
+// QueryOne run the query and scan the first row into T, it return sql.ErrNoRows when no row found.
+//
+// arg bind the :name parameters of the query, it is a struct or a map with string keys, nil for no parameters.
+// T is a struct mapped by `db` tag with snake_case fallback, or a single column value such as int or string.
+func QueryOne[T any](ctx context.Context, db IDBExecutor, query string, arg any) (T, error) {
+	var result T
+	rows, err := queryNamed(ctx, db, query, arg)
+	if err != nil {
+		return result, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return result, err
+		}
+		return result, sql.ErrNoRows
+	}
+	columns, err := rows.Columns()
+	if err != nil {
+		return result, err
+	}
+	if err := scanRow(rows, columns, reflect.ValueOf(&result).Elem()); err != nil {
+		return result, err
+	}
+	return result, rows.Err()
+}
+
+// QueryAll run the query and scan every row into T, see QueryOne for arg and T
+func QueryAll[T any](ctx context.Context, db IDBExecutor, query string, arg any) ([]T, error) {
+	rows, err := queryNamed(ctx, db, query, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	results := make([]T, 0)
+	for rows.Next() {
+		var result T
+		if err := scanRow(rows, columns, reflect.ValueOf(&result).Elem()); err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	return results, rows.Err()
+}
+
+// Exec run the statement with :name parameters bound from arg, see QueryOne for arg
+func Exec(ctx context.Context, db IDBExecutor, query string, arg any) (sql.Result, error) {
+	statement, args, err := BindNamed(db.Provider(), query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return db.ExecContext(ctx, statement, args...)
+}
+
+// BindNamed replace :name parameters with the provider placeholders ($n or ?)
+// and return the arguments in placeholder order
+func BindNamed(provider string, query string, arg any) (string, []any, error) {
+	names, statement := parseNamedQuery(query, provider)
+	if len(names) == 0 {
+		return statement, nil, nil
+	}
+	lookup, err := namedArgLookup(arg)
+	if err != nil {
+		return "", nil, err
+	}
+	args := make([]any, 0, len(names))
+	for _, name := range names {
+		value, ok := lookup(name)
+		if !ok {
+			return "", nil, ErrDBNamedParamNotfound(name)
+		}
+		args = append(args, value)
+	}
+	return statement, args, nil
+}
+
+func queryNamed(ctx context.Context, db IDBExecutor, query string, arg any) (*sql.Rows, error) {
+	statement, args, err := BindNamed(db.Provider(), query, arg)
+	if err != nil {
+		return nil, err
+	}
+	return db.QueryContext(ctx, statement, args...)
+}
+
+// parseNamedQuery return the parameter names and the rewritten query, quoted strings and identifiers,
+// line and block comments, postgres dollar-quoted bodies and :: casts are left untouched
+func parseNamedQuery(query string, provider string) ([]string, string) {
+	var sb strings.Builder
+	names := make([]string, 0)
+	for pos := 0; pos < len(query); {
+		c := query[pos]
+		end := pos
+		switch {
+		case c == '\'' || c == '"' || c == '`':
+			end = skipQuotedSQL(query, pos, provider == MYSQL && c != '`')
+		case strings.HasPrefix(query[pos:], "--") || (c == '#' && provider == MYSQL):
+			end = strings.IndexByte(query[pos:], '\n')
+			if end < 0 {
+				end = len(query)
+			} else {
+				end += pos
+			}
+		case strings.HasPrefix(query[pos:], "/*"):
+			end = skipBlockCommentSQL(query, pos, provider == POSTGRES)
+		case c == '$' && provider == POSTGRES:
+			if tag, ok := dollarQuoteTag(query, pos); ok {
+				if close := strings.Index(query[pos+len(tag):], tag); close < 0 {
+					end = len(query)
+				} else {
+					end = pos + len(tag) + close + len(tag)
+				}
+			}
+		case strings.HasPrefix(query[pos:], "::"):
+			end = pos + 2
+		case c == ':' && pos+1 < len(query) && isNameStart(query[pos+1]):
+			end = pos + 1
+			for end < len(query) && isNameChar(query[end]) {
+				end++
+			}
+			names = append(names, query[pos+1:end])
+			if provider == POSTGRES {
+				sb.WriteString("$")
+				sb.WriteString(strconv.Itoa(len(names)))
+			} else {
+				sb.WriteString("?")
+			}
+			pos = end
+			continue
+		}
+		if end == pos {
+			end = pos + 1
+		}
+		sb.WriteString(query[pos:end])
+		pos = end
+	}
+	return names, sb.String()
+}
+
+// skipQuotedSQL return the position after the quoted span at pos, a doubled quote is an escaped quote
+func skipQuotedSQL(query string, pos int, backslash bool) int {
+	quote := query[pos]
+	for end := pos + 1; end < len(query); end++ {
+		switch {
+		case backslash && query[end] == '\\':
+			end++
+		case query[end] == quote:
+			if end+1 < len(query) && query[end+1] == quote {
+				end++
+				continue
+			}
+			return end + 1
+		}
+	}
+	return len(query)
+}
+
+// skipBlockCommentSQL return the position after the block comment at pos, only postgres nest them
+func skipBlockCommentSQL(query string, pos int, nested bool) int {
+	depth := 0
+	for end := pos; end < len(query); {
+		switch {
+		case strings.HasPrefix(query[end:], "/*"):
+			depth++
+			end += 2
+		case strings.HasPrefix(query[end:], "*/"):
+			depth--
+			end += 2
+			if depth == 0 || !nested {
+				return end
+			}
+		default:
+			end++
+		}
+	}
+	return len(query)
+}
+
+// dollarQuoteTag return the postgres dollar quote tag ($$ or $tag$) at pos
+func dollarQuoteTag(query string, pos int) (string, bool) {
+	if pos > 0 && isNameChar(query[pos-1]) {
+		return "", false
+	}
+	for end := pos + 1; end < len(query); end++ {
+		c := query[end]
+		if c == '$' {
+			return query[pos : end+1], true
+		}
+		if !isNameChar(c) || (end == pos+1 && c >= '0' && c <= '9') {
+			return "", false
+		}
+	}
+	return "", false
+}
+
+func isNameStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isNameChar(c byte) bool {
+	return isNameStart(c) || (c >= '0' && c <= '9')
+}
+
+func namedArgLookup(arg any) (func(name string) (any, bool), error) {
+	rv := reflect.ValueOf(arg)
+	for rv.IsValid() && rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			return nil, ErrDBNamedArgInvalid(arg)
+		}
+		rv = rv.Elem()
+	}
+	if !rv.IsValid() {
+		return nil, ErrDBNamedArgInvalid(arg)
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String {
+			return nil, ErrDBNamedArgInvalid(arg)
+		}
+		return func(name string) (any, bool) {
+			value := rv.MapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()))
+			if !value.IsValid() {
+				return nil, false
+			}
+			return value.Interface(), true
+		}, nil
+	case reflect.Struct:
+		fields := structFields(rv.Type())
+		return func(name string) (any, bool) {
+			index, ok := fields[strings.ToLower(name)]
+			if !ok {
+				return nil, false
+			}
+			value, ok := fieldByIndex(rv, index, false)
+			if !ok {
+				// nil embedded pointer
+				return nil, true
+			}
+			return value.Interface(), true
+		}, nil
+	}
+	return nil, ErrDBNamedArgInvalid(arg)
+}
+
+func scanRow(rows *sql.Rows, columns []string, target reflect.Value) error {
+	if target.Kind() == reflect.Pointer && isMappedStruct(target.Type().Elem()) {
+		target.Set(reflect.New(target.Type().Elem()))
+		target = target.Elem()
+	}
+	if !isMappedStruct(target.Type()) {
+		return rows.Scan(target.Addr().Interface())
+	}
+
+	fields := structFields(target.Type())
+	dests := make([]any, len(columns))
+	for idx, column := range columns {
+		index, ok := fields[strings.ToLower(column)]
+		if !ok {
+			// column without destination field is discarded
+			dests[idx] = new(any)
+			continue
+		}
+		field, _ := fieldByIndex(target, index, true)
+		dests[idx] = field.Addr().Interface()
+	}
+	return rows.Scan(dests...)
+}
+
+var (
+	scannerType  = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+	timeType     = reflect.TypeOf(time.Time{})
+	fieldsCache  = sync.Map{}
+	fieldsTagKey = "db"
+)
+
+// isMappedStruct report whether t is scanned field by field rather than as a single value
+func isMappedStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct &&
+		t != timeType &&
+		!reflect.PointerTo(t).Implements(scannerType)
+}
+
+// structFields return the column name to field index mapping of struct type t
+func structFields(t reflect.Type) map[string][]int {
+	if cached, ok := fieldsCache.Load(t); ok {
+		return cached.(map[string][]int)
+	}
+	fields := make(map[string][]int)
+	collectFields(t, nil, fields)
+	fieldsCache.Store(t, fields)
+	return fields
+}
+
+func collectFields(t reflect.Type, parent []int, fields map[string][]int) {
+	embedded := make([][]int, 0)
+	for idx := 0; idx < t.NumField(); idx++ {
+		field := t.Field(idx)
+		tag := field.Tag.Get(fieldsTagKey)
+		if tag == "-" {
+			continue
+		}
+		index := append(append(make([]int, 0, len(parent)+1), parent...), idx)
+
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && tag == "" && isMappedStruct(fieldType) {
+			embedded = append(embedded, index)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if name == "" {
+			name = stringutil.ToSnake(field.Name)
+		}
+		name = strings.ToLower(name)
+		if _, found := fields[name]; !found {
+			fields[name] = index
+		}
+	}
+
+	// embedded fields are collected after the outer fields so the outer fields win
+	for _, index := range embedded {
+		fieldType := t.Field(index[len(index)-1]).Type
+		if fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		collectFields(fieldType, index, fields)
+	}
+}
+
+// fieldByIndex walk the index path, nil embedded pointers are allocated when alloc is true
+func fieldByIndex(v reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	for pos, idx := range index {
+		if pos > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(idx)
+	}
+	return v, true
+}
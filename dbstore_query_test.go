@@ -0,0 +1,171 @@
+package ihttp_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/gitkeng/ihttp"
+)
+
+type auditFields struct {
+	CreatedBy string
+	UpdatedBy *string
+}
+
+type orderRow struct {
+	auditFields
+	ID       int64 `db:"order_id"`
+	Customer string
+	Remark   sql.NullString
+	Secret   string `db:"-"`
+}
+
+func TestBindNamedStruct(t *testing.T) {
+	arg := orderRow{
+		auditFields: auditFields{CreatedBy: "admin"},
+		ID:          10,
+		Customer:    "somchai",
+	}
+	query := "SELECT id::text, ':skip' FROM orders WHERE order_id = :order_id AND customer = :customer AND created_by = :created_by OR order_id = :order_id"
+
+	statement, args, err := ihttp.BindNamed(ihttp.POSTGRES, query, arg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expect := "SELECT id::text, ':skip' FROM orders WHERE order_id = $1 AND customer = $2 AND created_by = $3 OR order_id = $4"
+	if statement != expect {
+		t.Errorf("got %s want %s", statement, expect)
+	}
+	if len(args) != 4 || args[0] != int64(10) || args[1] != "somchai" || args[2] != "admin" {
+		t.Errorf("unexpected args %v", args)
+	}
+
+	if _, _, err := ihttp.BindNamed(ihttp.POSTGRES, "SELECT :secret", arg); err == nil {
+		t.Errorf("expect error for db:\"-\" field")
+	}
+}
+
+func TestBindNamedMap(t *testing.T) {
+	arg := map[string]any{
+		"name":   "somchai",
+		"status": "active",
+	}
+	statement, args, err := ihttp.BindNamed(ihttp.MYSQL, "UPDATE users SET status = :status WHERE name = :name", arg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expect := "UPDATE users SET status = ? WHERE name = ?"
+	if statement != expect {
+		t.Errorf("got %s want %s", statement, expect)
+	}
+	if len(args) != 2 || args[0] != "active" || args[1] != "somchai" {
+		t.Errorf("unexpected args %v", args)
+	}
+
+	if _, _, err := ihttp.BindNamed(ihttp.MYSQL, "SELECT :missing", arg); err == nil {
+		t.Errorf("expect error for missing parameter")
+	}
+}
+
+func TestBindNamedSkipComments(t *testing.T) {
+	arg := map[string]any{"id": 1}
+	query := "SELECT /* :skip 'it''s */ $$ :body $$, $fn$ :fn $fn$, 'a'':b' -- :line\nFROM orders /* outer /* :inner */ :still */ WHERE id = :id"
+
+	statement, args, err := ihttp.BindNamed(ihttp.POSTGRES, query, arg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	expect := "SELECT /* :skip 'it''s */ $$ :body $$, $fn$ :fn $fn$, 'a'':b' -- :line\nFROM orders /* outer /* :inner */ :still */ WHERE id = $1"
+	if statement != expect {
+		t.Errorf("got %s want %s", statement, expect)
+	}
+	if len(args) != 1 || args[0] != 1 {
+		t.Errorf("unexpected args %v", args)
+	}
+
+	statement, _, err = ihttp.BindNamed(ihttp.MYSQL, "SELECT 'a\\':b' # :skip\nFROM orders WHERE id = :id", arg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if expect := "SELECT 'a\\':b' # :skip\nFROM orders WHERE id = ?"; statement != expect {
+		t.Errorf("got %s want %s", statement, expect)
+	}
+}
+
+type queryOrder struct {
+	ID       int64 `db:"order_id"`
+	Customer string
+	Remark   sql.NullString
+}
+
+func TestQueryNamedSQLite(t *testing.T) {
+	store, err := ihttp.NewDBStore(&ihttp.DBConfig{
+		ContextName:  "query",
+		Provider:     ihttp.SQLITE,
+		DatabaseName: ":memory:",
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if _, err := ihttp.Exec(ctx, store, "CREATE TABLE orders (order_id INTEGER PRIMARY KEY, customer TEXT NOT NULL, remark TEXT)", nil); err != nil {
+		t.Error(err)
+		return
+	}
+	result, err := ihttp.Exec(ctx, store, "INSERT INTO orders (order_id, customer) VALUES (:order_id, :customer)", queryOrder{ID: 1, Customer: "somchai"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if affected, _ := result.RowsAffected(); affected != 1 {
+		t.Errorf("got %d rows affected want 1", affected)
+	}
+	if _, err := ihttp.Exec(ctx, store, "INSERT INTO orders (order_id, customer, remark) VALUES (:id, :customer, :remark)", map[string]any{"id": 2, "customer": "somsri", "remark": "vip"}); err != nil {
+		t.Error(err)
+		return
+	}
+
+	order, err := ihttp.QueryOne[queryOrder](ctx, store, "SELECT * FROM orders WHERE customer = :customer", map[string]any{"customer": "somsri"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if order.ID != 2 || order.Customer != "somsri" || order.Remark.String != "vip" {
+		t.Errorf("unexpected order %+v", order)
+	}
+
+	orders, err := ihttp.QueryAll[*queryOrder](ctx, store, "SELECT order_id, customer, remark FROM orders /* :skip */ WHERE order_id >= :order_id ORDER BY order_id", queryOrder{ID: 1})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if len(orders) != 2 || orders[0].Customer != "somchai" || orders[0].Remark.Valid || orders[1].ID != 2 {
+		t.Errorf("unexpected orders %+v", orders)
+	}
+
+	count, err := ihttp.QueryOne[int](ctx, store, "SELECT COUNT(*) FROM orders", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if count != 2 {
+		t.Errorf("got %d orders want 2", count)
+	}
+
+	if _, err := ihttp.QueryOne[queryOrder](ctx, store, "SELECT * FROM orders WHERE order_id = :id", map[string]any{"id": 3}); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("got %v want sql.ErrNoRows", err)
+	}
+	empty, err := ihttp.QueryAll[queryOrder](ctx, store, "SELECT * FROM orders WHERE order_id = :id", map[string]any{"id": 3})
+	if err != nil || len(empty) != 0 {
+		t.Errorf("got %v %v want no orders", empty, err)
+	}
+}
@@ -0,0 +1,169 @@
+package ihttp
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gitkeng/ihttp/log"
+)
+
+type dbReplica struct {
+	url  string
+	conn *sql.DB
+	// evictedUntil is unix nano time, the replica is not used before it
+	evictedUntil atomic.Int64
+}
+
+// dbReplicaSet load-balance reads across replicas and evict the failed ones
+type dbReplicaSet struct {
+	contextName   string
+	replicas      []*dbReplica
+	counter       atomic.Uint32
+	evictDuration time.Duration
+	stop          chan struct{}
+	stopOnce      sync.Once
+}
+
+func newDBReplicaSet(cfg IDBConfig) (*dbReplicaSet, error) {
+	urls := cfg.GetReplicaURLs()
+	if len(urls) == 0 {
+		return nil, nil
+	}
+	set := &dbReplicaSet{
+		contextName:   cfg.GetContextName(),
+		replicas:      make([]*dbReplica, 0, len(urls)),
+		evictDuration: time.Second * time.Duration(cfg.GetReplicaEvictionTime()),
+		stop:          make(chan struct{}),
+	}
+	for _, url := range urls {
+		conn, err := openDBConn(cfg, url)
+		if err != nil {
+			set.close()
+			return nil, err
+		}
+		replica := &dbReplica{
+			url:  url,
+			conn: conn,
+		}
+		// an unreachable replica does not stop the service, it is evicted until it recover
+		if err := conn.Ping(); err != nil {
+			set.evict(replica, err)
+		}
+		set.replicas = append(set.replicas, replica)
+	}
+
+	go set.healthCheck(time.Second * time.Duration(cfg.GetReplicaHealthCheckInterval()))
+	return set, nil
+}
+
+// next return the next healthy replica or nil
+func (set *dbReplicaSet) next() *dbReplica {
+	if set == nil || len(set.replicas) == 0 {
+		return nil
+	}
+	now := time.Now().UnixNano()
+	start := int(set.counter.Add(1))
+	for idx := 0; idx < len(set.replicas); idx++ {
+		replica := set.replicas[(start+idx)%len(set.replicas)]
+		if replica.evictedUntil.Load() <= now {
+			return replica
+		}
+	}
+	return nil
+}
+
+func (set *dbReplicaSet) evict(replica *dbReplica, err error) {
+	replica.evictedUntil.Store(time.Now().Add(set.evictDuration).UnixNano())
+	log.Warnf("database context name %s replica %s evicted for %s with err %s",
+		set.contextName, replica.url, set.evictDuration, err.Error())
+}
+
+func (set *dbReplicaSet) healthCheck(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-set.stop:
+			return
+		case <-ticker.C:
+			for _, replica := range set.replicas {
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				err := replica.conn.PingContext(ctx)
+				cancel()
+
+				evictedUntil := replica.evictedUntil.Load()
+				if err != nil {
+					set.evict(replica, err)
+				} else if evictedUntil > 0 && evictedUntil <= time.Now().UnixNano() {
+					replica.evictedUntil.Store(0)
+					log.Infof("database context name %s replica %s is back", set.contextName, replica.url)
+				}
+			}
+		}
+	}
+}
+
+func (set *dbReplicaSet) close() error {
+	if set == nil {
+		return nil
+	}
+	set.stopOnce.Do(func() {
+		close(set.stop)
+	})
+	var errs []error
+	for _, replica := range set.replicas {
+		if err := replica.conn.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// isDBConnectionError report whether err is a broken connection rather than a query error
+func isDBConnectionError(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+type dbSessionKey struct{}
+
+// dbSession remember which database context names are written within a request
+type dbSession struct {
+	mutex sync.Mutex
+	wrote map[string]bool
+}
+
+// WithDBSession return ctx carrying a session for read-your-writes,
+// after a write to a store every read of that store within ctx go to the primary
+func WithDBSession(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(dbSessionKey{}).(*dbSession); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, dbSessionKey{}, &dbSession{wrote: make(map[string]bool)})
+}
+
+func markDBSessionWrote(ctx context.Context, contextName string) {
+	if session, ok := ctx.Value(dbSessionKey{}).(*dbSession); ok {
+		session.mutex.Lock()
+		session.wrote[contextName] = true
+		session.mutex.Unlock()
+	}
+}
+
+func dbSessionWrote(ctx context.Context, contextName string) bool {
+	if session, ok := ctx.Value(dbSessionKey{}).(*dbSession); ok {
+		session.mutex.Lock()
+		defer session.mutex.Unlock()
+		return session.wrote[contextName]
+	}
+	return false
+}
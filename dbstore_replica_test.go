@@ -0,0 +1,194 @@
+package ihttp
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// badConnDriver is a stub replica driver whose connections are always broken
+type badConnDriver struct{}
+
+func (badConnDriver) Open(string) (driver.Conn, error) {
+	return nil, driver.ErrBadConn
+}
+
+func init() {
+	sql.Register("ihttp_badconn", badConnDriver{})
+}
+
+// openItemsDB open a sqlite file with a single items row named name
+func openItemsDB(t *testing.T, name string) *sql.DB {
+	t.Helper()
+	conn, err := sql.Open("sqlite3", filepath.Join(t.TempDir(), name+".db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		conn.Close()
+	})
+	if _, err := conn.Exec("CREATE TABLE items (name TEXT)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Exec("INSERT INTO items (name) VALUES (?)", name); err != nil {
+		t.Fatal(err)
+	}
+	return conn
+}
+
+// newReplicaDBStore return the store of primary with a stub replica set over conns,
+// sqlite itself does not support replicas so the set is built directly
+func newReplicaDBStore(primary *sql.DB, conns ...*sql.DB) *DBStore {
+	cfg := &DBConfig{
+		ContextName:    "local",
+		Provider:       SQLITE,
+		ReadYourWrites: true,
+	}
+	set := &dbReplicaSet{
+		contextName:   cfg.ContextName,
+		evictDuration: time.Minute,
+		stop:          make(chan struct{}),
+	}
+	for _, conn := range conns {
+		set.replicas = append(set.replicas, &dbReplica{url: "stub", conn: conn})
+	}
+	return &DBStore{
+		dbConn:   primary,
+		config:   cfg,
+		replicas: set,
+		metrics:  newDBMetrics(cfg),
+		stop:     make(chan struct{}),
+	}
+}
+
+// firstItemName return the name of the first items row read with query
+func firstItemName(t *testing.T, ctx context.Context, store IDBStore) string {
+	t.Helper()
+	name, err := QueryOne[string](ctx, store, "SELECT name FROM items ORDER BY rowid LIMIT 1", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+// readOnlyTxName return the first items row read in a read-only transaction
+func readOnlyTxName(t *testing.T, ctx context.Context, store IDBStore) string {
+	t.Helper()
+	tx, err := store.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+	name := ""
+	if err := tx.QueryRowContext(ctx, "SELECT name FROM items ORDER BY rowid LIMIT 1").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	return name
+}
+
+func TestDBStoreReplicaRouting(t *testing.T) {
+	store := newReplicaDBStore(openItemsDB(t, "primary"), openItemsDB(t, "replica"))
+	ctx := context.Background()
+
+	if store.Reader() == store.Writer() {
+		t.Errorf("expect the reader to be the replica")
+	}
+	if name := firstItemName(t, ctx, store); name != "replica" {
+		t.Errorf("expect read from replica got %s", name)
+	}
+	if name := readOnlyTxName(t, ctx, store); name != "replica" {
+		t.Errorf("expect read-only transaction from replica got %s", name)
+	}
+
+	if _, err := store.ExecContext(ctx, "INSERT INTO items (name) VALUES (?)", "written"); err != nil {
+		t.Fatal(err)
+	}
+	written := 0
+	if err := store.Writer().QueryRow("SELECT COUNT(*) FROM items WHERE name = 'written'").Scan(&written); err != nil || written != 1 {
+		t.Errorf("expect the write on the primary got %d %v", written, err)
+	}
+	if err := store.Reader().QueryRow("SELECT COUNT(*) FROM items WHERE name = 'written'").Scan(&written); err != nil || written != 0 {
+		t.Errorf("expect no write on the replica got %d %v", written, err)
+	}
+
+	tx, err := store.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := ""
+	if err := tx.QueryRowContext(ctx, "SELECT name FROM items ORDER BY rowid LIMIT 1").Scan(&name); err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback()
+	if name != "primary" {
+		t.Errorf("expect read in transaction from primary got %s", name)
+	}
+}
+
+func TestDBStoreReplicaReadYourWrites(t *testing.T) {
+	store := newReplicaDBStore(openItemsDB(t, "primary"), openItemsDB(t, "replica"))
+	session := WithDBSession(context.Background())
+
+	if name := firstItemName(t, session, store); name != "replica" {
+		t.Errorf("expect read before write from replica got %s", name)
+	}
+	tx, err := store.BeginTx(session, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback()
+	if name := firstItemName(t, session, store); name != "primary" {
+		t.Errorf("expect read after transaction from primary got %s", name)
+	}
+	if name := readOnlyTxName(t, session, store); name != "primary" {
+		t.Errorf("expect read-only transaction after write from primary got %s", name)
+	}
+
+	// another session and a store without read-your-writes keep reading the replica
+	if name := firstItemName(t, WithDBSession(context.Background()), store); name != "replica" {
+		t.Errorf("expect read in another session from replica got %s", name)
+	}
+	store.config.(*DBConfig).ReadYourWrites = false
+	if name := firstItemName(t, session, store); name != "replica" {
+		t.Errorf("expect read without read-your-writes from replica got %s", name)
+	}
+}
+
+func TestDBStoreReplicaFallback(t *testing.T) {
+	broken, err := sql.Open("ihttp_badconn", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer broken.Close()
+	store := newReplicaDBStore(openItemsDB(t, "primary"), broken)
+	ctx := context.Background()
+
+	// the broken replica is evicted on the first read and the read is retried on the primary
+	if name := firstItemName(t, ctx, store); name != "primary" {
+		t.Errorf("expect read from primary got %s", name)
+	}
+	if store.Reader() != store.Writer() {
+		t.Errorf("expect the reader to fall back to the primary")
+	}
+	if name := readOnlyTxName(t, ctx, store); name != "primary" {
+		t.Errorf("expect read-only transaction from primary got %s", name)
+	}
+
+	store.replicas.replicas[0].evictedUntil.Store(0)
+	if name := readOnlyTxName(t, ctx, store); name != "primary" {
+		t.Errorf("expect read-only transaction to fall back to primary got %s", name)
+	}
+	if store.replicas.next() != nil {
+		t.Errorf("expect the broken replica to be evicted by the transaction")
+	}
+}
+
+func TestDBConfigSQLiteReplicaNotSupport(t *testing.T) {
+	cfg := &DBConfig{ContextName: "local", Provider: SQLITE, DatabaseName: ":memory:", ReplicaURLs: []string{"replica.db"}}
+	if err := cfg.Validate(); err == nil || err.Error() != ErrDBReplicaNotSupport(SQLITE).Error() {
+		t.Errorf("got %v want %v", err, ErrDBReplicaNotSupport(SQLITE))
+	}
+}
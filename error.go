@@ -16,10 +16,12 @@ var (
 	ErrSSLKeyFileRequire          = errors.New("ssl key file is require")
 	ErrSSLKeyFileNotfound         = func(keyFile string) error { return fmt.Errorf("ssl key file: %s not found", keyFile) }
 
-	ErrDBConfigsIsRequire    = errors.New("database configs is require")
-	ErrLogConfigIsRequire    = errors.New("log config is require")
-	ErrRedisConfigsIsRequire = errors.New("redis configs is require")
-	ErrAPIConfigIsRequire    = errors.New("api config is require")
+	ErrDBConfigsIsRequire     = errors.New("database configs is require")
+	ErrLogConfigIsRequire     = errors.New("log config is require")
+	ErrRedisConfigsIsRequire  = errors.New("redis configs is require")
+	ErrAPIConfigIsRequire     = errors.New("api config is require")
+	ErrOutboxConfigsIsRequire = errors.New("outbox configs is require")
+	ErrClientConfigsIsRequire = errors.New("client configs is require")
 
 	//Log Config errors
 	ErrInvalidLogLevel         = func(level string) error { return fmt.Errorf("log level is invalid: %s" + level) }
@@ -37,6 +39,139 @@ var (
 	ErrDBNameRequire          = errors.New("database name is require")
 	ErrDBURLPattern           = func(url string) error { return fmt.Errorf("database invalid url [%s] pattern <db uri>:<port>", url) }
 	ErrDuplicateDBContextName = func(name string) error { return fmt.Errorf("database context name [%s] is duplicate", name) }
+	ErrInvalidDBSSLMode       = func(mode string) error { return fmt.Errorf("database ssl mode is invalid: %s", mode) }
+	ErrDBSSLRootCertInvalid   = func(file string) error {
+		return fmt.Errorf("database ssl root cert: %s has no valid certificate", file)
+	}
+	ErrDBSSLNoServerCert   = errors.New("database server does not present a certificate")
+	ErrDBReplicaNotSupport = func(provider string) error {
+		return fmt.Errorf("database provider %s does not support replicas", provider)
+	}
+	ErrDBNamedParamNotfound = func(name string) error { return fmt.Errorf("database named parameter [%s] not found in arg", name) }
+	ErrDBNamedArgInvalid    = func(arg any) error {
+		return fmt.Errorf("database named arg must be struct or map with string key: %T", arg)
+	}
+	ErrDBInitialScript = func(file string, line int, err error) error {
+		return fmt.Errorf("database initial script %s:%d execute fail: %w", file, line, err)
+	}
+	ErrDBConnectRetryExceed = func(name string, attempts int, err error) error {
+		return fmt.Errorf("database context name %s connect fail after %d attempts: %w", name, attempts, err)
+	}
+	ErrDBNotReady = func(name string, err error) error {
+		return fmt.Errorf("database context name %s is not ready: %w", name, err)
+	}
+	ErrDBStoreClosed = errors.New("database store is closed")
+
+	//Requester errors
+	ErrHTTPClientStatus     = errors.New("http client error status")
+	ErrHTTPServerStatus     = errors.New("http server error status")
+	ErrRequesterCertInvalid = func(file string) error { return fmt.Errorf("requester cert file: %s has no valid certificate", file) }
+	ErrRequesterPinMismatch = errors.New("requester server certificate does not match any pinned public key")
+	ErrCircuitOpen          = errors.New("circuit breaker is open")
+	ErrCircuitBreakerOpen   = func(name string) error { return fmt.Errorf("%w: %s", ErrCircuitOpen, name) }
+	ErrDownloadTooLarge     = func(url string, maxSize int64) error {
+		return fmt.Errorf("download %s is larger than %d bytes", url, maxSize)
+	}
+	ErrHTTPResponseDecode = func(method string, url string, err error) error {
+		return fmt.Errorf("%s %s decode response fail: %w", method, url, err)
+	}
+
+	//Client errors
+	ErrClientContextNameIsRequire = errors.New("client context name is required")
+	ErrClientBaseURLIsRequire     = errors.New("client base url is required")
+	ErrInvalidClientTLSVersion    = func(version string) error { return fmt.Errorf("client min tls version is invalid: %s", version) }
+	ErrDuplicateClientContextName = func(name string) error { return fmt.Errorf("client context name [%s] is duplicate", name) }
+
+	//Request authentication errors
+	ErrOAuth2TokenURLIsRequire = errors.New("oauth2 token url is required")
+	ErrOAuth2ClientIDIsRequire = errors.New("oauth2 client id is required")
+	ErrOAuth2TokenInvalid      = errors.New("oauth2 token response is invalid")
+	ErrOAuth2TokenRequest      = func(status int, body string) error {
+		return fmt.Errorf("oauth2 token request return status %d: %s", status, body)
+	}
+	ErrHMACHeadersMissing    = errors.New("hmac signature headers are missing")
+	ErrHMACUnknownKey        = func(keyID string) error { return fmt.Errorf("hmac key id [%s] is unknown", keyID) }
+	ErrHMACTimestampInvalid  = errors.New("hmac signature timestamp is invalid or expired")
+	ErrHMACBodyTooLarge      = errors.New("hmac signed body is too large")
+	ErrHMACSignatureMismatch = errors.New("hmac signature does not match")
+	ErrHMACNonceReplayed     = errors.New("hmac signature nonce is replayed")
+
+	//Authentication errors
+	ErrJWTKeyIsRequire            = errors.New("jwt secret, public key or jwks url is required")
+	ErrJWTKeyInvalid              = func(file string) error { return fmt.Errorf("jwt key file: %s has no valid public key", file) }
+	ErrJWTKeyNotfound             = func(kid string) error { return fmt.Errorf("jwt key id [%s] not found", kid) }
+	ErrJWKSFetch                  = func(url string, status int) error { return fmt.Errorf("jwks %s return status %d", url, status) }
+	ErrJWKSKeyInvalid             = func(kid string) error { return fmt.Errorf("jwks key id [%s] is invalid", kid) }
+	ErrJWTMissing                 = errors.New("bearer token is missing")
+	ErrJWTExpired                 = errors.New("jwt is expired or has no expiry")
+	ErrJWTNotYetValid             = errors.New("jwt is not valid yet")
+	ErrJWTIssuerInvalid           = func(issuer string) error { return fmt.Errorf("jwt issuer [%s] is invalid", issuer) }
+	ErrJWTAudienceInvalid         = errors.New("jwt audience is invalid")
+	ErrSigningKeyInvalid          = func(kid string) error { return fmt.Errorf("signing key [%s] does not match its algorithm", kid) }
+	ErrSigningKeyActive           = func(kid string) error { return fmt.Errorf("signing key [%s] is active and cannot be retired", kid) }
+	ErrRefreshTokenStoreIsRequire = errors.New("refresh token store is required")
+	ErrRefreshTokenInvalid        = errors.New("refresh token is invalid, expired or revoked")
+	ErrRefreshTokenReused         = errors.New("refresh token is reused, its family is revoked")
+	ErrRefreshTokenReusedBy       = func(subject string) error { return fmt.Errorf("subject [%s]: %w", subject, ErrRefreshTokenReused) }
+	ErrAPIKeyStoreIsRequire       = errors.New("api key store is required")
+	ErrAPIKeyManagerIsRequire     = errors.New("api key manager is required")
+	ErrAPIKeyAdminAuthIsRequire   = errors.New("api key admin endpoints require an authorization middleware")
+	ErrAPIKeyLookupInvalid        = func(lookup string) error { return fmt.Errorf("api key lookup [%s] is invalid", lookup) }
+	ErrAPIKeyMissing              = errors.New("api key is missing")
+	ErrAPIKeyInvalid              = errors.New("api key is invalid, expired or revoked")
+	ErrAPIKeyNotfound             = errors.New("api key not found")
+	ErrAPIKeyQuotaExceeded        = func(id string) error { return fmt.Errorf("api key [%s] quota exceeded", id) }
+	ErrTOTPStoreIsRequire         = errors.New("totp store is required")
+	ErrTOTPCacheIsRequire         = errors.New("totp redis cache is required")
+	ErrTOTPEncryptionKeyIsRequire = errors.New("totp encryption key is required")
+	ErrTOTPAlreadyEnrolled        = func(userID string) error { return fmt.Errorf("user [%s] is already enrolled in totp", userID) }
+	ErrTOTPNotEnrolled            = func(userID string) error { return fmt.Errorf("user [%s] is not enrolled in totp", userID) }
+	ErrTOTPCodeInvalid            = errors.New("totp code is invalid or already used")
+	ErrTOTPTooManyAttempts        = func(userID string) error { return fmt.Errorf("user [%s] has too many failed totp attempts", userID) }
+	ErrPermissionInvalid          = func(permission string) error { return fmt.Errorf("permission [%s] is not resource:action", permission) }
+	ErrPolicyRoleNameIsRequire    = errors.New("policy role name is required")
+	ErrDuplicatePolicyRole        = func(role string) error { return fmt.Errorf("duplicate policy role [%s]", role) }
+	ErrPolicyRoleNotfound         = func(role string) error { return fmt.Errorf("policy role [%s] not found", role) }
+	ErrPolicySourceIsRequire      = errors.New("policy source is required")
+	ErrAuthorizerNotfound         = errors.New("authorizer is not configured")
+	ErrCSRFTokenLookupInvalid     = func(lookup string) error { return fmt.Errorf("csrf token lookup [%s] is invalid", lookup) }
+	ErrCSRFTokenMissing           = errors.New("csrf token is missing")
+	ErrCSRFTokenInvalid           = errors.New("csrf token is invalid or expired")
+	ErrCSRFOriginMissing          = errors.New("origin or referer header is required")
+	ErrCSRFOriginInvalid          = func(origin string) error { return fmt.Errorf("origin [%s] is not trusted", origin) }
+	ErrPrincipalForbidden         = func(requirement string) error {
+		return fmt.Errorf("principal does not have the required %s", requirement)
+	}
+
+	//Response cache errors
+	ErrResponseCacheIsRequire = errors.New("response cache redis is required")
+
+	//Outbox errors
+	ErrOutboxContextNameIsRequire      = errors.New("outbox context name is required")
+	ErrOutboxDBContextNameIsRequire    = errors.New("outbox database context name is required")
+	ErrOutboxCacheContextNameIsRequire = errors.New("outbox cache context name is required")
+	ErrInvalidOutboxTable              = func(table string) error { return fmt.Errorf("outbox table name is invalid: %s", table) }
+	ErrInvalidOutboxMode               = func(mode string) error { return fmt.Errorf("outbox mode is invalid: %s", mode) }
+	ErrDuplicateOutboxContextName      = func(name string) error { return fmt.Errorf("outbox context name [%s] is duplicate", name) }
+	ErrOutboxDBNotfound                = func(name string) error { return fmt.Errorf("outbox database context name [%s] not found", name) }
+	ErrOutboxCacheNotfound             = func(name string) error { return fmt.Errorf("outbox cache context name [%s] not found", name) }
+	ErrOutboxTopicIsRequire            = errors.New("outbox topic is require")
+
+	//Query builder errors
+	ErrQueryFieldIsRequire  = errors.New("query field is require")
+	ErrQueryFieldNotAllowed = func(field string) error { return fmt.Errorf("query field [%s] is not allowed", field) }
+	ErrInvalidQueryOperator = func(field string, op Operator) error {
+		return fmt.Errorf("query field [%s] operator is invalid: %s", field, op)
+	}
+	ErrInvalidQueryDirection = func(field string, d Direction) error {
+		return fmt.Errorf("query field [%s] order direction is invalid: %s", field, d)
+	}
+	ErrInvalidQueryValue = func(field string, op Operator) error {
+		return fmt.Errorf("query field [%s] value is invalid for operator %s", field, op)
+	}
+	ErrInvalidQueryLimit            = func(limit int) error { return fmt.Errorf("query limit is invalid: %d", limit) }
+	ErrInvalidQueryOffset           = func(offset int) error { return fmt.Errorf("query offset is invalid: %d", offset) }
+	ErrQueryBuilderColumnsIsRequire = errors.New("query builder columns is require")
 
 	//RedisCache Config errors
 	ErrDuplicateRedisContextName = func(name string) error { return fmt.Errorf("redis context name [%s] is duplicate", name) }
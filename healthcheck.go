@@ -6,6 +6,13 @@ type HealthCheckFunc func(ms *Microservice) error
 
 func (ms *Microservice) registerHealthCheck() {
 	ms.echo.GET(ms.healthCheckEndpoint, func(c echo.Context) error {
+		// a database started in degraded mode is not ready until it connect
+		for _, dbStore := range ms.dbStores {
+			if err := dbStore.Ready(); err != nil {
+				ms.responseNotReady(c.Response(), err.Error())
+				return nil
+			}
+		}
 		for _, health := range ms.healthCheckFuncs {
 			if err := health(ms); err != nil {
 				ms.responseUnHealthy(c.Response(), err.Error())
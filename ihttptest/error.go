@@ -0,0 +1,15 @@
+package ihttptest
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	ErrFixtureFileIsRequire = errors.New("fixture file is required")
+	ErrInvalidMode          = func(mode Mode) error { return fmt.Errorf("record/replay mode is invalid: %s", mode) }
+	ErrUnmatched            = errors.New("no recorded response match the request")
+	ErrUnmatchedRequest     = func(method string, url string) error {
+		return fmt.Errorf("%w: %s %s", ErrUnmatched, method, url)
+	}
+)
@@ -0,0 +1,382 @@
+// Package ihttptest provide the record/replay transport for testing the handlers calling downstream APIs
+// through ihttp.IRequester.
+//
+// In record mode the calls go to the live downstream and the request/response pairs are saved to a fixture
+// file on Close, in replay mode the fixture answer the calls without network:
+//
+//	recorder, err := ihttptest.New(ihttptest.Config{File: "testdata/users.json", Mode: ihttptest.ModeAuto})
+//	defer recorder.Close()
+//	requester, err := ihttp.NewRequesterWithOptions(nil, baseURL, ihttp.RequesterOptions{WrapTransport: recorder.Wrap})
+//
+// or for every requester of the service with ihttp.WithRequesterTransport(recorder.Wrap).
+package ihttptest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Mode is the mode of the Transport
+type Mode string
+
+const (
+	// ModeRecord send the calls to the downstream and save them
+	ModeRecord Mode = "record"
+	// ModeReplay answer the calls from the fixture
+	ModeReplay Mode = "replay"
+	// ModeAuto replay when the fixture file exist, otherwise record
+	ModeAuto Mode = "auto"
+)
+
+// MatchOn is the parts of the request compared in replay mode
+type MatchOn int
+
+const (
+	MatchMethod MatchOn = 1 << iota
+	MatchPath
+	MatchQuery
+	MatchBody
+	// MatchDefault compare method, path and query
+	MatchDefault = MatchMethod | MatchPath | MatchQuery
+)
+
+// Redacted replace the value of the redacted headers
+const Redacted = "REDACTED"
+
+// DefaultRedactHeaders is the headers redacted when recording
+var DefaultRedactHeaders = []string{
+	"Authorization",
+	"Proxy-Authorization",
+	"Cookie",
+	"Set-Cookie",
+	"X-Api-Key",
+	"X-Signature",
+}
+
+// Config is the config of Transport
+type Config struct {
+	// File is the fixture file, such as testdata/users.json
+	File string
+	// Mode default ModeAuto
+	Mode Mode
+	// Match is the parts of the request compared in replay mode, default MatchDefault
+	Match MatchOn
+	// Strict fail the unmatched calls in replay mode, otherwise they are sent to the downstream
+	Strict bool
+	// RedactHeaders is the request and response headers redacted when recording in addition to DefaultRedactHeaders
+	RedactHeaders []string
+}
+
+// Interaction is a recorded request/response pair
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   recordBody  `json:"body"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       recordBody  `json:"body"`
+}
+
+// recordBody is saved as text, or as base64 when it is not valid UTF-8
+type recordBody []byte
+
+func (body recordBody) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(body) {
+		return json.Marshal(string(body))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(body)})
+}
+
+func (body *recordBody) UnmarshalJSON(data []byte) error {
+	var text string
+	if err := json.Unmarshal(data, &text); err == nil {
+		*body = recordBody(text)
+		return nil
+	}
+	encoded := struct {
+		Base64 string `json:"base64"`
+	}{}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	raw, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	if err != nil {
+		return err
+	}
+	*body = raw
+	return nil
+}
+
+type fixture struct {
+	Interactions []*Interaction `json:"interactions"`
+}
+
+// Transport is the record/replay http.RoundTripper
+type Transport struct {
+	config Config
+	redact map[string]bool
+
+	mutex        sync.Mutex
+	interactions []*Interaction
+	used         []bool
+	unmatched    []string
+}
+
+// New return new Transport, in replay mode the fixture file is loaded
+func New(config Config) (*Transport, error) {
+	if strings.TrimSpace(config.File) == "" {
+		return nil, ErrFixtureFileIsRequire
+	}
+	if config.Mode == "" {
+		config.Mode = ModeAuto
+	}
+	if config.Match == 0 {
+		config.Match = MatchDefault
+	}
+	if config.Mode == ModeAuto {
+		config.Mode = ModeRecord
+		if _, err := os.Stat(config.File); err == nil {
+			config.Mode = ModeReplay
+		}
+	}
+	if config.Mode != ModeRecord && config.Mode != ModeReplay {
+		return nil, ErrInvalidMode(config.Mode)
+	}
+
+	transport := &Transport{
+		config:       config,
+		redact:       make(map[string]bool),
+		interactions: make([]*Interaction, 0),
+	}
+	for _, header := range append(append([]string{}, DefaultRedactHeaders...), config.RedactHeaders...) {
+		transport.redact[http.CanonicalHeaderKey(strings.TrimSpace(header))] = true
+	}
+	if config.Mode == ModeReplay {
+		raw, err := os.ReadFile(config.File)
+		if err != nil {
+			return nil, err
+		}
+		loaded := &fixture{}
+		if err := json.Unmarshal(raw, loaded); err != nil {
+			return nil, err
+		}
+		transport.interactions = loaded.Interactions
+		transport.used = make([]bool, len(loaded.Interactions))
+	}
+	return transport, nil
+}
+
+// Mode return the resolved mode, ModeAuto is resolved to ModeRecord or ModeReplay
+func (transport *Transport) Mode() Mode {
+	return transport.config.Mode
+}
+
+// wrappedTransport is the Transport of one requester, the live calls go to the requester transport
+type wrappedTransport struct {
+	transport *Transport
+	next      http.RoundTripper
+}
+
+// Wrap return the transport sending the live calls to next, it is the ihttp.RequesterOptions.WrapTransport.
+// Every wrapped requester keep its own next, the recorded calls are shared.
+func (transport *Transport) Wrap(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &wrappedTransport{transport: transport, next: next}
+}
+
+func (wrapped *wrappedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return wrapped.transport.roundTrip(req, wrapped.next)
+}
+
+// CloseIdleConnections close the idle connections of the requester transport
+func (wrapped *wrappedTransport) CloseIdleConnections() {
+	closeIdleConnections(wrapped.next)
+}
+
+// RoundTrip record or replay the call, the live calls go to http.DefaultTransport
+func (transport *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return transport.roundTrip(req, http.DefaultTransport)
+}
+
+func (transport *Transport) roundTrip(req *http.Request, next http.RoundTripper) (*http.Response, error) {
+	body, err := readRequestBody(req)
+	if err != nil {
+		return nil, err
+	}
+	if transport.config.Mode == ModeReplay {
+		if interaction := transport.match(req, body); interaction != nil {
+			return interaction.Response.toHTTPResponse(req), nil
+		}
+		transport.mutex.Lock()
+		transport.unmatched = append(transport.unmatched, req.Method+" "+req.URL.String())
+		transport.mutex.Unlock()
+		if transport.config.Strict {
+			return nil, ErrUnmatchedRequest(req.Method, req.URL.String())
+		}
+	}
+
+	resp, err := next.RoundTrip(req)
+	if err != nil || transport.config.Mode != ModeRecord {
+		return resp, err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	transport.mutex.Lock()
+	transport.interactions = append(transport.interactions, &Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Header: transport.redactHeader(req.Header),
+			Body:   body,
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Header:     transport.redactHeader(resp.Header),
+			Body:       respBody,
+		},
+	})
+	transport.mutex.Unlock()
+	return resp, nil
+}
+
+// Unmatched return the calls without recorded response in replay mode
+func (transport *Transport) Unmatched() []string {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+	return append([]string(nil), transport.unmatched...)
+}
+
+// Close save the recorded calls to the fixture file in record mode
+func (transport *Transport) Close() error {
+	if transport.config.Mode != ModeRecord {
+		return nil
+	}
+	transport.mutex.Lock()
+	raw, err := json.MarshalIndent(&fixture{Interactions: transport.interactions}, "", "  ")
+	transport.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(transport.config.File), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(transport.config.File, raw, 0644)
+}
+
+// CloseIdleConnections close the idle connections of http.DefaultTransport
+func (transport *Transport) CloseIdleConnections() {
+	closeIdleConnections(http.DefaultTransport)
+}
+
+func closeIdleConnections(next http.RoundTripper) {
+	if closer, ok := next.(interface{ CloseIdleConnections() }); ok {
+		closer.CloseIdleConnections()
+	}
+}
+
+// match return the first unused matching interaction, the last matching one is reused when all are used
+func (transport *Transport) match(req *http.Request, body []byte) *Interaction {
+	transport.mutex.Lock()
+	defer transport.mutex.Unlock()
+	var reused *Interaction
+	for idx, interaction := range transport.interactions {
+		if !transport.matches(interaction, req, body) {
+			continue
+		}
+		if !transport.used[idx] {
+			transport.used[idx] = true
+			return interaction
+		}
+		reused = interaction
+	}
+	return reused
+}
+
+func (transport *Transport) matches(interaction *Interaction, req *http.Request, body []byte) bool {
+	match := transport.config.Match
+	if match&MatchMethod != 0 && !strings.EqualFold(interaction.Request.Method, req.Method) {
+		return false
+	}
+	recorded, err := req.URL.Parse(interaction.Request.URL)
+	if err != nil {
+		return false
+	}
+	if match&MatchPath != 0 && recorded.Path != req.URL.Path {
+		return false
+	}
+	// the query is compared as values so the parameter order does not matter
+	if match&MatchQuery != 0 && recorded.Query().Encode() != req.URL.Query().Encode() {
+		return false
+	}
+	if match&MatchBody != 0 && !bytes.Equal(interaction.Request.Body, body) {
+		return false
+	}
+	return true
+}
+
+func (transport *Transport) redactHeader(header http.Header) http.Header {
+	redacted := header.Clone()
+	for name := range redacted {
+		if transport.redact[http.CanonicalHeaderKey(name)] {
+			redacted[name] = []string{Redacted}
+		}
+	}
+	return redacted
+}
+
+func (resp RecordedResponse) toHTTPResponse(req *http.Request) *http.Response {
+	header := resp.Header.Clone()
+	if header == nil {
+		header = make(http.Header)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", resp.StatusCode, http.StatusText(resp.StatusCode)),
+		StatusCode:    resp.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(resp.Body)),
+		ContentLength: int64(len(resp.Body)),
+		Request:       req,
+	}
+}
+
+// readRequestBody read the body and put it back for the live transport
+func readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	return body, nil
+}
@@ -0,0 +1,197 @@
+package ihttptest_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gitkeng/ihttp"
+	"github.com/gitkeng/ihttp/ihttptest"
+)
+
+func TestRecordReplay(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret-session"})
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"name":"` + r.URL.Query().Get("name") + `"}`))
+	}))
+	file := filepath.Join(t.TempDir(), "testdata", "users.json")
+
+	recorder, err := ihttptest.New(ihttptest.Config{File: file})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if recorder.Mode() != ihttptest.ModeRecord {
+		t.Errorf("expect record mode without fixture got %s", recorder.Mode())
+	}
+	requester, err := ihttp.NewRequesterWithOptions(nil, server.URL, ihttp.RequesterOptions{
+		Headers:       map[string]string{"Authorization": "Bearer secret-token"},
+		WrapTransport: recorder.Wrap,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, name := range []string{"somchai", "somsri"} {
+		if _, err := requester.Get("/users", map[string]string{"name": name, "active": "true"}); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if err := recorder.Close(); err != nil {
+		t.Error(err)
+		return
+	}
+	raw, err := os.ReadFile(file)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if strings.Contains(string(raw), "secret-token") || strings.Contains(string(raw), "secret-session") ||
+		!strings.Contains(string(raw), ihttptest.Redacted) {
+		t.Errorf("expect secrets redacted in %s", raw)
+	}
+
+	// the downstream is gone, the fixture answer the calls
+	server.Close()
+	replayer, err := ihttptest.New(ihttptest.Config{File: file, Strict: true})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if replayer.Mode() != ihttptest.ModeReplay {
+		t.Errorf("expect replay mode with fixture got %s", replayer.Mode())
+	}
+	ms, err := ihttp.New(
+		ihttp.WithAPIConfig(&ihttp.APIConfig{}),
+		ihttp.WithRequesterTransport(replayer.Wrap),
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer ms.Cleanup()
+	ms.GET("/profile", func(ctx ihttp.IContext) error {
+		requester, err := ctx.Requester(server.URL, 0)
+		if err != nil {
+			return err
+		}
+		resp, err := requester.Get("/users", map[string]string{"active": "true", "name": ctx.QueryParam("name")})
+		if err != nil {
+			return err
+		}
+		return ctx.WebContext().JSONBlob(resp.StatusCode, resp.Body)
+	})
+
+	rec := httptest.NewRecorder()
+	ms.GetEngine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/profile?name=somsri", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"name":"somsri"}` {
+		t.Errorf("unexpected replayed response %d %s", rec.Code, rec.Body.String())
+	}
+	if calls != 2 {
+		t.Errorf("expect no live call in replay mode, got %d calls", calls)
+	}
+
+	replayed, err := ihttp.NewRequesterWithOptions(nil, server.URL, ihttp.RequesterOptions{WrapTransport: replayer.Wrap})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := replayed.Get("/users", map[string]string{"name": "somjai"}); !errors.Is(err, ihttptest.ErrUnmatched) {
+		t.Errorf("expect unmatched error in strict mode, got %v", err)
+	}
+	if len(replayer.Unmatched()) != 1 {
+		t.Errorf("expect one unmatched call got %v", replayer.Unmatched())
+	}
+}
+
+type roundTripperFunc func(req *http.Request) (*http.Response, error)
+
+func (fn roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return fn(req)
+}
+
+func TestWrapIsolation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{}`))
+	}))
+	defer server.Close()
+	dir := t.TempDir()
+
+	// every wrapped requester send its live calls to its own base transport
+	recorder, err := ihttptest.New(ihttptest.Config{File: filepath.Join(dir, "wrap.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := map[string]int{}
+	wrapped := map[string]http.RoundTripper{}
+	for _, name := range []string{"first", "second"} {
+		name := name
+		wrapped[name] = recorder.Wrap(roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			calls[name]++
+			return http.DefaultTransport.RoundTrip(req)
+		}))
+	}
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/first", nil)
+	resp, err := wrapped["first"].RoundTrip(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if calls["first"] != 1 || calls["second"] != 0 {
+		t.Errorf("expect the call on the first transport only got %v", calls)
+	}
+
+	// the pooled requesters of different transports are not shared
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ms.Cleanup()
+	recorders := map[string]*ihttptest.Transport{}
+	for _, name := range []string{"a", "b"} {
+		if recorders[name], err = ihttptest.New(ihttptest.Config{File: filepath.Join(dir, name+".json")}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ms.GET("/call/:name", func(ctx ihttp.IContext) error {
+		name := ctx.WebContext().Param("name")
+		requester, err := ctx.RequesterWithOptions(server.URL, ihttp.RequesterOptions{
+			WrapTransport: recorders[name].Wrap,
+			PoolKey:       name,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := requester.Get("/users", nil); err != nil {
+			return err
+		}
+		return ctx.WebContext().NoContent(http.StatusNoContent)
+	})
+	for _, name := range []string{"a", "b", "b"} {
+		rec := httptest.NewRecorder()
+		ms.GetEngine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/call/"+name, nil))
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("call %s got %d %s", name, rec.Code, rec.Body.String())
+		}
+	}
+	for name, expect := range map[string]int{"a": 1, "b": 2} {
+		if err := recorders[name].Close(); err != nil {
+			t.Fatal(err)
+		}
+		raw, err := os.ReadFile(filepath.Join(dir, name+".json"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Count(string(raw), `"method"`); got != expect {
+			t.Errorf("recorder %s expect %d calls got %d", name, expect, got)
+		}
+	}
+}
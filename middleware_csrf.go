@@ -1,14 +1,20 @@
 package ihttp
 
 import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
-	"errors"
-	"github.com/labstack/echo/v4"
-	"github.com/labstack/echo/v4/middleware"
-	"github.com/labstack/gommon/random"
+	"encoding/base64"
 	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/labstack/gommon/random"
 )
 
 type (
@@ -21,8 +27,8 @@ type (
 		TokenLength uint8 `yaml:"token_length"`
 		// Optional. Default value 32.
 
-		// TokenLookup is a string in the form of "<source>:<key>" that is used
-		// to extract token from the request.
+		// TokenLookup is a comma separated list of "<source>:<key>" that is used
+		// to extract token from the request, the first non-empty source is used.
 		// Optional. Default value "header:X-CSRF-Token".
 		// Possible values:
 		// - "header:<name>"
@@ -61,6 +67,32 @@ type (
 		// Indicates if CSRF cookie is SameSite Mode.
 		// Optional. Default value false.
 		CookieSameSite http.SameSite `yaml:"cookie_same_site"`
+
+		// Secret enable the signed token mode, the token is an HMAC over the session id,
+		// its expiry and a nonce so it cannot be forged or reused by another session.
+		// Optional. Default value none, the token is random.
+		Secret string `yaml:"-"`
+
+		// SessionID return the session the signed token is bound to, a new token is issued
+		// when the session change.
+		// Optional. Default value is the subject of the authenticated principal.
+		SessionID func(c echo.Context) string `yaml:"-"`
+
+		// CheckOrigin enable the check of the Origin or Referer header of unsafe requests,
+		// it is always enabled in the signed token mode.
+		// Optional. Default value false.
+		CheckOrigin bool `yaml:"check_origin"`
+
+		// TrustedOrigins is the origins, in the form of "scheme://host[:port]", accepted in
+		// the Origin or Referer header of unsafe requests in addition to the origin of the request.
+		// It apply when the origin is checked.
+		// Optional. Default value none.
+		TrustedOrigins []string `yaml:"trusted_origins"`
+
+		// RequireOrigin reject unsafe requests without Origin and Referer header.
+		// It apply when the origin is checked.
+		// Optional. Default value false.
+		RequireOrigin bool `yaml:"require_origin"`
 	}
 
 	// csrfTokenExtractor defines a function that takes `echo.Context` and returns
@@ -87,9 +119,25 @@ func CSRF() echo.MiddlewareFunc {
 	return CSRFWithConfig(c)
 }
 
-// CSRFIncludeGETMethodWithConfig returns a CSRF middleware with config.
+// CSRFIncludeGETMethodWithConfig returns a CSRF middleware with config validating GET requests too.
 // See `CSRF()`.
 func CSRFIncludeGETMethodWithConfig(config CSRFConfig) echo.MiddlewareFunc {
+	return csrfWithConfig(nil, config, true)
+}
+
+// CSRFWithConfig returns a CSRF middleware with config.
+// See `CSRF()`.
+func CSRFWithConfig(config CSRFConfig) echo.MiddlewareFunc {
+	return csrfWithConfig(nil, config, false)
+}
+
+// CSRF returns a CSRF middleware with config, the rejections are logged with the service logger.
+// See `CSRF()`.
+func (ms *Microservice) CSRF(config CSRFConfig) echo.MiddlewareFunc {
+	return csrfWithConfig(ms, config, false)
+}
+
+func csrfWithConfig(ms *Microservice, config CSRFConfig, includeGET bool) echo.MiddlewareFunc {
 	// Defaults
 	if config.Skipper == nil {
 		config.Skipper = DefaultCSRFConfig.Skipper
@@ -109,15 +157,36 @@ func CSRFIncludeGETMethodWithConfig(config CSRFConfig) echo.MiddlewareFunc {
 	if config.CookieMaxAge == 0 {
 		config.CookieMaxAge = DefaultCSRFConfig.CookieMaxAge
 	}
+	if config.SessionID == nil {
+		config.SessionID = func(c echo.Context) string {
+			if principal, found := principalFrom(c); found {
+				return principal.Subject
+			}
+			return ""
+		}
+	}
 
 	// Initialize
-	parts := strings.Split(config.TokenLookup, ":")
-	extractor := csrfTokenFromHeader(parts[1])
-	switch parts[0] {
-	case "form":
-		extractor = csrfTokenFromForm(parts[1])
-	case "query":
-		extractor = csrfTokenFromQuery(parts[1])
+	extractors := make([]csrfTokenExtractor, 0)
+	for _, source := range strings.Split(config.TokenLookup, ",") {
+		parts := strings.SplitN(strings.TrimSpace(source), ":", 2)
+		if len(parts) != 2 || parts[1] == "" {
+			panic(ErrCSRFTokenLookupInvalid(source))
+		}
+		switch parts[0] {
+		case "header":
+			extractors = append(extractors, csrfTokenFromHeader(parts[1]))
+		case "form":
+			extractors = append(extractors, csrfTokenFromForm(parts[1]))
+		case "query":
+			extractors = append(extractors, csrfTokenFromQuery(parts[1]))
+		default:
+			panic(ErrCSRFTokenLookupInvalid(source))
+		}
+	}
+	signer := &csrfSigner{
+		secret: []byte(config.Secret),
+		ttl:    time.Duration(config.CookieMaxAge) * time.Second,
 	}
 
 	return func(next echo.HandlerFunc) echo.HandlerFunc {
@@ -127,27 +196,37 @@ func CSRFIncludeGETMethodWithConfig(config CSRFConfig) echo.MiddlewareFunc {
 			}
 
 			req := c.Request()
+			sessionID := config.SessionID(c)
 			k, err := c.Cookie(config.CookieName)
-			token := ""
+			token, cookieToken := "", ""
+			if err == nil {
+				cookieToken = k.Value
+			}
 
-			// Generate token
-			if err != nil {
-				token = random.String(config.TokenLength)
-			} else {
+			if err == nil && (config.Secret == "" || signer.fresh(k.Value, sessionID)) {
 				//Reuse token
 				token = k.Value
+			} else if config.Secret != "" {
+				// Generate token, it rotate when the session change or the token near its expiry
+				if token, err = signer.sign(sessionID); err != nil {
+					return csrfReject(ms, c, http.StatusInternalServerError, "INTERNAL_ERROR", "cannot generate csrf token", err)
+				}
+			} else {
+				token = random.String(config.TokenLength)
 			}
 
 			switch req.Method {
 			case http.MethodHead, http.MethodOptions, http.MethodTrace:
+			case http.MethodGet:
+				if includeGET {
+					if err := validateCSRFRequest(c, config, signer, extractors, cookieToken, sessionID); err != nil {
+						return csrfReject(ms, c, http.StatusForbidden, "INVALID_CSRF_TOKEN", "invalid csrf token", err)
+					}
+				}
 			default:
 				// Validate token only for requests which are not defined as 'safe' by RFC7231
-				clientToken, err := extractor(c)
-				if err != nil {
-					return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-				}
-				if !validateCSRFToken(token, clientToken) {
-					return echo.NewHTTPError(http.StatusForbidden, "invalid csrf token")
+				if err := validateCSRFRequest(c, config, signer, extractors, cookieToken, sessionID); err != nil {
+					return csrfReject(ms, c, http.StatusForbidden, "INVALID_CSRF_TOKEN", "invalid csrf token", err)
 				}
 			}
 
@@ -169,7 +248,6 @@ func CSRFIncludeGETMethodWithConfig(config CSRFConfig) echo.MiddlewareFunc {
 			} else {
 				cookie.SameSite = config.CookieSameSite
 			}
-
 			c.SetCookie(cookie)
 
 			// Store token in the context
@@ -183,99 +261,124 @@ func CSRFIncludeGETMethodWithConfig(config CSRFConfig) echo.MiddlewareFunc {
 	}
 }
 
-// CSRFWithConfig returns a CSRF middleware with config.
-// See `CSRF()`.
-func CSRFWithConfig(config CSRFConfig) echo.MiddlewareFunc {
-	// Defaults
-	if config.Skipper == nil {
-		config.Skipper = DefaultCSRFConfig.Skipper
-	}
-	if config.TokenLength == 0 {
-		config.TokenLength = DefaultCSRFConfig.TokenLength
+// validateCSRFRequest check the submitted token against the cookie token and the origin of the request
+// when it is enabled, a signed token must also be valid for the session
+func validateCSRFRequest(c echo.Context, config CSRFConfig, signer *csrfSigner, extractors []csrfTokenExtractor,
+	cookieToken string, sessionID string) error {
+	if config.Secret != "" || config.CheckOrigin {
+		if err := validateCSRFOrigin(c.Request(), config); err != nil {
+			return err
+		}
 	}
-	if config.TokenLookup == "" {
-		config.TokenLookup = DefaultCSRFConfig.TokenLookup
+	clientToken := ""
+	for _, extractor := range extractors {
+		if clientToken, _ = extractor(c); clientToken != "" {
+			break
+		}
 	}
-	if config.ContextKey == "" {
-		config.ContextKey = DefaultCSRFConfig.ContextKey
+	if clientToken == "" || cookieToken == "" {
+		return ErrCSRFTokenMissing
 	}
-	if config.CookieName == "" {
-		config.CookieName = DefaultCSRFConfig.CookieName
+	if !validateCSRFToken(cookieToken, clientToken) {
+		return ErrCSRFTokenInvalid
 	}
-	if config.CookieMaxAge == 0 {
-		config.CookieMaxAge = DefaultCSRFConfig.CookieMaxAge
+	if config.Secret != "" && !signer.valid(clientToken, sessionID) {
+		return ErrCSRFTokenInvalid
 	}
+	return nil
+}
 
-	// Initialize
-	parts := strings.Split(config.TokenLookup, ":")
-	extractor := csrfTokenFromHeader(parts[1])
-	switch parts[0] {
-	case "form":
-		extractor = csrfTokenFromForm(parts[1])
-	case "query":
-		extractor = csrfTokenFromQuery(parts[1])
+// validateCSRFOrigin check the Origin header, or the Referer header without Origin,
+// match the origin of the request or a trusted origin
+func validateCSRFOrigin(req *http.Request, config CSRFConfig) error {
+	origin := req.Header.Get(echo.HeaderOrigin)
+	if origin == "" {
+		if referer, err := url.Parse(req.Referer()); err == nil && referer.Host != "" {
+			origin = referer.Scheme + "://" + referer.Host
+		}
 	}
+	if origin == "" {
+		if config.RequireOrigin {
+			return ErrCSRFOriginMissing
+		}
+		return nil
+	}
+	scheme := "http"
+	if req.TLS != nil || strings.EqualFold(req.Header.Get(echo.HeaderXForwardedProto), "https") {
+		scheme = "https"
+	}
+	if strings.EqualFold(origin, scheme+"://"+req.Host) {
+		return nil
+	}
+	for _, trusted := range config.TrustedOrigins {
+		if strings.EqualFold(origin, strings.TrimSuffix(trusted, "/")) {
+			return nil
+		}
+	}
+	return ErrCSRFOriginInvalid(origin)
+}
 
-	return func(next echo.HandlerFunc) echo.HandlerFunc {
-		return func(c echo.Context) error {
-			if config.Skipper(c) {
-				return next(c)
-			}
-
-			req := c.Request()
-			k, err := c.Cookie(config.CookieName)
-			token := ""
+// csrfReject answer with the Response envelope
+func csrfReject(ms *Microservice, c echo.Context, status int, code string, message string, err error) error {
+	if ms != nil {
+		return NewHTTPContext(ms, c).Response(WarnLevel, "CSRF", status, code, message, err)
+	}
+	return c.JSON(status, Response{
+		RequestId:  c.Response().Header().Get(echo.HeaderXRequestID),
+		StatusCode: status,
+		Code:       code,
+		Message:    message,
+		Error:      ToErrors(err, code, "", nil),
+	})
+}
 
-			// Generate token
-			if err != nil {
-				token = random.String(config.TokenLength)
-			} else {
-				//Reuse token
-				token = k.Value
-			}
+// csrfSigner sign the tokens "<nonce>.<expiry>.<mac>", mac is the HMAC-SHA256 of the session id,
+// expiry and nonce
+type csrfSigner struct {
+	secret []byte
+	ttl    time.Duration
+}
 
-			switch req.Method {
-			case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
-			default:
-				// Validate token only for requests which are not defined as 'safe' by RFC7231
-				clientToken, err := extractor(c)
-				if err != nil {
-					return echo.NewHTTPError(http.StatusBadRequest, err.Error())
-				}
-				if !validateCSRFToken(token, clientToken) {
-					return echo.NewHTTPError(http.StatusForbidden, "invalid csrf token")
-				}
-			}
+func (signer *csrfSigner) mac(sessionID string, expiry string, nonce string) string {
+	mac := hmac.New(sha256.New, signer.secret)
+	mac.Write([]byte(sessionID + "\n" + expiry + "\n" + nonce))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
 
-			// Set CSRF cookie
-			cookie := new(http.Cookie)
-			cookie.Name = config.CookieName
-			cookie.Value = token
-			if config.CookiePath != "" {
-				cookie.Path = config.CookiePath
-			}
-			if config.CookieDomain != "" {
-				cookie.Domain = config.CookieDomain
-			}
-			cookie.Expires = time.Now().Add(time.Duration(config.CookieMaxAge) * time.Second)
-			cookie.Secure = config.CookieSecure
-			cookie.HttpOnly = config.CookieHTTPOnly
-			if config.CookieSameSite < 1 || config.CookieSameSite > 4 {
-				cookie.SameSite = http.SameSiteDefaultMode
-			} else {
-				cookie.SameSite = config.CookieSameSite
-			}
-			c.SetCookie(cookie)
+func (signer *csrfSigner) sign(sessionID string) (string, error) {
+	raw := make([]byte, 16)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(raw)
+	expiry := strconv.FormatInt(time.Now().Add(signer.ttl).Unix(), 10)
+	return nonce + "." + expiry + "." + signer.mac(sessionID, expiry, nonce), nil
+}
 
-			// Store token in the context
-			c.Set(config.ContextKey, token)
+// expiry return the expiry of the token signed for the session, zero when the token is invalid
+func (signer *csrfSigner) expiry(token string, sessionID string) time.Time {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}
+	}
+	if !hmac.Equal([]byte(parts[2]), []byte(signer.mac(sessionID, parts[1], parts[0]))) {
+		return time.Time{}
+	}
+	expiry, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return time.Time{}
+	}
+	return time.Unix(expiry, 0)
+}
 
-			// Protect clients from caching the response
-			c.Response().Header().Add(echo.HeaderVary, echo.HeaderCookie)
+func (signer *csrfSigner) valid(token string, sessionID string) bool {
+	return time.Now().Before(signer.expiry(token, sessionID))
+}
 
-			return next(c)
-		}
-	}
+// fresh report whether the token is valid for more than half of its lifetime,
+// an older token is replaced so the pages do not submit it after it expire
+func (signer *csrfSigner) fresh(token string, sessionID string) bool {
+	return time.Until(signer.expiry(token, sessionID)) > signer.ttl/2
 }
 
 // csrfTokenFromForm returns a `csrfTokenExtractor` that extracts token from the
@@ -292,7 +395,7 @@ func csrfTokenFromForm(param string) csrfTokenExtractor {
 	return func(c echo.Context) (string, error) {
 		token := c.FormValue(param)
 		if token == "" {
-			return "", errors.New("missing csrf token in the form parameter")
+			return "", ErrCSRFTokenMissing
 		}
 		return token, nil
 	}
@@ -304,7 +407,7 @@ func csrfTokenFromQuery(param string) csrfTokenExtractor {
 	return func(c echo.Context) (string, error) {
 		token := c.QueryParam(param)
 		if token == "" {
-			return "", errors.New("missing csrf token in the query string")
+			return "", ErrCSRFTokenMissing
 		}
 		return token, nil
 	}
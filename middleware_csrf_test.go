@@ -0,0 +1,191 @@
+package ihttp_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/gitkeng/ihttp"
+	"github.com/labstack/echo/v4"
+)
+
+func TestCSRFSignedToken(t *testing.T) {
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer ms.Cleanup()
+	csrf := ms.CSRF(ihttp.CSRFConfig{
+		Secret:         "s3cret",
+		TokenLookup:    "header:X-CSRF-Token,form:_csrf",
+		TrustedOrigins: []string{"https://app.example.com"},
+		SessionID: func(c echo.Context) string {
+			return c.Request().Header.Get("X-Session")
+		},
+	})
+	handler := func(ctx ihttp.IContext) error {
+		return ctx.WebContext().String(http.StatusOK, ctx.WebContext().Get("csrf").(string))
+	}
+	ms.GET("/form", handler, csrf)
+	ms.POST("/orders", handler, csrf)
+	server := httptest.NewServer(ms.GetEngine())
+	defer server.Close()
+
+	type request struct {
+		session string
+		cookie  string
+		header  string
+		form    string
+		origin  string
+		referer string
+	}
+	call := func(method string, r request) (*http.Response, *ihttp.Response, string) {
+		var body *strings.Reader
+		if r.form != "" {
+			body = strings.NewReader(url.Values{"_csrf": {r.form}}.Encode())
+		} else {
+			body = strings.NewReader("")
+		}
+		req, _ := http.NewRequest(method, server.URL+"/"+map[string]string{http.MethodGet: "form", http.MethodPost: "orders"}[method], body)
+		req.Header.Set("X-Session", r.session)
+		if r.form != "" {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		if r.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "_csrf", Value: r.cookie})
+		}
+		if r.header != "" {
+			req.Header.Set("X-CSRF-Token", r.header)
+		}
+		if r.origin != "" {
+			req.Header.Set("Origin", r.origin)
+		}
+		if r.referer != "" {
+			req.Header.Set("Referer", r.referer)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		cookie := ""
+		for _, c := range resp.Cookies() {
+			if c.Name == "_csrf" {
+				cookie = c.Value
+			}
+		}
+		envelope := &ihttp.Response{}
+		if resp.StatusCode != http.StatusOK {
+			json.NewDecoder(resp.Body).Decode(envelope)
+		}
+		return resp, envelope, cookie
+	}
+
+	_, _, token := call(http.MethodGet, request{session: "s1"})
+	if strings.Count(token, ".") != 2 {
+		t.Errorf("expect signed token, got %q", token)
+		return
+	}
+	if _, _, reused := call(http.MethodGet, request{session: "s1", cookie: token}); reused != token {
+		t.Error("expect fresh token reused within the session")
+	}
+	_, _, rotated := call(http.MethodGet, request{session: "s2", cookie: token})
+	if rotated == token {
+		t.Error("expect token rotated for a new session")
+	}
+
+	forged := token[:strings.LastIndex(token, ".")+1] + "AAAA"
+	tests := []struct {
+		name   string
+		req    request
+		status int
+	}{
+		{"header", request{session: "s1", cookie: token, header: token}, http.StatusOK},
+		{"form", request{session: "s1", cookie: token, form: token}, http.StatusOK},
+		{"same origin referer", request{session: "s1", cookie: token, header: token, referer: server.URL + "/form"}, http.StatusOK},
+		{"trusted origin", request{session: "s1", cookie: token, header: token, origin: "https://app.example.com"}, http.StatusOK},
+		{"missing token", request{session: "s1", cookie: token}, http.StatusForbidden},
+		{"other session", request{session: "s2", cookie: token, header: token}, http.StatusForbidden},
+		{"forged token", request{session: "s1", cookie: forged, header: forged}, http.StatusForbidden},
+		{"cross origin", request{session: "s1", cookie: token, header: token, origin: "https://evil.example.com"}, http.StatusForbidden},
+		{"cross origin referer", request{session: "s1", cookie: token, header: token, referer: "https://evil.example.com/page"}, http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		resp, envelope, _ := call(http.MethodPost, tc.req)
+		if resp.StatusCode != tc.status {
+			t.Errorf("%s: expect status %d, got %d", tc.name, tc.status, resp.StatusCode)
+			continue
+		}
+		if tc.status == http.StatusForbidden && (envelope.Code != "INVALID_CSRF_TOKEN" || len(envelope.Error) == 0) {
+			t.Errorf("%s: expect Errors envelope, got %+v", tc.name, envelope)
+		}
+	}
+}
+
+func TestCSRFWithConfigEnvelope(t *testing.T) {
+	e := echo.New()
+	e.POST("/orders", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}, ihttp.CSRFWithConfig(ihttp.CSRFConfig{TokenLookup: "header:X-CSRF-Token,query:csrf"}))
+
+	req := httptest.NewRequest(http.MethodPost, "/orders?csrf=abc", nil)
+	req.AddCookie(&http.Cookie{Name: "_csrf", Value: "abc"})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expect token from query accepted, got %d", rec.Code)
+	}
+
+	// the origin is not checked unless it is enabled
+	req = httptest.NewRequest(http.MethodPost, "/orders?csrf=abc", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	req.AddCookie(&http.Cookie{Name: "_csrf", Value: "abc"})
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expect cross origin accepted without origin check, got %d", rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/orders", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	envelope := &ihttp.Response{}
+	json.Unmarshal(rec.Body.Bytes(), envelope)
+	if rec.Code != http.StatusForbidden || envelope.Code != "INVALID_CSRF_TOKEN" {
+		t.Errorf("expect Errors envelope, got %d %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestCSRFCheckOrigin(t *testing.T) {
+	e := echo.New()
+	e.POST("/orders", func(c echo.Context) error {
+		return c.NoContent(http.StatusNoContent)
+	}, ihttp.CSRFWithConfig(ihttp.CSRFConfig{TokenLookup: "header:X-CSRF-Token", CheckOrigin: true, RequireOrigin: true}))
+
+	tests := []struct {
+		name   string
+		origin string
+		status int
+	}{
+		{"same origin", "http://example.com", http.StatusNoContent},
+		{"cross origin", "https://evil.example.com", http.StatusForbidden},
+		{"missing origin", "", http.StatusForbidden},
+	}
+	for _, tc := range tests {
+		req := httptest.NewRequest(http.MethodPost, "/orders", nil)
+		req.Header.Set("X-CSRF-Token", "abc")
+		req.AddCookie(&http.Cookie{Name: "_csrf", Value: "abc"})
+		if tc.origin != "" {
+			req.Header.Set("Origin", tc.origin)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		if rec.Code != tc.status {
+			t.Errorf("%s: expect status %d, got %d", tc.name, tc.status, rec.Code)
+		}
+	}
+}
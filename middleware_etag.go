@@ -0,0 +1,178 @@
+package ihttp
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+type (
+	// ConditionalGetConfig defines the config for ConditionalGet middleware.
+	ConditionalGetConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper middleware.Skipper
+
+		// WeakETag computes W/"..." ETags, for the bodies which are semantically equivalent
+		// but not byte identical, such as the compressed ones.
+		// Optional. Default value false.
+		WeakETag bool `yaml:"weak_etag"`
+
+		// CacheControl is the Cache-Control of the route when the handler does not set one,
+		// such as "public, max-age=60" or "private, no-cache".
+		// Optional. Default value "", no header.
+		CacheControl string `yaml:"cache_control"`
+
+		// LastModified returns the modification time of the requested resource,
+		// a Last-Modified header set by the handler takes precedence.
+		// Optional. Default value nil.
+		LastModified func(c echo.Context) time.Time
+	}
+
+	// bufferedResponseWriter keeps the response of the handler so it can be rewritten,
+	// the headers are still written to the underlying writer
+	bufferedResponseWriter struct {
+		http.ResponseWriter
+		status int
+		body   bytes.Buffer
+	}
+)
+
+var (
+	// DefaultConditionalGetConfig is the default ConditionalGet middleware config.
+	DefaultConditionalGetConfig = ConditionalGetConfig{
+		Skipper: middleware.DefaultSkipper,
+	}
+)
+
+func (w *bufferedResponseWriter) WriteHeader(code int) {
+	w.status = code
+}
+
+func (w *bufferedResponseWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
+
+// captureResponse run next with a buffered response writer, the original writer is restored on return
+func captureResponse(c echo.Context, next echo.HandlerFunc) (*bufferedResponseWriter, error) {
+	res := c.Response()
+	original := res.Writer
+	buffer := &bufferedResponseWriter{ResponseWriter: original, status: http.StatusOK}
+	res.Writer = buffer
+	defer func() {
+		res.Writer = original
+	}()
+	return buffer, next(c)
+}
+
+// flush write the captured response to the restored writer
+func (w *bufferedResponseWriter) flush(c echo.Context) error {
+	res := c.Response()
+	if !res.Committed {
+		return nil
+	}
+	res.Writer.WriteHeader(res.Status)
+	_, err := res.Writer.Write(w.body.Bytes())
+	return err
+}
+
+// ConditionalGet returns the middleware computing ETags and answering 304 Not Modified
+// with DefaultConditionalGetConfig.
+func ConditionalGet() echo.MiddlewareFunc {
+	return ConditionalGetWithConfig(DefaultConditionalGetConfig)
+}
+
+// ConditionalGetWithConfig returns the middleware computing the ETag of the GET responses from their body,
+// the requests whose If-None-Match or If-Modified-Since match are answered 304 Not Modified.
+// See `ConditionalGet()`.
+func ConditionalGetWithConfig(config ConditionalGetConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultConditionalGetConfig.Skipper
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) || c.Request().Method != http.MethodGet {
+				return next(c)
+			}
+			buffer, err := captureResponse(c, next)
+			res := c.Response()
+			if err != nil || res.Status != http.StatusOK {
+				if flushErr := buffer.flush(c); flushErr != nil {
+					return flushErr
+				}
+				return err
+			}
+
+			header := res.Header()
+			if config.CacheControl != "" && header.Get(echo.HeaderCacheControl) == "" {
+				header.Set(echo.HeaderCacheControl, config.CacheControl)
+			}
+			etag := header.Get("ETag")
+			if etag == "" {
+				sum := sha256.Sum256(buffer.body.Bytes())
+				etag = `"` + hex.EncodeToString(sum[:16]) + `"`
+				if config.WeakETag {
+					etag = "W/" + etag
+				}
+				header.Set("ETag", etag)
+			}
+			lastModified := time.Time{}
+			if value := header.Get(echo.HeaderLastModified); value != "" {
+				lastModified, _ = http.ParseTime(value)
+			} else if config.LastModified != nil {
+				if lastModified = config.LastModified(c); !lastModified.IsZero() {
+					header.Set(echo.HeaderLastModified, lastModified.UTC().Format(http.TimeFormat))
+				}
+			}
+
+			if notModified(c.Request(), etag, lastModified) {
+				header.Del(echo.HeaderContentType)
+				header.Del(echo.HeaderContentLength)
+				res.Status = http.StatusNotModified
+				res.Size = 0
+				res.Writer.WriteHeader(http.StatusNotModified)
+				return nil
+			}
+			return buffer.flush(c)
+		}
+	}
+}
+
+// notModified evaluate If-None-Match with the weak comparison, If-Modified-Since is evaluated
+// only without If-None-Match as of RFC 7232
+func notModified(req *http.Request, etag string, lastModified time.Time) bool {
+	if match := req.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	if lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(req.Header.Get(echo.HeaderIfModifiedSince))
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// CacheControl returns the middleware setting the Cache-Control of a route,
+// such as CacheControl("public, max-age=300").
+func CacheControl(value string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			c.Response().Header().Set(echo.HeaderCacheControl, value)
+			return next(c)
+		}
+	}
+}
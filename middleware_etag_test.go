@@ -0,0 +1,84 @@
+package ihttp_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gitkeng/ihttp"
+	"github.com/labstack/echo/v4"
+)
+
+func TestConditionalGet(t *testing.T) {
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer ms.Cleanup()
+	modified := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	ms.GET("/items", func(ctx ihttp.IContext) error {
+		return ctx.WebContext().JSON(http.StatusOK, map[string]string{"name": "item"})
+	}, ihttp.ConditionalGetWithConfig(ihttp.ConditionalGetConfig{
+		CacheControl: "public, max-age=60",
+		LastModified: func(c echo.Context) time.Time { return modified },
+	}))
+	ms.GET("/weak", func(ctx ihttp.IContext) error {
+		return ctx.WebContext().String(http.StatusOK, "weak")
+	}, ihttp.ConditionalGetWithConfig(ihttp.ConditionalGetConfig{WeakETag: true}), ihttp.CacheControl("private, no-cache"))
+	ms.GET("/missing", func(ctx ihttp.IContext) error {
+		return ctx.WebContext().String(http.StatusNotFound, "missing")
+	}, ihttp.ConditionalGet())
+	server := httptest.NewServer(ms.GetEngine())
+	defer server.Close()
+
+	get := func(path string, headers map[string]string) *http.Response {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	resp := get("/items", nil)
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode != http.StatusOK || len(etag) < 3 || etag[0] != '"' {
+		t.Fatalf("expect 200 with a strong etag got %d %q", resp.StatusCode, etag)
+	}
+	if resp.Header.Get("Cache-Control") != "public, max-age=60" || resp.Header.Get("Last-Modified") != modified.Format(http.TimeFormat) {
+		t.Errorf("expect cache-control and last-modified got %v", resp.Header)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		headers map[string]string
+		status  int
+	}{
+		{"etag match", "/items", map[string]string{"If-None-Match": `"other", ` + etag}, http.StatusNotModified},
+		{"weak etag match", "/items", map[string]string{"If-None-Match": "W/" + etag}, http.StatusNotModified},
+		{"etag mismatch wins over date", "/items", map[string]string{"If-None-Match": `"other"`, "If-Modified-Since": modified.Format(http.TimeFormat)}, http.StatusOK},
+		{"not modified since", "/items", map[string]string{"If-Modified-Since": modified.Add(time.Hour).Format(http.TimeFormat)}, http.StatusNotModified},
+		{"modified since", "/items", map[string]string{"If-Modified-Since": modified.Add(-time.Hour).Format(http.TimeFormat)}, http.StatusOK},
+		{"any", "/weak", map[string]string{"If-None-Match": "*"}, http.StatusNotModified},
+		{"error status", "/missing", map[string]string{"If-None-Match": "*"}, http.StatusNotFound},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if resp := get(test.path, test.headers); resp.StatusCode != test.status {
+				t.Errorf("expect %d got %d", test.status, resp.StatusCode)
+			}
+		})
+	}
+
+	resp = get("/weak", nil)
+	if etag := resp.Header.Get("ETag"); len(etag) < 2 || etag[:2] != "W/" || resp.Header.Get("Cache-Control") != "private, no-cache" {
+		t.Errorf("expect a weak etag and the route cache-control got %v", resp.Header)
+	}
+}
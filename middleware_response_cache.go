@@ -0,0 +1,235 @@
+package ihttp
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+const cacheTagsContextKey = "ihttp.cache_tags"
+
+type (
+	// ResponseCacheConfig defines the config of ResponseCache.
+	ResponseCacheConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper middleware.Skipper
+
+		// Cache is the redis shared by the instances of the service.
+		// Required.
+		Cache IRedisCache
+
+		// KeyPrefix of the cached responses and of the tag indexes.
+		// Optional. Default value DefaultResponseCacheKeyPrefix.
+		KeyPrefix string
+
+		// TTL of a cached response.
+		// Optional. Default value DefaultResponseCacheTTL.
+		TTL time.Duration
+
+		// VaryHeaders are the request headers making part of the cache key, such as Accept-Language,
+		// they are added to the Vary of the response.
+		VaryHeaders []string
+
+		// CredentialHeaders are the request headers carrying credentials, the requests with one of them
+		// are not cached unless it is one of VaryHeaders. The requests with a principal are never cached.
+		// Optional. Default value DefaultResponseCacheCredentialHeaders.
+		CredentialHeaders []string
+
+		// MaxBodySize is the largest response body to cache.
+		// Optional. Default value DefaultResponseCacheMaxBodySize.
+		MaxBodySize int
+	}
+
+	// ResponseCache caches the GET responses in redis, keyed by method, host, path, query and vary headers,
+	// the responses are tagged so they can be purged when their resources change
+	ResponseCache struct {
+		config ResponseCacheConfig
+	}
+
+	// cachedResponse is the response kept in redis
+	cachedResponse struct {
+		Status int         `json:"status"`
+		Header http.Header `json:"header"`
+		Body   []byte      `json:"body"`
+	}
+)
+
+// DefaultResponseCacheCredentialHeaders are the credential headers of ResponseCacheConfig,
+// X-API-Key is the default key lookup of APIKeyConfig
+var DefaultResponseCacheCredentialHeaders = []string{
+	echo.HeaderAuthorization,
+	"Proxy-Authorization",
+	echo.HeaderCookie,
+	"X-API-Key",
+}
+
+// NewResponseCache create the response cache of config
+func NewResponseCache(cfg ResponseCacheConfig) (*ResponseCache, error) {
+	if cfg.Cache == nil {
+		return nil, ErrResponseCacheIsRequire
+	}
+	if cfg.Skipper == nil {
+		cfg.Skipper = middleware.DefaultSkipper
+	}
+	if cfg.KeyPrefix == "" {
+		cfg.KeyPrefix = DefaultResponseCacheKeyPrefix
+	}
+	if cfg.TTL <= 0 {
+		cfg.TTL = DefaultResponseCacheTTL
+	}
+	if cfg.MaxBodySize <= 0 {
+		cfg.MaxBodySize = DefaultResponseCacheMaxBodySize
+	}
+	if cfg.CredentialHeaders == nil {
+		cfg.CredentialHeaders = DefaultResponseCacheCredentialHeaders
+	}
+	vary := make([]string, 0, len(cfg.VaryHeaders))
+	for _, name := range cfg.VaryHeaders {
+		vary = append(vary, http.CanonicalHeaderKey(name))
+	}
+	cfg.VaryHeaders = vary
+	credentials := make([]string, 0, len(cfg.CredentialHeaders))
+	for _, name := range cfg.CredentialHeaders {
+		if name = http.CanonicalHeaderKey(name); !containsAny(vary, []string{name}) {
+			credentials = append(credentials, name)
+		}
+	}
+	cfg.CredentialHeaders = credentials
+	return &ResponseCache{config: cfg}, nil
+}
+
+// CacheTags tag the response of the request in addition to the tags of the route,
+// so the handler can tag the resources it has read
+func CacheTags(c echo.Context, tags ...string) {
+	current, _ := c.Get(cacheTagsContextKey).([]string)
+	c.Set(cacheTagsContextKey, append(current, tags...))
+}
+
+// Middleware returns the middleware caching the 200 responses of the route under tags,
+// the X-Cache response header tells HIT or MISS. The responses carrying a CSP nonce of
+// SecurityHeaders are never cached.
+func (rc *ResponseCache) Middleware(tags ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := c.Request()
+			if rc.config.Skipper(c) || (req.Method != http.MethodGet && req.Method != http.MethodHead) || !rc.cacheable(c) {
+				return next(c)
+			}
+			res := c.Response()
+			for _, name := range rc.config.VaryHeaders {
+				res.Header().Add(echo.HeaderVary, name)
+			}
+			key := rc.key(req)
+			if raw, err := rc.config.Cache.Get(key); err == nil && raw != "" {
+				entry := cachedResponse{}
+				if err := json.Unmarshal([]byte(raw), &entry); err == nil {
+					for name, values := range entry.Header {
+						res.Header()[name] = values
+					}
+					res.Header().Set("X-Cache", "HIT")
+					res.WriteHeader(entry.Status)
+					_, err = res.Write(entry.Body)
+					return err
+				}
+			}
+
+			res.Header().Set("X-Cache", "MISS")
+			buffer, err := captureResponse(c, next)
+			if flushErr := buffer.flush(c); flushErr != nil {
+				return flushErr
+			}
+			// an authentication or security headers middleware after the cache set the principal or nonce during next
+			if err != nil || res.Status != http.StatusOK || buffer.body.Len() > rc.config.MaxBodySize ||
+				!rc.storable(res.Header()) || !rc.cacheable(c) {
+				return err
+			}
+			routeTags, _ := c.Get(cacheTagsContextKey).([]string)
+			// the cache is best effort, a redis failure does not fail the served response
+			_ = rc.store(key, res.Header(), buffer.body.Bytes(), append(append([]string{}, tags...), routeTags...))
+			return nil
+		}
+	}
+}
+
+// Purge delete the cached responses of the tags
+func (rc *ResponseCache) Purge(tags ...string) error {
+	for _, tag := range tags {
+		tagKey := rc.tagKey(tag)
+		keys, err := rc.config.Cache.HFields(tagKey, "*")
+		if err != nil {
+			return err
+		}
+		if err := rc.config.Cache.Del(append(keys, tagKey)...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// cacheable refuse the authenticated requests, their responses must not be served to other callers,
+// and the requests with a CSP nonce, their header and body must not be replayed to other requests
+func (rc *ResponseCache) cacheable(c echo.Context) bool {
+	if _, found := principalFrom(c); found {
+		return false
+	}
+	if nonce, _ := c.Get(cspNonceContextKey).(string); nonce != "" {
+		return false
+	}
+	for _, name := range rc.config.CredentialHeaders {
+		if c.Request().Header.Get(name) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// storable refuse the responses setting cookies or forbidding shared caches
+func (rc *ResponseCache) storable(header http.Header) bool {
+	if header.Get(echo.HeaderSetCookie) != "" {
+		return false
+	}
+	control := strings.ToLower(header.Get(echo.HeaderCacheControl))
+	return !strings.Contains(control, "no-store") && !strings.Contains(control, "private")
+}
+
+func (rc *ResponseCache) key(req *http.Request) string {
+	hash := sha256.New()
+	hash.Write([]byte(req.Method + "\n" + req.Host + "\n" + req.URL.Path + "\n" + req.URL.Query().Encode()))
+	for _, name := range rc.config.VaryHeaders {
+		hash.Write([]byte("\n" + name + ":" + strings.Join(req.Header.Values(name), ",")))
+	}
+	return rc.config.KeyPrefix + ":" + hex.EncodeToString(hash.Sum(nil))
+}
+
+func (rc *ResponseCache) tagKey(tag string) string {
+	return rc.config.KeyPrefix + ":tag:" + tag
+}
+
+func (rc *ResponseCache) store(key string, header http.Header, body []byte, tags []string) error {
+	entry := cachedResponse{Status: http.StatusOK, Header: make(http.Header), Body: body}
+	for name, values := range header {
+		if name == "X-Cache" || name == echo.HeaderXRequestID {
+			continue
+		}
+		entry.Header[name] = values
+	}
+	raw, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	if err := rc.config.Cache.SetS(key, string(raw), rc.config.TTL); err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		if err := rc.config.Cache.HSetS(rc.tagKey(tag), key, "", rc.config.TTL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,194 @@
+package ihttp_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gitkeng/ihttp"
+)
+
+func TestResponseCache(t *testing.T) {
+	if _, err := ihttp.NewResponseCache(ihttp.ResponseCacheConfig{}); err != ihttp.ErrResponseCacheIsRequire {
+		t.Errorf("expect %v got %v", ihttp.ErrResponseCacheIsRequire, err)
+	}
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer ms.Cleanup()
+	rc, err := ihttp.NewResponseCache(ihttp.ResponseCacheConfig{
+		Cache:       newMemoryCache(),
+		VaryHeaders: []string{"accept-language"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	calls := 0
+	ms.GET("/products/:id", func(ctx ihttp.IContext) error {
+		calls++
+		id := ctx.WebContext().Param("id")
+		ihttp.CacheTags(ctx.WebContext(), "product:"+id)
+		return ctx.WebContext().String(http.StatusOK, fmt.Sprintf("%s-%s-%d", id, ctx.WebContext().Request().Header.Get("Accept-Language"), calls))
+	}, rc.Middleware("products"))
+	server := httptest.NewServer(ms.GetEngine())
+	defer server.Close()
+
+	get := func(path string, headers map[string]string) (string, string) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+		for name, value := range headers {
+			req.Header.Set(name, value)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body), resp.Header.Get("X-Cache")
+	}
+
+	steps := []struct {
+		name    string
+		purge   []string
+		path    string
+		headers map[string]string
+		body    string
+		cache   string
+	}{
+		{"miss", nil, "/products/1?a=1&b=2", nil, "1--1", "MISS"},
+		{"hit with reordered query", nil, "/products/1?b=2&a=1", nil, "1--1", "HIT"},
+		{"vary header", nil, "/products/1?a=1&b=2", map[string]string{"Accept-Language": "th"}, "1-th-2", "MISS"},
+		{"other product", nil, "/products/2", nil, "2--3", "MISS"},
+		{"authorization is not cached", nil, "/products/2", map[string]string{"Authorization": "Bearer x"}, "2--4", ""},
+		{"purge product tag", []string{"product:1"}, "/products/1?a=1&b=2", nil, "1--5", "MISS"},
+		{"other tag kept", nil, "/products/2", nil, "2--3", "HIT"},
+		{"purge route tag", []string{"products"}, "/products/2", nil, "2--6", "MISS"},
+	}
+	for _, step := range steps {
+		if err := rc.Purge(step.purge...); err != nil {
+			t.Fatal(err)
+		}
+		body, cache := get(step.path, step.headers)
+		if body != step.body || cache != step.cache {
+			t.Errorf("%s: expect %s %s got %s %s", step.name, step.body, step.cache, body, cache)
+		}
+	}
+}
+
+func TestResponseCacheAuthenticated(t *testing.T) {
+	manager, err := ihttp.NewAPIKeyManager(ihttp.APIKeyManagerConfig{
+		Store: ihttp.NewRedisAPIKeyStore(newMemoryCache(), ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := map[string]string{}
+	for _, name := range []string{"alice", "bob"} {
+		_, plaintext, err := manager.Create(context.Background(), ihttp.APIKeyRequest{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys[name] = plaintext
+	}
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ms.Cleanup()
+	auth, err := ms.APIKey(ihttp.APIKeyConfig{Manager: manager, KeyLookup: "header:X-API-Key,query:api_key"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := ihttp.NewResponseCache(ihttp.ResponseCacheConfig{Cache: newMemoryCache()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := func(ctx ihttp.IContext) error {
+		principal, _ := ctx.Principal()
+		return ctx.WebContext().String(http.StatusOK, principal.Claims["name"].(string))
+	}
+	// the principal is seen before the cache on /after and only after the handler on /before
+	ms.GET("/after", handler, auth, rc.Middleware())
+	ms.GET("/before", handler, rc.Middleware(), auth)
+	server := httptest.NewServer(ms.GetEngine())
+	defer server.Close()
+
+	for _, path := range []string{"/after", "/before"} {
+		for _, lookup := range []string{"header", "query"} {
+			for _, name := range []string{"alice", "bob", "alice"} {
+				req, _ := http.NewRequest(http.MethodGet, server.URL+path, nil)
+				if lookup == "header" {
+					req.Header.Set("X-API-Key", keys[name])
+				} else {
+					req.URL.RawQuery = "api_key=" + keys[name]
+				}
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Fatal(err)
+				}
+				body, _ := io.ReadAll(resp.Body)
+				resp.Body.Close()
+				if string(body) != name || resp.Header.Get("X-Cache") == "HIT" {
+					t.Errorf("%s by %s of %s expect an uncached response got %s %s", path, lookup, name, body, resp.Header.Get("X-Cache"))
+				}
+			}
+		}
+		// a caller without key is not served the response of a key
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("%s without key expect 401 got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestResponseCacheCSPNonce(t *testing.T) {
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ms.Cleanup()
+	rc, err := ihttp.NewResponseCache(ihttp.ResponseCacheConfig{Cache: newMemoryCache()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := ihttp.DefaultSecurityHeadersConfig
+	config.ContentSecurityPolicy = "script-src 'nonce-{nonce}'"
+	headers := ihttp.SecurityHeadersWithConfig(config)
+	handler := func(ctx ihttp.IContext) error {
+		return ctx.WebContext().String(http.StatusOK, ctx.CSPNonce())
+	}
+	// the nonce is set before the cache on /after and only after the handler on /before
+	ms.GET("/after", handler, headers, rc.Middleware())
+	ms.GET("/before", handler, rc.Middleware(), headers)
+	server := httptest.NewServer(ms.GetEngine())
+	defer server.Close()
+
+	for _, path := range []string{"/after", "/before"} {
+		nonces := map[string]bool{}
+		for idx := 0; idx < 2; idx++ {
+			resp, err := http.Get(server.URL + path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.Header.Get("X-Cache") == "HIT" || !strings.Contains(resp.Header.Get("Content-Security-Policy"), string(body)) {
+				t.Errorf("%s expect an uncached response with its own nonce got %s %s", path, body, resp.Header.Get("X-Cache"))
+			}
+			nonces[string(body)] = true
+		}
+		if len(nonces) != 2 {
+			t.Errorf("%s expect a new nonce per request got %v", path, nonces)
+		}
+	}
+}
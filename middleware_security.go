@@ -0,0 +1,198 @@
+package ihttp
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"go.uber.org/zap"
+)
+
+const cspNonceContextKey = "ihttp.csp_nonce"
+
+// CSPNoncePlaceholder is replaced by the nonce of the request in ContentSecurityPolicy,
+// such as "script-src 'self' 'nonce-{nonce}'"
+const CSPNoncePlaceholder = "{nonce}"
+
+type (
+	// SecurityHeadersConfig defines the config for SecurityHeaders middleware,
+	// an empty value does not set its header.
+	SecurityHeadersConfig struct {
+		// Skipper defines a function to skip middleware.
+		Skipper middleware.Skipper
+
+		// HSTSMaxAge is the max-age (in seconds) of Strict-Transport-Security,
+		// it is set on HTTPS requests only.
+		// Optional. Default value 0, no header.
+		HSTSMaxAge            int  `yaml:"hsts_max_age"`
+		HSTSIncludeSubdomains bool `yaml:"hsts_include_subdomains"`
+		HSTSPreload           bool `yaml:"hsts_preload"`
+
+		// ContentTypeNosniff is the X-Content-Type-Options value, "nosniff".
+		ContentTypeNosniff string `yaml:"content_type_nosniff"`
+
+		// FrameOptions is the X-Frame-Options value, "DENY" or "SAMEORIGIN".
+		FrameOptions string `yaml:"frame_options"`
+
+		// ReferrerPolicy is the Referrer-Policy value.
+		ReferrerPolicy string `yaml:"referrer_policy"`
+
+		// PermissionsPolicy is the Permissions-Policy value, such as "camera=(), geolocation=()".
+		PermissionsPolicy string `yaml:"permissions_policy"`
+
+		// CrossOriginOpenerPolicy is the Cross-Origin-Opener-Policy value.
+		CrossOriginOpenerPolicy string `yaml:"cross_origin_opener_policy"`
+
+		// CrossOriginEmbedderPolicy is the Cross-Origin-Embedder-Policy value.
+		CrossOriginEmbedderPolicy string `yaml:"cross_origin_embedder_policy"`
+
+		// ContentSecurityPolicy is the Content-Security-Policy value, CSPNoncePlaceholder is replaced by
+		// a nonce generated per request, the templates read it with IContext.CSPNonce.
+		ContentSecurityPolicy string `yaml:"content_security_policy"`
+
+		// CSPReportOnly send the policy as Content-Security-Policy-Report-Only,
+		// the violations are reported without being blocked.
+		CSPReportOnly bool `yaml:"csp_report_only"`
+
+		// CSPReportURI is appended to the policy as report-uri, see Microservice.CSPReport.
+		CSPReportURI string `yaml:"csp_report_uri"`
+	}
+)
+
+var (
+	// DefaultSecurityHeadersConfig is the default SecurityHeaders middleware config.
+	DefaultSecurityHeadersConfig = SecurityHeadersConfig{
+		Skipper:                 middleware.DefaultSkipper,
+		HSTSMaxAge:              31536000,
+		HSTSIncludeSubdomains:   true,
+		ContentTypeNosniff:      "nosniff",
+		FrameOptions:            "DENY",
+		ReferrerPolicy:          "strict-origin-when-cross-origin",
+		CrossOriginOpenerPolicy: "same-origin",
+		ContentSecurityPolicy:   "default-src 'self'; object-src 'none'; base-uri 'self'; frame-ancestors 'none'",
+	}
+)
+
+// SecurityHeaders returns the middleware setting the hardening headers of DefaultSecurityHeadersConfig.
+func SecurityHeaders() echo.MiddlewareFunc {
+	return SecurityHeadersWithConfig(DefaultSecurityHeadersConfig)
+}
+
+// SecurityHeadersWithConfig returns the middleware setting the hardening headers of config.
+// See `SecurityHeaders()`.
+func SecurityHeadersWithConfig(config SecurityHeadersConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = DefaultSecurityHeadersConfig.Skipper
+	}
+	hsts := ""
+	if config.HSTSMaxAge > 0 {
+		hsts = fmt.Sprintf("max-age=%d", config.HSTSMaxAge)
+		if config.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+		if config.HSTSPreload {
+			hsts += "; preload"
+		}
+	}
+	policy := strings.TrimSpace(strings.TrimSuffix(strings.TrimSpace(config.ContentSecurityPolicy), ";"))
+	if policy != "" && config.CSPReportURI != "" {
+		policy += "; report-uri " + config.CSPReportURI
+	}
+	cspHeader := echo.HeaderContentSecurityPolicy
+	if config.CSPReportOnly {
+		cspHeader = echo.HeaderContentSecurityPolicyReportOnly
+	}
+	withNonce := strings.Contains(policy, CSPNoncePlaceholder)
+
+	headers := map[string]string{
+		echo.HeaderXContentTypeOptions: config.ContentTypeNosniff,
+		echo.HeaderXFrameOptions:       config.FrameOptions,
+		echo.HeaderReferrerPolicy:      config.ReferrerPolicy,
+		"Permissions-Policy":           config.PermissionsPolicy,
+		"Cross-Origin-Opener-Policy":   config.CrossOriginOpenerPolicy,
+		"Cross-Origin-Embedder-Policy": config.CrossOriginEmbedderPolicy,
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			header := c.Response().Header()
+			for name, value := range headers {
+				if value != "" {
+					header.Set(name, value)
+				}
+			}
+			req := c.Request()
+			if hsts != "" && (req.TLS != nil || strings.EqualFold(req.Header.Get(echo.HeaderXForwardedProto), "https")) {
+				header.Set(echo.HeaderStrictTransportSecurity, hsts)
+			}
+			if policy != "" {
+				value := policy
+				if withNonce {
+					raw := make([]byte, 16)
+					if _, err := rand.Read(raw); err != nil {
+						return err
+					}
+					nonce := base64.StdEncoding.EncodeToString(raw)
+					c.Set(cspNonceContextKey, nonce)
+					value = strings.ReplaceAll(policy, CSPNoncePlaceholder, nonce)
+				}
+				header.Set(cspHeader, value)
+			}
+			return next(c)
+		}
+	}
+}
+
+// CSPNonce return the Content-Security-Policy nonce of the request, empty without SecurityHeaders
+// or a policy using CSPNoncePlaceholder
+func (ctx *HTTPContext) CSPNonce() string {
+	if ctx.ctx == nil {
+		return ""
+	}
+	nonce, _ := ctx.ctx.Get(cspNonceContextKey).(string)
+	return nonce
+}
+
+// CSPReport register the endpoint collecting the Content-Security-Policy violation reports at path,
+// the reports of the report-uri and the Reporting API formats are logged as warnings
+func (ms *Microservice) CSPReport(path string) {
+	ms.POST(path, func(ctx IContext) error {
+		raw, err := io.ReadAll(io.LimitReader(ctx.WebContext().Request().Body, DefaultCSPReportMaxBodySize))
+		if err != nil {
+			return ctx.WebContext().NoContent(http.StatusBadRequest)
+		}
+		reports := make([]map[string]any, 0)
+		legacy := struct {
+			Report map[string]any `json:"csp-report"`
+		}{}
+		if err := json.Unmarshal(raw, &legacy); err == nil && legacy.Report != nil {
+			reports = append(reports, legacy.Report)
+		} else {
+			batch := make([]struct {
+				Type string         `json:"type"`
+				Body map[string]any `json:"body"`
+			}, 0)
+			if err := json.Unmarshal(raw, &batch); err != nil {
+				return ctx.WebContext().NoContent(http.StatusBadRequest)
+			}
+			for _, report := range batch {
+				if report.Type == "csp-violation" && report.Body != nil {
+					reports = append(reports, report.Body)
+				}
+			}
+		}
+		for _, report := range reports {
+			ctx.Log(WarnLevel, "[CSP] content security policy violation", zap.Any("csp_report", report))
+		}
+		return ctx.WebContext().NoContent(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,93 @@
+package ihttp_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gitkeng/ihttp"
+)
+
+func TestSecurityHeaders(t *testing.T) {
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer ms.Cleanup()
+	config := ihttp.DefaultSecurityHeadersConfig
+	config.PermissionsPolicy = "camera=(), geolocation=()"
+	config.ContentSecurityPolicy = "default-src 'self'; script-src 'self' 'nonce-{nonce}';"
+	config.CSPReportOnly = true
+	config.CSPReportURI = "/csp-report"
+	ms.GET("/page", func(ctx ihttp.IContext) error {
+		return ctx.WebContext().String(http.StatusOK, ctx.CSPNonce())
+	}, ihttp.SecurityHeadersWithConfig(config))
+	ms.CSPReport("/csp-report")
+	server := httptest.NewServer(ms.GetEngine())
+	defer server.Close()
+
+	get := func(proto string) (*http.Response, string) {
+		req, _ := http.NewRequest(http.MethodGet, server.URL+"/page", nil)
+		if proto != "" {
+			req.Header.Set("X-Forwarded-Proto", proto)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return resp, string(body)
+	}
+
+	resp, nonce := get("https")
+	expects := map[string]string{
+		"X-Content-Type-Options":     "nosniff",
+		"X-Frame-Options":            "DENY",
+		"Referrer-Policy":            "strict-origin-when-cross-origin",
+		"Permissions-Policy":         "camera=(), geolocation=()",
+		"Cross-Origin-Opener-Policy": "same-origin",
+		"Strict-Transport-Security":  "max-age=31536000; includeSubDomains",
+		"Content-Security-Policy-Report-Only": "default-src 'self'; script-src 'self' 'nonce-" + nonce +
+			"'; report-uri /csp-report",
+	}
+	for name, expect := range expects {
+		if got := resp.Header.Get(name); got != expect {
+			t.Errorf("%s expect %q got %q", name, expect, got)
+		}
+	}
+	if nonce == "" || resp.Header.Get("Content-Security-Policy") != "" {
+		t.Errorf("expect a nonce and a report-only policy got nonce %q", nonce)
+	}
+
+	resp, other := get("")
+	if other == nonce {
+		t.Error("expect a nonce per request")
+	}
+	if resp.Header.Get("Strict-Transport-Security") != "" {
+		t.Error("expect no HSTS over plain http")
+	}
+
+	reports := []struct {
+		contentType string
+		body        string
+		status      int
+	}{
+		{"application/csp-report", `{"csp-report":{"document-uri":"https://example.com/","violated-directive":"script-src"}}`, http.StatusNoContent},
+		{"application/reports+json", `[{"type":"csp-violation","body":{"documentURL":"https://example.com/","effectiveDirective":"script-src"}}]`, http.StatusNoContent},
+		{"application/csp-report", `not json`, http.StatusBadRequest},
+	}
+	for _, report := range reports {
+		resp, err := http.Post(server.URL+"/csp-report", report.contentType, strings.NewReader(report.body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != report.status {
+			t.Errorf("report %s expect %d got %d", report.body, report.status, resp.StatusCode)
+		}
+	}
+}
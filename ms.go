@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"github.com/gitkeng/ihttp/log"
 	"github.com/gitkeng/ihttp/util/convutil"
-	"github.com/gitkeng/ihttp/util/dbutil"
 	"github.com/gitkeng/ihttp/util/id"
 	"github.com/gitkeng/ihttp/util/stringutil"
 	"github.com/labstack/echo/v4"
@@ -47,8 +46,14 @@ type IMicroservice interface {
 
 	//DB return the DBStore
 	DB(dbContextName string) (IDBStore, bool)
+	//DBStats return the statistics of every DBStore by database context name
+	DBStats() map[string]DBStoreStats
 	//Cache return the RedisCache
 	Cache(cacheContextName string) (IRedisCache, bool)
+	//Outbox return the Outbox
+	Outbox(outboxContextName string) (IOutbox, bool)
+	//Client return the configured client
+	Client(clientContextName string) (IRequester, bool)
 }
 
 // Microservice is the centralized service management
@@ -78,11 +83,21 @@ type Microservice struct {
 
 	dbStores    map[string]IDBStore
 	redisCaches map[string]IRedisCache
-
-	logConfig    ILogConfig
-	apiConfig    IAPIConfig
-	redisConfigs map[string]IRedisConfig
-	dbConfigs    map[string]IDBConfig
+	outboxes    map[string]*Outbox
+	clients     map[string]*Requester
+	// requesters pool the requesters built by IContext.Requester
+	requesters *requesterPool
+	// requesterTransport wrap the transport of every requester built by the service
+	requesterTransport func(base http.RoundTripper) http.RoundTripper
+	// authorizer decide RequirePermission and IContext.Can
+	authorizer *Authorizer
+
+	logConfig     ILogConfig
+	apiConfig     IAPIConfig
+	redisConfigs  map[string]IRedisConfig
+	dbConfigs     map[string]IDBConfig
+	outboxConfigs map[string]IOutboxConfig
+	clientConfigs map[string]IClientConfig
 
 	cleanupFuncs []CleanupFunc
 }
@@ -187,8 +202,13 @@ func New(options ...Option) (*Microservice, error) {
 		logLevel:            zapcore.DebugLevel,
 		dbStores:            make(map[string]IDBStore),
 		redisCaches:         make(map[string]IRedisCache),
+		outboxes:            make(map[string]*Outbox),
+		clients:             make(map[string]*Requester),
+		requesters:          newRequesterPool(),
 		redisConfigs:        make(map[string]IRedisConfig),
 		dbConfigs:           make(map[string]IDBConfig),
+		outboxConfigs:       make(map[string]IOutboxConfig),
+		clientConfigs:       make(map[string]IClientConfig),
 		cleanupFuncs:        make([]CleanupFunc, 0),
 	}
 
@@ -211,6 +231,14 @@ func New(options ...Option) (*Microservice, error) {
 		ms.Cleanup()
 		return nil, err
 	}
+	if err := ms.startOutboxes(); err != nil {
+		ms.Cleanup()
+		return nil, err
+	}
+	if err := ms.buildClients(); err != nil {
+		ms.Cleanup()
+		return nil, err
+	}
 
 	ms.echo = echo.New()
 	ms.echo.Validator = &Validator{}
@@ -327,6 +355,20 @@ func (ms *Microservice) Cleanup() error {
 		}
 	}
 
+	// stop the relays before closing the stores they use
+	for key, outbox := range ms.outboxes {
+		outbox.close()
+		delete(ms.outboxes, key)
+	}
+
+	for key, client := range ms.clients {
+		client.closeIdleConnections()
+		delete(ms.clients, key)
+	}
+	if ms.requesters != nil {
+		ms.requesters.close()
+	}
+
 	for key, dbStore := range ms.dbStores {
 		err := dbStore.Close()
 		if err != nil {
@@ -362,20 +404,41 @@ func (ms *Microservice) connectDB() error {
 			return err
 		}
 		ms.dbStores[dbStore.Config().GetContextName()] = dbStore
+	}
+	return nil
+}
 
-		initScripts := ms.dbConfigs[key].GetInitialScripts()
-		if len(initScripts) > 0 {
-			for _, initScript := range initScripts {
-				if sqlCmd, err := dbutil.SQLLoader(initScript); err != nil {
-					return err
-				} else {
-					if _, err := dbStore.Conn().Exec(sqlCmd); err != nil {
-						return err
-					}
-				}
-			}
+func (ms *Microservice) startOutboxes() error {
+	//setup outboxes from outboxConfigs
+	for key, _ := range ms.outboxConfigs {
+		cfg := ms.outboxConfigs[key]
+		dbStore, found := ms.dbStores[cfg.GetDBContextName()]
+		if !found {
+			return ErrOutboxDBNotfound(cfg.GetDBContextName())
+		}
+		cache, found := ms.redisCaches[cfg.GetCacheContextName()]
+		if !found {
+			return ErrOutboxCacheNotfound(cfg.GetCacheContextName())
 		}
+		outbox, err := NewOutbox(cfg, dbStore, cache)
+		if err != nil {
+			return err
+		}
+		outbox.start()
+		ms.outboxes[cfg.GetContextName()] = outbox
+	}
+	return nil
+}
 
+func (ms *Microservice) buildClients() error {
+	//setup clients from clientConfigs
+	for key, _ := range ms.clientConfigs {
+		cfg := ms.clientConfigs[key]
+		client, err := NewRequesterWithOptions(ms, cfg.GetBaseURL(), cfg.GetRequesterOptions())
+		if err != nil {
+			return err
+		}
+		ms.clients[cfg.GetContextName()] = client
 	}
 	return nil
 }
@@ -404,6 +467,12 @@ func (ms *Microservice) responseUnHealthy(resp *echo.Response, reason string) {
 	resp.Write([]byte(errMsg))
 }
 
+func (ms *Microservice) responseNotReady(resp *echo.Response, reason string) {
+	errMsg := "Service not ready because of " + reason
+	resp.WriteHeader(http.StatusServiceUnavailable)
+	resp.Write([]byte(errMsg))
+}
+
 func (ms *Microservice) DB(dbContextName string) (IDBStore, bool) {
 	if len(ms.dbStores) > 0 {
 		dbstore, found := ms.dbStores[dbContextName]
@@ -413,6 +482,36 @@ func (ms *Microservice) DB(dbContextName string) (IDBStore, bool) {
 
 }
 
+func (ms *Microservice) Outbox(outboxContextName string) (IOutbox, bool) {
+	if outbox, found := ms.outboxes[outboxContextName]; found {
+		return outbox, true
+	}
+	return nil, false
+}
+
+// Client return a copy of the configured client, the copies share the connection pool
+func (ms *Microservice) Client(clientContextName string) (IRequester, bool) {
+	if client, found := ms.clients[clientContextName]; found {
+		return client.clone(), true
+	}
+	return nil, false
+}
+
+// DBStats return the pool statistics and the per-statement metrics of every DBStore,
+// it is meant to feed a metrics endpoint
+func (ms *Microservice) DBStats() map[string]DBStoreStats {
+	stats := make(map[string]DBStoreStats, len(ms.dbStores))
+	for contextName, dbStore := range ms.dbStores {
+		stats[contextName] = dbStore.Stats()
+	}
+	return stats
+}
+
+// CircuitBreakerStats return the state of every requester circuit breaker by base URL
+func (ms *Microservice) CircuitBreakerStats() []CircuitBreakerStats {
+	return CircuitBreakerStatsAll()
+}
+
 func (ms *Microservice) Cache(cacheContextName string) (IRedisCache, bool) {
 	if len(ms.redisCaches) > 0 {
 		redis, found := ms.redisCaches[cacheContextName]
@@ -440,3 +539,8 @@ func (ms *Microservice) GetHttpsPort() int {
 		return -1
 	}
 }
+
+// Authorizer return the authorizer of the service, nil without WithAuthorizer
+func (ms *Microservice) Authorizer() *Authorizer {
+	return ms.authorizer
+}
@@ -91,20 +91,56 @@ var (
 	}
 )
 
+const (
+	OperatorEqual          Operator = "eq"
+	OperatorNotEqual       Operator = "ne"
+	OperatorGreater        Operator = "gt"
+	OperatorGreaterOrEqual Operator = "gte"
+	OperatorLess           Operator = "lt"
+	OperatorLessOrEqual    Operator = "lte"
+	OperatorIn             Operator = "in"
+	OperatorLike           Operator = "like"
+	OperatorIsNull         Operator = "is_null"
+	// OperatorRange use FromValue and ToValue, either side may be omitted
+	OperatorRange Operator = "range"
+)
+
+var (
+	QueryOperatorMap = map[string]Operator{
+		"eq":      OperatorEqual,
+		"ne":      OperatorNotEqual,
+		"gt":      OperatorGreater,
+		"gte":     OperatorGreaterOrEqual,
+		"lt":      OperatorLess,
+		"lte":     OperatorLessOrEqual,
+		"in":      OperatorIn,
+		"like":    OperatorLike,
+		"is_null": OperatorIsNull,
+		"range":   OperatorRange,
+	}
+)
+
 type (
 	Direction    string
+	Operator     string
 	IQueryFilter interface {
 		GetField() string
+		GetOperator() Operator
+		IsNot() bool
 		GetValue() any
 		GetFromValue() any
 		GetToValue() any
 	}
 
 	QueryFilter struct {
-		Field     string `json:"field,omitempty"`
-		Value     any    `json:"value,omitempty"`
-		FromValue any    `json:"from_value,omitempty"`
-		ToValue   any    `json:"to_value,omitempty"`
+		Field string `json:"field,omitempty"`
+		// Operator is optional, default is eq when Value is set otherwise range
+		Operator Operator `json:"operator,omitempty"`
+		// Not negate the filter condition
+		Not       bool `json:"not,omitempty"`
+		Value     any  `json:"value,omitempty"`
+		FromValue any  `json:"from_value,omitempty"`
+		ToValue   any  `json:"to_value,omitempty"`
 	}
 
 	IQueryOption interface {
@@ -134,6 +170,21 @@ func (filter *QueryFilter) GetField() string {
 	return strings.TrimSpace(filter.Field)
 }
 
+func (filter *QueryFilter) GetOperator() Operator {
+	operator := Operator(strings.ToLower(strings.TrimSpace(string(filter.Operator))))
+	if operator != "" {
+		return operator
+	}
+	if filter.Value == nil && (filter.FromValue != nil || filter.ToValue != nil) {
+		return OperatorRange
+	}
+	return OperatorEqual
+}
+
+func (filter *QueryFilter) IsNot() bool {
+	return filter.Not
+}
+
 func (filter *QueryFilter) GetValue() any {
 	if value, ok := filter.Value.(string); ok {
 		return strings.TrimSpace(value)
@@ -176,7 +227,10 @@ func (order *QueryOrder) GetField() string {
 }
 
 func (order *QueryOrder) GetOrder() Direction {
-	return order.Order
+	if order.Order == "" {
+		return Ascending
+	}
+	return Direction(strings.ToLower(string(order.Order)))
 }
 
 type FilterRequest struct {
@@ -185,6 +239,30 @@ type FilterRequest struct {
 }
 
 func (req *FilterRequest) Validate() error {
+	for idx := range req.Filters {
+		filter := &req.Filters[idx]
+		if stringutil.IsEmptyString(filter.GetField()) {
+			return ErrQueryFieldIsRequire
+		}
+		if _, ok := QueryOperatorMap[string(filter.GetOperator())]; !ok {
+			return ErrInvalidQueryOperator(filter.GetField(), filter.GetOperator())
+		}
+	}
+	if req.Option.Limit < 0 {
+		return ErrInvalidQueryLimit(req.Option.Limit)
+	}
+	if req.Option.Offset < 0 {
+		return ErrInvalidQueryOffset(req.Option.Offset)
+	}
+	for idx := range req.Option.Sort {
+		order := &req.Option.Sort[idx]
+		if stringutil.IsEmptyString(order.GetField()) {
+			return ErrQueryFieldIsRequire
+		}
+		if _, ok := QueryOrderDirectionMap[string(order.GetOrder())]; !ok {
+			return ErrInvalidQueryDirection(order.GetField(), order.Order)
+		}
+	}
 	return nil
 }
 
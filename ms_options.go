@@ -4,6 +4,7 @@ import (
 	"github.com/gitkeng/ihttp/util/stringutil"
 	"github.com/labstack/echo/v4"
 	"go.uber.org/zap/zapcore"
+	"net/http"
 	"strings"
 )
 
@@ -155,6 +156,71 @@ func WithRedisConfigs(confs ...IRedisConfig) Option {
 	}
 }
 
+// WithOutboxConfigs is the option for setting outbox config,
+// the database and redis cache of each outbox must be configured too
+func WithOutboxConfigs(confs ...IOutboxConfig) Option {
+	return func(ms *Microservice) error {
+		if len(confs) == 0 {
+			return ErrOutboxConfigsIsRequire
+		}
+		if ms.outboxConfigs == nil {
+			ms.outboxConfigs = make(map[string]IOutboxConfig, 0)
+		}
+		for idx, _ := range confs {
+			if err := confs[idx].Bind(); err != nil {
+				return err
+			}
+			if err := confs[idx].Validate(); err != nil {
+				return err
+			}
+			ms.outboxConfigs[confs[idx].GetContextName()] = confs[idx]
+		}
+		return nil
+	}
+}
+
+// WithClientConfigs is the option for setting the downstream clients built at start
+func WithClientConfigs(confs ...IClientConfig) Option {
+	return func(ms *Microservice) error {
+		if len(confs) == 0 {
+			return ErrClientConfigsIsRequire
+		}
+		if ms.clientConfigs == nil {
+			ms.clientConfigs = make(map[string]IClientConfig, 0)
+		}
+		for idx, _ := range confs {
+			if err := confs[idx].Bind(); err != nil {
+				return err
+			}
+			if err := confs[idx].Validate(); err != nil {
+				return err
+			}
+			ms.clientConfigs[confs[idx].GetContextName()] = confs[idx]
+		}
+		return nil
+	}
+}
+
+// WithRequesterTransport is the option for wrapping the transport of every requester built by the service,
+// such as the record/replay transport of ihttptest in handler tests
+func WithRequesterTransport(wrap func(base http.RoundTripper) http.RoundTripper) Option {
+	return func(ms *Microservice) error {
+		ms.requesterTransport = wrap
+		return nil
+	}
+}
+
+// WithAuthorizer is the option for setting the authorizer of RequirePermission and IContext.Can
+func WithAuthorizer(authorizer *Authorizer) Option {
+	return func(ms *Microservice) error {
+		if authorizer == nil {
+			return ErrAuthorizerNotfound
+		}
+		ms.authorizer = authorizer
+		return nil
+	}
+}
+
 // WithHealthChecks is the option for setting health check functions
 func WithHealthChecks(healthFuncs ...HealthCheckFunc) Option {
 	return func(ms *Microservice) error {
@@ -0,0 +1,348 @@
+package ihttp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gitkeng/ihttp/log"
+	"github.com/gitkeng/ihttp/util/retryutil"
+	"github.com/gitkeng/ihttp/util/stringutil"
+)
+
+// IOutbox write events in the caller's database transaction and relay them to redis after commit.
+//
+// Delivery is at-least-once: an event is published before its delivered mark is committed,
+// so a crash in between publish it again and consumers should dedupe by OutboxMessage.ID.
+type IOutbox interface {
+	// Publish insert the event into the outbox table within tx,
+	// tx should be the DBTx of the business write so both commit or rollback together
+	Publish(ctx context.Context, tx IDBExecutor, topic string, key string, payload any) error
+	// Relay publish the pending events once and return the number delivered
+	Relay(ctx context.Context) (int, error)
+	// Purge delete the delivered events older than the retention and return the number deleted
+	Purge(ctx context.Context) (int64, error)
+	// Config return outbox config
+	Config() IOutboxConfig
+}
+
+// OutboxMessage is the message published to the redis channel in pubsub mode,
+// in stream mode the same fields are the stream entry values
+type OutboxMessage struct {
+	ID        int64           `json:"id"`
+	Topic     string          `json:"topic"`
+	Key       string          `json:"key,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type outboxEvent struct {
+	ID            int64     `db:"id"`
+	Topic         string    `db:"topic"`
+	EventKey      string    `db:"event_key"`
+	Payload       string    `db:"payload"`
+	Attempts      int       `db:"attempts"`
+	CreatedAt     time.Time `db:"created_at"`
+	NextAttemptAt time.Time `db:"next_attempt_at"`
+}
+
+type Outbox struct {
+	config  IOutboxConfig
+	db      IDBStore
+	cache   IRedisCache
+	backoff *retryutil.Backoff
+	stop    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// NewOutbox return new Outbox of the database and redis cache, it does not start the relay
+func NewOutbox(cfg IOutboxConfig, db IDBStore, cache IRedisCache) (*Outbox, error) {
+	if cfg == nil {
+		return nil, ErrOutboxConfigsIsRequire
+	}
+	if db == nil {
+		return nil, ErrOutboxDBNotfound(cfg.GetDBContextName())
+	}
+	if cache == nil {
+		return nil, ErrOutboxCacheNotfound(cfg.GetCacheContextName())
+	}
+	outbox := &Outbox{
+		config: cfg,
+		db:     db,
+		cache:  cache,
+		backoff: retryutil.NewBackoff(
+			time.Millisecond*time.Duration(cfg.GetRetryInitialInterval()),
+			time.Millisecond*time.Duration(cfg.GetRetryMaxInterval()),
+		),
+		stop: make(chan struct{}),
+	}
+	if cfg.IsAutoCreateTable() {
+		if err := outbox.CreateTable(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+	return outbox, nil
+}
+
+// OutboxSchema return the statements creating the outbox table for the database provider
+func OutboxSchema(provider string, table string) ([]string, error) {
+	index := strings.ReplaceAll(table, ".", "_") + "_pending_idx"
+	switch provider {
+	case POSTGRES:
+		return []string{
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    id              BIGSERIAL PRIMARY KEY,
+    topic           VARCHAR(255) NOT NULL,
+    event_key       VARCHAR(255) NOT NULL DEFAULT '',
+    payload         TEXT NOT NULL,
+    attempts        INT NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    created_at      TIMESTAMP NOT NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    delivered_at    TIMESTAMP
+)`, table),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (delivered_at, id)", index, table),
+		}, nil
+	case MYSQL:
+		return []string{
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    id              BIGINT AUTO_INCREMENT PRIMARY KEY,
+    topic           VARCHAR(255) NOT NULL,
+    event_key       VARCHAR(255) NOT NULL DEFAULT '',
+    payload         LONGTEXT NOT NULL,
+    attempts        INT NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    created_at      DATETIME(6) NOT NULL,
+    next_attempt_at DATETIME(6) NOT NULL,
+    delivered_at    DATETIME(6) NULL,
+    INDEX %s (delivered_at, id)
+)`, table, index),
+		}, nil
+	case SQLITE:
+		return []string{
+			fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+    id              INTEGER PRIMARY KEY AUTOINCREMENT,
+    topic           TEXT NOT NULL,
+    event_key       TEXT NOT NULL DEFAULT '',
+    payload         TEXT NOT NULL,
+    attempts        INTEGER NOT NULL DEFAULT 0,
+    last_error      TEXT,
+    created_at      TIMESTAMP NOT NULL,
+    next_attempt_at TIMESTAMP NOT NULL,
+    delivered_at    TIMESTAMP
+)`, table),
+			fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (delivered_at, id)", index, table),
+		}, nil
+	}
+	return nil, ErrInvalidDBProvider(provider)
+}
+
+// CreateTable create the outbox table when it does not exist
+func (outbox *Outbox) CreateTable(ctx context.Context) error {
+	statements, err := OutboxSchema(outbox.db.Provider(), outbox.config.GetTable())
+	if err != nil {
+		return err
+	}
+	for _, statement := range statements {
+		if _, err := outbox.db.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (outbox *Outbox) Config() IOutboxConfig {
+	return outbox.config
+}
+
+func (outbox *Outbox) Publish(ctx context.Context, tx IDBExecutor, topic string, key string, payload any) error {
+	if stringutil.IsEmptyString(topic) {
+		return ErrOutboxTopicIsRequire
+	}
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+	_, err = Exec(ctx, tx, fmt.Sprintf(
+		"INSERT INTO %s (topic, event_key, payload, attempts, created_at, next_attempt_at) VALUES (:topic, :key, :payload, 0, :now, :now)",
+		outbox.config.GetTable()), map[string]any{
+		"topic":   topic,
+		"key":     key,
+		"payload": string(raw),
+		"now":     now,
+	})
+	return err
+}
+
+// Relay lock a batch of pending events in id order, publish them and mark them delivered.
+//
+// Rows are locked with FOR UPDATE SKIP LOCKED on postgres and mysql so every replica of the
+// service can run the relay. The batch stop at the first event that is not due or fail,
+// a later event is never published ahead of an earlier one by the same relay. An event
+// that fail MaxAttempts times is left in the table with its last error and skipped.
+func (outbox *Outbox) Relay(ctx context.Context) (int, error) {
+	if err := outbox.db.Ready(); err != nil {
+		return 0, err
+	}
+	tx, err := outbox.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	lock := ""
+	if outbox.db.Provider() != SQLITE {
+		lock = " FOR UPDATE SKIP LOCKED"
+	}
+	table := outbox.config.GetTable()
+	events, err := QueryAll[outboxEvent](ctx, tx, fmt.Sprintf(
+		"SELECT id, topic, event_key, payload, attempts, created_at, next_attempt_at FROM %s WHERE delivered_at IS NULL AND attempts < :max_attempts ORDER BY id LIMIT :limit%s",
+		table, lock), map[string]any{
+		"max_attempts": outbox.config.GetMaxAttempts(),
+		"limit":        outbox.config.GetBatchSize(),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	now := time.Now().UTC()
+	for _, event := range events {
+		if event.NextAttemptAt.After(now) {
+			break
+		}
+		if err := outbox.publish(event); err != nil {
+			attempts := event.Attempts + 1
+			nextAttemptAt := now.Add(outbox.backoff.Next(event.Attempts))
+			if _, updateErr := Exec(ctx, tx, fmt.Sprintf(
+				"UPDATE %s SET attempts = :attempts, next_attempt_at = :next_attempt_at, last_error = :last_error WHERE id = :id",
+				table), map[string]any{
+				"attempts":        attempts,
+				"next_attempt_at": nextAttemptAt,
+				"last_error":      err.Error(),
+				"id":              event.ID,
+			}); updateErr != nil {
+				return delivered, updateErr
+			}
+			if attempts >= outbox.config.GetMaxAttempts() {
+				log.Errorf("outbox context name %s event %d to %s fail after %d attempts with err %s",
+					outbox.config.GetContextName(), event.ID, event.Topic, attempts, err.Error())
+			} else {
+				log.Warnf("outbox context name %s event %d to %s fail with err %s, retry at %s",
+					outbox.config.GetContextName(), event.ID, event.Topic, err.Error(), nextAttemptAt.Format(time.RFC3339))
+			}
+			break
+		}
+		if _, err := Exec(ctx, tx, fmt.Sprintf(
+			"UPDATE %s SET attempts = attempts + 1, delivered_at = :now WHERE id = :id", table),
+			map[string]any{
+				"now": now,
+				"id":  event.ID,
+			}); err != nil {
+			return delivered, err
+		}
+		delivered++
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return delivered, nil
+}
+
+func (outbox *Outbox) publish(event outboxEvent) error {
+	createdAt := event.CreatedAt.UTC()
+	if outbox.config.GetMode() == OutboxModeStream {
+		_, err := outbox.cache.XAdd(event.Topic, map[string]interface{}{
+			"id":         strconv.FormatInt(event.ID, 10),
+			"key":        event.EventKey,
+			"payload":    event.Payload,
+			"created_at": createdAt.Format(time.RFC3339Nano),
+		}, outbox.config.GetStreamMaxLen())
+		return err
+	}
+	message, err := json.Marshal(OutboxMessage{
+		ID:        event.ID,
+		Topic:     event.Topic,
+		Key:       event.EventKey,
+		Payload:   json.RawMessage(event.Payload),
+		CreatedAt: createdAt,
+	})
+	if err != nil {
+		return err
+	}
+	return outbox.cache.Pub(event.Topic, string(message))
+}
+
+func (outbox *Outbox) Purge(ctx context.Context) (int64, error) {
+	if err := outbox.db.Ready(); err != nil {
+		return 0, err
+	}
+	before := time.Now().UTC().Add(-time.Second * time.Duration(outbox.config.GetRetention()))
+	result, err := Exec(ctx, outbox.db, fmt.Sprintf(
+		"DELETE FROM %s WHERE delivered_at IS NOT NULL AND delivered_at < :before", outbox.config.GetTable()),
+		map[string]any{
+			"before": before,
+		})
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// start run the relay and the cleanup in background until close
+func (outbox *Outbox) start() {
+	outbox.wg.Add(1)
+	go func() {
+		defer outbox.wg.Done()
+		poll := time.NewTicker(time.Millisecond * time.Duration(outbox.config.GetPollInterval()))
+		defer poll.Stop()
+		cleanup := time.NewTicker(time.Second * time.Duration(outbox.config.GetCleanupInterval()))
+		defer cleanup.Stop()
+		for {
+			select {
+			case <-outbox.stop:
+				return
+			case <-poll.C:
+				outbox.drain()
+			case <-cleanup.C:
+				if _, err := outbox.Purge(context.Background()); err != nil {
+					log.Warnf("outbox context name %s cleanup fail with err %s", outbox.config.GetContextName(), err.Error())
+				}
+			}
+		}
+	}()
+}
+
+// drain relay full batches until the backlog is empty
+func (outbox *Outbox) drain() {
+	for {
+		delivered, err := outbox.Relay(context.Background())
+		if err != nil {
+			log.Warnf("outbox context name %s relay fail with err %s", outbox.config.GetContextName(), err.Error())
+			return
+		}
+		if delivered < outbox.config.GetBatchSize() {
+			return
+		}
+		select {
+		case <-outbox.stop:
+			return
+		default:
+		}
+	}
+}
+
+// close stop the background relay and wait for the running batch
+func (outbox *Outbox) close() {
+	outbox.once.Do(func() {
+		close(outbox.stop)
+	})
+	outbox.wg.Wait()
+}
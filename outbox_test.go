@@ -0,0 +1,115 @@
+package ihttp_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/gitkeng/ihttp"
+)
+
+type fakeOutboxCache struct {
+	ihttp.IRedisCache
+	fail     bool
+	messages []string
+}
+
+func (cache *fakeOutboxCache) Pub(channel string, message interface{}) error {
+	if cache.fail {
+		return errors.New("redis unavailable")
+	}
+	cache.messages = append(cache.messages, message.(string))
+	return nil
+}
+
+func TestOutboxRelay(t *testing.T) {
+	dbCfg := &ihttp.DBConfig{
+		ContextName:  "local",
+		Provider:     ihttp.SQLITE,
+		DatabaseName: ":memory:",
+	}
+	if err := dbCfg.Bind(); err != nil {
+		t.Error(err)
+		return
+	}
+	store, err := ihttp.NewDBStore(dbCfg)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer store.Close()
+
+	outboxCfg := &ihttp.OutboxConfig{
+		DBContextName:    "local",
+		CacheContextName: "cache",
+		AutoCreateTable:  true,
+	}
+	if err := outboxCfg.Bind(); err != nil {
+		t.Error(err)
+		return
+	}
+	if err := outboxCfg.Validate(); err != nil {
+		t.Error(err)
+		return
+	}
+	cache := &fakeOutboxCache{fail: true}
+	outbox, err := ihttp.NewOutbox(outboxCfg, store, cache)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	ctx := context.Background()
+	tx, err := store.BeginTx(ctx, nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	for _, name := range []string{"somchai", "somsri"} {
+		if err := outbox.Publish(ctx, tx, "user.created", name, map[string]string{"name": name}); err != nil {
+			t.Error(err)
+			return
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		t.Error(err)
+		return
+	}
+
+	// a failed publish is scheduled for retry and stop the batch
+	delivered, err := outbox.Relay(ctx)
+	if err != nil || delivered != 0 {
+		t.Errorf("expect 0 delivered got %d err %v", delivered, err)
+		return
+	}
+	if _, err := store.ExecContext(ctx, "UPDATE outbox SET next_attempt_at = created_at"); err != nil {
+		t.Error(err)
+		return
+	}
+
+	cache.fail = false
+	delivered, err = outbox.Relay(ctx)
+	if err != nil || delivered != 2 {
+		t.Errorf("expect 2 delivered got %d err %v", delivered, err)
+		return
+	}
+	for idx, raw := range cache.messages {
+		var message ihttp.OutboxMessage
+		if err := json.Unmarshal([]byte(raw), &message); err != nil {
+			t.Error(err)
+			return
+		}
+		if message.ID != int64(idx+1) || message.Topic != "user.created" {
+			t.Errorf("unexpected message %s", raw)
+		}
+		t.Logf("%s", raw)
+	}
+
+	if delivered, _ := outbox.Relay(ctx); delivered != 0 {
+		t.Errorf("expect nothing left to deliver got %d", delivered)
+	}
+	if purged, err := outbox.Purge(ctx); err != nil || purged != 0 {
+		t.Errorf("expect nothing purged within retention got %d err %v", purged, err)
+	}
+}
@@ -0,0 +1,259 @@
+package ihttp
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// QueryBuilder turn FilterRequest into parameterized WHERE/ORDER BY/LIMIT/OFFSET clauses.
+//
+// Only fields listed in the column whitelist can be filtered or sorted,
+// API field names never reach the SQL text.
+type QueryBuilder struct {
+	provider string
+	columns  map[string]string
+	maxLimit int
+}
+
+// QueryBuilderOption is the option for setting QueryBuilder
+type QueryBuilderOption func(builder *QueryBuilder)
+
+// WithQueryMaxLimit cap the page size, a request without limit get maxLimit rows
+func WithQueryMaxLimit(maxLimit int) QueryBuilderOption {
+	return func(builder *QueryBuilder) {
+		builder.maxLimit = maxLimit
+	}
+}
+
+// NewQueryBuilder return new QueryBuilder for database provider (postgres, mysql or sqlite),
+// columns map the API field name to the table column
+func NewQueryBuilder(provider string, columns map[string]string, options ...QueryBuilderOption) (*QueryBuilder, error) {
+	provider = strings.ToLower(provider)
+	if provider != POSTGRES && provider != MYSQL && provider != SQLITE {
+		return nil, ErrInvalidDBProvider(provider)
+	}
+	if len(columns) == 0 {
+		return nil, ErrQueryBuilderColumnsIsRequire
+	}
+	builder := &QueryBuilder{
+		provider: provider,
+		columns:  columns,
+	}
+	for _, setter := range options {
+		if setter != nil {
+			setter(builder)
+		}
+	}
+	return builder, nil
+}
+
+// SQLQuery is the output of QueryBuilder
+type SQLQuery struct {
+	// Where is the WHERE clause include keyword, empty when no filter
+	Where string
+	// OrderBy is the ORDER BY clause include keyword, empty when no sort
+	OrderBy string
+	// Paging is the LIMIT/OFFSET clause, empty when no limit and offset
+	Paging string
+	// Args is the arguments of Where
+	Args []any
+	// PagingArgs is the arguments of Paging
+	PagingArgs []any
+}
+
+// Select append the clauses to the select statement (SELECT ... FROM ...)
+// and return the statement with its arguments
+func (query *SQLQuery) Select(selectFrom string) (string, []any) {
+	var sb strings.Builder
+	sb.WriteString(selectFrom)
+	for _, clause := range []string{query.Where, query.OrderBy, query.Paging} {
+		if clause != "" {
+			sb.WriteString(" ")
+			sb.WriteString(clause)
+		}
+	}
+	args := make([]any, 0, len(query.Args)+len(query.PagingArgs))
+	args = append(args, query.Args...)
+	args = append(args, query.PagingArgs...)
+	return sb.String(), args
+}
+
+// Count return the total rows statement for pagination, from is the table or join expression
+func (query *SQLQuery) Count(from string) (string, []any) {
+	statement := fmt.Sprintf("SELECT COUNT(*) FROM %s", from)
+	if query.Where != "" {
+		statement += " " + query.Where
+	}
+	return statement, query.Args
+}
+
+// Build validate the request and build the clauses
+func (builder *QueryBuilder) Build(req *FilterRequest) (*SQLQuery, error) {
+	query := &SQLQuery{
+		Args:       make([]any, 0),
+		PagingArgs: make([]any, 0),
+	}
+	if req == nil {
+		req = &FilterRequest{}
+	}
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+
+	conditions := make([]string, 0)
+	for _, filter := range req.GetFilters() {
+		condition, err := builder.condition(filter, &query.Args)
+		if err != nil {
+			return nil, err
+		}
+		conditions = append(conditions, condition)
+	}
+	if len(conditions) > 0 {
+		query.Where = "WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	option := req.GetOption()
+	orders := make([]string, 0)
+	for _, order := range option.GetSort() {
+		column, err := builder.column(order.GetField())
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, fmt.Sprintf("%s %s", column, strings.ToUpper(string(order.GetOrder()))))
+	}
+	if len(orders) > 0 {
+		query.OrderBy = "ORDER BY " + strings.Join(orders, ", ")
+	}
+
+	limit := option.GetLimit()
+	if builder.maxLimit > 0 && (limit <= 0 || limit > builder.maxLimit) {
+		limit = builder.maxLimit
+	}
+	paging := make([]string, 0)
+	if limit > 0 {
+		query.PagingArgs = append(query.PagingArgs, limit)
+		paging = append(paging, "LIMIT "+builder.placeholder(len(query.Args)+len(query.PagingArgs)))
+	}
+	if offset := option.GetOffset(); offset > 0 {
+		if limit <= 0 {
+			// mysql and sqlite do not accept OFFSET without LIMIT
+			switch builder.provider {
+			case MYSQL:
+				paging = append(paging, "LIMIT 18446744073709551615")
+			case SQLITE:
+				paging = append(paging, "LIMIT -1")
+			}
+		}
+		query.PagingArgs = append(query.PagingArgs, offset)
+		paging = append(paging, "OFFSET "+builder.placeholder(len(query.Args)+len(query.PagingArgs)))
+	}
+	query.Paging = strings.Join(paging, " ")
+
+	return query, nil
+}
+
+func (builder *QueryBuilder) column(field string) (string, error) {
+	column, ok := builder.columns[field]
+	if !ok {
+		return "", ErrQueryFieldNotAllowed(field)
+	}
+	return column, nil
+}
+
+func (builder *QueryBuilder) placeholder(position int) string {
+	if builder.provider == POSTGRES {
+		return fmt.Sprintf("$%d", position)
+	}
+	return "?"
+}
+
+func (builder *QueryBuilder) bind(args *[]any, value any) string {
+	*args = append(*args, value)
+	return builder.placeholder(len(*args))
+}
+
+func (builder *QueryBuilder) condition(filter IQueryFilter, args *[]any) (string, error) {
+	field := filter.GetField()
+	column, err := builder.column(field)
+	if err != nil {
+		return "", err
+	}
+	operator := filter.GetOperator()
+	value := filter.GetValue()
+
+	var condition string
+	switch operator {
+	case OperatorEqual, OperatorNotEqual, OperatorGreater, OperatorGreaterOrEqual, OperatorLess, OperatorLessOrEqual:
+		if value == nil {
+			return "", ErrInvalidQueryValue(field, operator)
+		}
+		condition = fmt.Sprintf("%s %s %s", column, sqlComparison[operator], builder.bind(args, value))
+	case OperatorLike:
+		pattern, ok := value.(string)
+		if !ok {
+			return "", ErrInvalidQueryValue(field, operator)
+		}
+		condition = fmt.Sprintf("%s LIKE %s", column, builder.bind(args, pattern))
+	case OperatorIn:
+		values, ok := toSlice(value)
+		if !ok || len(values) == 0 {
+			return "", ErrInvalidQueryValue(field, operator)
+		}
+		placeholders := make([]string, 0, len(values))
+		for _, item := range values {
+			placeholders = append(placeholders, builder.bind(args, item))
+		}
+		condition = fmt.Sprintf("%s IN (%s)", column, strings.Join(placeholders, ", "))
+	case OperatorIsNull:
+		condition = fmt.Sprintf("%s IS NULL", column)
+	case OperatorRange:
+		from, to := filter.GetFromValue(), filter.GetToValue()
+		switch {
+		case from != nil && to != nil:
+			condition = fmt.Sprintf("%s BETWEEN %s AND %s", column, builder.bind(args, from), builder.bind(args, to))
+		case from != nil:
+			condition = fmt.Sprintf("%s >= %s", column, builder.bind(args, from))
+		case to != nil:
+			condition = fmt.Sprintf("%s <= %s", column, builder.bind(args, to))
+		default:
+			return "", ErrInvalidQueryValue(field, operator)
+		}
+	default:
+		return "", ErrInvalidQueryOperator(field, operator)
+	}
+
+	if filter.IsNot() {
+		return fmt.Sprintf("NOT (%s)", condition), nil
+	}
+	return condition, nil
+}
+
+var sqlComparison = map[Operator]string{
+	OperatorEqual:          "=",
+	OperatorNotEqual:       "<>",
+	OperatorGreater:        ">",
+	OperatorGreaterOrEqual: ">=",
+	OperatorLess:           "<",
+	OperatorLessOrEqual:    "<=",
+}
+
+// toSlice convert any slice or array value to []any
+func toSlice(value any) ([]any, bool) {
+	if value == nil {
+		return nil, false
+	}
+	rv := reflect.ValueOf(value)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, false
+	}
+	values := make([]any, 0, rv.Len())
+	for idx := 0; idx < rv.Len(); idx++ {
+		item := rv.Index(idx).Interface()
+		if str, ok := item.(string); ok {
+			item = strings.TrimSpace(str)
+		}
+		values = append(values, item)
+	}
+	return values, true
+}
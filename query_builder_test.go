@@ -0,0 +1,109 @@
+package ihttp_test
+
+import (
+	"testing"
+
+	"github.com/gitkeng/ihttp"
+)
+
+var queryColumns = map[string]string{
+	"name":       "u.name",
+	"status":     "u.status",
+	"created_at": "u.created_at",
+	"deleted_at": "u.deleted_at",
+}
+
+func queryRequest() *ihttp.FilterRequest {
+	return &ihttp.FilterRequest{
+		Filters: []ihttp.QueryFilter{
+			{Field: "name", Operator: ihttp.OperatorLike, Value: "som%"},
+			{Field: "status", Operator: ihttp.OperatorIn, Value: []any{"active", "pending"}},
+			{Field: "created_at", FromValue: "2023-01-01", ToValue: "2023-12-31"},
+			{Field: "deleted_at", Operator: ihttp.OperatorIsNull, Not: true},
+		},
+		Option: ihttp.QueryOption{
+			Limit:  20,
+			Offset: 40,
+			Sort: []ihttp.QueryOrder{
+				{Field: "created_at", Order: ihttp.Descending},
+				{Field: "name"},
+			},
+		},
+	}
+}
+
+func TestQueryBuilderPostgres(t *testing.T) {
+	builder, err := ihttp.NewQueryBuilder(ihttp.POSTGRES, queryColumns)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	query, err := builder.Build(queryRequest())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	statement, args := query.Select("SELECT * FROM users u")
+	expect := "SELECT * FROM users u WHERE u.name LIKE $1 AND u.status IN ($2, $3) AND u.created_at BETWEEN $4 AND $5 AND NOT (u.deleted_at IS NULL) ORDER BY u.created_at DESC, u.name ASC LIMIT $6 OFFSET $7"
+	if statement != expect {
+		t.Errorf("got %s want %s", statement, expect)
+	}
+	if len(args) != 7 {
+		t.Errorf("got %d args want 7", len(args))
+	}
+
+	count, countArgs := query.Count("users u")
+	expect = "SELECT COUNT(*) FROM users u WHERE u.name LIKE $1 AND u.status IN ($2, $3) AND u.created_at BETWEEN $4 AND $5 AND NOT (u.deleted_at IS NULL)"
+	if count != expect {
+		t.Errorf("got %s want %s", count, expect)
+	}
+	if len(countArgs) != 5 {
+		t.Errorf("got %d args want 5", len(countArgs))
+	}
+}
+
+func TestQueryBuilderMySQL(t *testing.T) {
+	builder, err := ihttp.NewQueryBuilder(ihttp.MYSQL, queryColumns, ihttp.WithQueryMaxLimit(10))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	query, err := builder.Build(queryRequest())
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	statement, args := query.Select("SELECT * FROM users u")
+	expect := "SELECT * FROM users u WHERE u.name LIKE ? AND u.status IN (?, ?) AND u.created_at BETWEEN ? AND ? AND NOT (u.deleted_at IS NULL) ORDER BY u.created_at DESC, u.name ASC LIMIT ? OFFSET ?"
+	if statement != expect {
+		t.Errorf("got %s want %s", statement, expect)
+	}
+	if limit := args[len(args)-2]; limit != 10 {
+		t.Errorf("got limit %v want 10", limit)
+	}
+}
+
+func TestQueryBuilderReject(t *testing.T) {
+	builder, err := ihttp.NewQueryBuilder(ihttp.POSTGRES, queryColumns)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	requests := map[string]*ihttp.FilterRequest{
+		"unknown field": {Filters: []ihttp.QueryFilter{{Field: "password", Value: "x"}}},
+		"bad operator":  {Filters: []ihttp.QueryFilter{{Field: "name", Operator: "regex", Value: "x"}}},
+		"bad in value":  {Filters: []ihttp.QueryFilter{{Field: "status", Operator: ihttp.OperatorIn, Value: "active"}}},
+		"bad direction": {Option: ihttp.QueryOption{Sort: []ihttp.QueryOrder{{Field: "name", Order: "sideways"}}}},
+		"unknown sort":  {Option: ihttp.QueryOption{Sort: []ihttp.QueryOrder{{Field: "1; DROP TABLE users"}}}},
+	}
+	for name, req := range requests {
+		if _, err := builder.Build(req); err == nil {
+			t.Errorf("%s: expect error", name)
+		} else {
+			t.Logf("%s: %s", name, err)
+		}
+	}
+}
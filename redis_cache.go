@@ -56,6 +56,8 @@ type IRedisCache interface {
 	Exists(key string) (bool, error)
 
 	Pub(channel string, message interface{}) error
+	// XAdd append the values to the stream and return the entry id, maxLen > 0 trim the stream approximately
+	XAdd(stream string, values map[string]interface{}, maxLen int64) (string, error)
 	Sub(channels ...string) (<-chan *redis.Message, string /*subID used for close*/, error)
 	Unsub(subID string) error
 	Open() error
@@ -1372,6 +1374,44 @@ func (cache *RedisCache) Autonumbers(name string, n int) ([]int, error) {
 	return ress, nil
 }
 
+// XAdd will append the values to the stream, the stream is trimmed to about maxLen entries when maxLen > 0
+func (cache *RedisCache) XAdd(stream string, values map[string]interface{}, maxLen int64) (string, error) {
+
+	c, err := cache.getClient()
+	if err != nil {
+		return "", err
+	}
+
+	args := &redis.XAddArgs{
+		Stream: stream,
+		Values: values,
+	}
+	if maxLen > 0 {
+		args.MaxLen = maxLen
+		args.Approx = true
+	}
+
+	retriesDelayMs := cache.getRetriesDelayInMs()
+	retries := -1
+	for {
+		retries++
+		if retries > len(retriesDelayMs)-1 {
+			return "", fmt.Errorf("RedisCache: retry exceed limits")
+		}
+
+		id, err := c.XAdd(context.Background(), args).Result()
+		if err != nil {
+			if cache.isNoConnectionError(err) {
+				time.Sleep(time.Millisecond * time.Duration(retriesDelayMs[retries]))
+				continue
+			}
+			return "", err
+		}
+
+		return id, nil
+	}
+}
+
 // Pub will publish to subscriber
 func (cache *RedisCache) Pub(channel string, message interface{}) error {
 
@@ -0,0 +1,163 @@
+package ihttp_test
+
+import (
+	"path"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gitkeng/ihttp"
+)
+
+// memoryCache is the in-memory IRedisCache of the tests, it implement the string and hash commands,
+// the expiry and Del of a key apply to its string value or its whole hash as in redis
+type memoryCache struct {
+	ihttp.IRedisCache
+	mutex   sync.Mutex
+	values  map[string]string
+	hashes  map[string]map[string]string
+	expires map[string]time.Time
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{
+		values:  make(map[string]string),
+		hashes:  make(map[string]map[string]string),
+		expires: make(map[string]time.Time),
+	}
+}
+
+// expire drop the key when it is expired, it must be called with the mutex held
+func (cache *memoryCache) expire(key string) {
+	if expire, found := cache.expires[key]; found && time.Now().After(expire) {
+		cache.del(key)
+	}
+}
+
+// del must be called with the mutex held
+func (cache *memoryCache) del(key string) {
+	delete(cache.values, key)
+	delete(cache.hashes, key)
+	delete(cache.expires, key)
+}
+
+// get must be called with the mutex held
+func (cache *memoryCache) get(key string) (string, bool) {
+	cache.expire(key)
+	value, found := cache.values[key]
+	return value, found
+}
+
+// hash return the fields of key, it must be called with the mutex held
+func (cache *memoryCache) hash(key string, create bool) map[string]string {
+	cache.expire(key)
+	fields, found := cache.hashes[key]
+	if !found && create {
+		fields = make(map[string]string)
+		cache.hashes[key] = fields
+	}
+	return fields
+}
+
+func (cache *memoryCache) Get(key string) (string, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	value, _ := cache.get(key)
+	return value, nil
+}
+
+func (cache *memoryCache) SetS(key string, value string, expire time.Duration) error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.del(key)
+	cache.values[key] = value
+	if expire > 0 {
+		cache.expires[key] = time.Now().Add(expire)
+	}
+	return nil
+}
+
+func (cache *memoryCache) Del(keys ...string) error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	for _, key := range keys {
+		cache.del(key)
+	}
+	return nil
+}
+
+func (cache *memoryCache) Exists(key string) (bool, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	_, found := cache.get(key)
+	return found || len(cache.hashes[key]) > 0, nil
+}
+
+func (cache *memoryCache) IncrBy(key string, val int) (int, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	value, _ := cache.get(key)
+	current, _ := strconv.Atoi(value)
+	current += val
+	cache.values[key] = strconv.Itoa(current)
+	return current, nil
+}
+
+func (cache *memoryCache) Incr(key string) (int, error) {
+	return cache.IncrBy(key, 1)
+}
+
+func (cache *memoryCache) Expire(key string, expire time.Duration) error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.expires[key] = time.Now().Add(expire)
+	return nil
+}
+
+func (cache *memoryCache) HSetSNoExpire(key string, field string, value string) error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.hash(key, true)[field] = value
+	return nil
+}
+
+func (cache *memoryCache) HSetS(key string, field string, value string, expire time.Duration) error {
+	if err := cache.HSetSNoExpire(key, field, value); err != nil {
+		return err
+	}
+	if expire > 0 {
+		return cache.Expire(key, expire)
+	}
+	return nil
+}
+
+func (cache *memoryCache) HGet(key string, field string) (string, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	return cache.hash(key, false)[field], nil
+}
+
+func (cache *memoryCache) HFields(key string, pattern string) ([]string, error) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	fields := make([]string, 0)
+	for field := range cache.hash(key, false) {
+		if matched, _ := path.Match(pattern, field); matched || pattern == "" || pattern == "*" {
+			fields = append(fields, field)
+		}
+	}
+	return fields, nil
+}
+
+func (cache *memoryCache) HDel(key string, fields ...string) error {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	hash := cache.hash(key, false)
+	for _, field := range fields {
+		delete(hash, field)
+	}
+	if len(hash) == 0 {
+		cache.del(key)
+	}
+	return nil
+}
@@ -1,34 +1,103 @@
 package ihttp
 
 import (
-	"crypto/tls"
-	"crypto/x509"
+	"context"
 	"errors"
 	"fmt"
-	"github.com/gitkeng/ihttp/util/fileutil"
 	"github.com/gitkeng/ihttp/util/stringutil"
 	"github.com/go-resty/resty/v2"
-	"os"
+	"github.com/labstack/echo/v4"
+	"go.uber.org/zap"
+	"io"
+	"net/http"
 	"time"
 )
 
-// IRequester is interface to connect to HTTP endpoint
+// IRequester is interface to connect to HTTP endpoint.
+//
+// Every method return the response even when the status policy turn it into an error,
+// the error is nil only when the request succeed and the policy accept the status.
 type IRequester interface {
-	Get(path string, params map[string]string) (string, error)
-	Post(path string, params map[string]string) (string, error)
-	PostJSON(path string, body interface{}) (string, error)
-	Put(path string, params map[string]string) (string, error)
-	PutJSON(path string, body interface{}) (string, error)
-	Delete(path string, params map[string]string) (string, error)
+	Get(path string, params map[string]string) (*HTTPResponse, error)
+	Post(path string, params map[string]string) (*HTTPResponse, error)
+	PostJSON(path string, body interface{}) (*HTTPResponse, error)
+	Put(path string, params map[string]string) (*HTTPResponse, error)
+	PutJSON(path string, body interface{}) (*HTTPResponse, error)
+	Delete(path string, params map[string]string) (*HTTPResponse, error)
+	// Do send the request with method, query params and JSON body, body is nil for no body
+	Do(method string, path string, params map[string]string, body any) (*HTTPResponse, error)
+	// PostForm request using HTTP POST with form-urlencoded body
+	PostForm(path string, form map[string]string) (*HTTPResponse, error)
+	// PostMultipart upload the fields and files using HTTP POST with multipart/form-data body
+	PostMultipart(path string, fields map[string]string, files ...MultipartFile) (*HTTPResponse, error)
+	// Download stream the response body of HTTP GET to w, the returned response has no body
+	Download(path string, params map[string]string, w io.Writer, options DownloadOptions) (*HTTPResponse, error)
+	// Proxy stream the downstream status, headers and body to resp without buffering
+	Proxy(resp *echo.Response, method string, path string, params map[string]string, body any) (*HTTPResponse, error)
+	// SetStatusPolicy replace the policy deciding which status is an error, default is ErrorOnNon2xx
+	SetStatusPolicy(policy StatusPolicy)
+	// WithContext return a copy of the requester bound to ctx, the calls are cancelled with ctx
+	// and carry the request id of ctx
+	WithContext(ctx context.Context) IRequester
 	GetClient() *resty.Client
 	Request() *resty.Request
 }
 
 // Requester implement IRequester
 type Requester struct {
-	baseURL string
-	ms      *Microservice
-	client  *resty.Client
+	baseURL        string
+	ms             *Microservice
+	client         *resty.Client
+	statusPolicy   StatusPolicy
+	retry          *RetryPolicy
+	breaker        *CircuitBreaker
+	deadlineBudget time.Duration
+	// parent is the context every call derive from, it is the echo request context for requesters from IContext
+	parent context.Context
+	// headers is set on every call, it carry the propagated inbound headers
+	headers map[string]string
+	// propagateHeaders is the inbound headers forwarded when the requester is bound to IContext
+	propagateHeaders []string
+	authenticator    RequestAuthenticator
+	logger           IContextLogger
+}
+
+// RequesterOptions is the options of NewRequesterWithOptions
+type RequesterOptions struct {
+	// Timeout is the timeout of each attempt
+	Timeout time.Duration
+	// CertFiles is the additional CA certificate files trusted with the system pool
+	CertFiles []string
+	// TLS is the server verification and client certificate options
+	TLS RequesterTLSOptions
+	// Retry retry the failed calls, nil disable retry
+	Retry *RetryPolicy
+	// CircuitBreaker enable the circuit breaker of the base URL, nil disable it.
+	// The breaker is shared by every requester of the same base URL and thresholds.
+	CircuitBreaker *CircuitBreakerConfig
+	// DeadlineBudget is the total time of a call including every retry and backoff, 0 is unlimited
+	DeadlineBudget time.Duration
+	// PropagateHeaders is the inbound headers forwarded by requesters from IContext
+	// in addition to X-Request-ID, the trace context headers and the api config propagate headers
+	PropagateHeaders []string
+	// Headers is set on every call
+	Headers map[string]string
+	// MaxIdleConns is the max idle connections of all hosts, 0 use DefaultRequesterMaxIdleConns
+	MaxIdleConns int
+	// MaxIdleConnsPerHost is the max idle connections kept per host, 0 use DefaultRequesterMaxIdleConnsPerHost
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost limit the connections per host including the active ones, 0 is unlimited
+	MaxConnsPerHost int
+	// IdleConnTimeout close the connections idle longer than it, 0 use DefaultRequesterIdleConnTimeout
+	IdleConnTimeout time.Duration
+	// Authenticator add the credentials to every attempt, such as OAuth2ClientCredentials or HMACSigner
+	Authenticator RequestAuthenticator `json:"-"`
+	// WrapTransport wrap the pooled transport, such as the record/replay transport of ihttptest,
+	// nil use the transport of WithRequesterTransport
+	WrapTransport func(base http.RoundTripper) http.RoundTripper `json:"-"`
+	// PoolKey is the identity of WrapTransport in the requester pool of IContext.RequesterWithOptions,
+	// the requesters with a WrapTransport and no PoolKey are not pooled
+	PoolKey string
 }
 
 // NewRequester return new Requester
@@ -37,128 +106,293 @@ func NewRequester(
 	baseURL string,
 	timeout time.Duration,
 	certFiles ...string) (*Requester, error) {
+	return NewRequesterWithOptions(ms, baseURL, RequesterOptions{
+		Timeout:   timeout,
+		CertFiles: certFiles,
+	})
+}
+
+// NewRequesterWithOptions return new Requester with retry, circuit breaker and deadline budget options
+func NewRequesterWithOptions(ms *Microservice, baseURL string, options RequesterOptions) (*Requester, error) {
 
 	if stringutil.IsEmptyString(baseURL) {
 		return nil, errors.New("baseURL is required")
 	}
 
-	client := resty.New()
-
-	if len(certFiles) > 0 {
-		rootCAs, err := x509.SystemCertPool()
-		if err != nil {
-			return nil, err
-		}
-		for _, certFile := range certFiles {
-			if found, _ := fileutil.IsFileExist(certFile); !found {
-				return nil, fmt.Errorf("certFile %s not found", certFile)
-			}
-
-			certRaw, err := os.ReadFile(certFile)
-			if err != nil {
-				return nil, err
-			}
-
-			if ok := rootCAs.AppendCertsFromPEM(certRaw); !ok {
-				return nil, errors.New("failed to append cert")
-			}
-		}
-
-		client.SetTLSClientConfig(&tls.Config{
-			InsecureSkipVerify: true,
-			RootCAs:            rootCAs,
+	transport, err := newRequesterTransport(options)
+	if err != nil {
+		return nil, err
+	}
+	var roundTripper http.RoundTripper = transport
+	wrapTransport := options.WrapTransport
+	if wrapTransport == nil && ms != nil {
+		wrapTransport = ms.requesterTransport
+	}
+	if wrapTransport != nil {
+		roundTripper = wrapTransport(transport)
+	}
+	client := resty.New().SetTransport(roundTripper)
+	if options.Authenticator != nil {
+		client.SetPreRequestHook(func(_ *resty.Client, req *http.Request) error {
+			return options.Authenticator.Authenticate(req)
 		})
 	}
 
-	if timeout > 0 {
-		client.SetTimeout(timeout)
+	if options.Timeout > 0 {
+		client.SetTimeout(options.Timeout)
 	}
 
-	return &Requester{
-		baseURL: baseURL,
-		ms:      ms,
-		client:  client,
-	}, nil
+	rqt := &Requester{
+		baseURL:        baseURL,
+		ms:             ms,
+		client:         client,
+		statusPolicy:   ErrorOnNon2xx,
+		deadlineBudget: options.DeadlineBudget,
+		authenticator:  options.Authenticator,
+		parent:         context.Background(),
+		headers:        make(map[string]string, len(options.Headers)),
+	}
+	for name, value := range options.Headers {
+		rqt.headers[http.CanonicalHeaderKey(name)] = value
+	}
+	rqt.propagateHeaders = append(rqt.propagateHeaders, options.PropagateHeaders...)
+	if ms != nil {
+		rqt.logger = ms
+	}
+	if options.Retry != nil {
+		retry := options.Retry.withDefaults()
+		rqt.retry = &retry
+	}
+	if options.CircuitBreaker != nil {
+		rqt.breaker = circuitBreakers.get(baseURL, *options.CircuitBreaker)
+	}
+	return rqt, nil
 }
 
 // Get request using HTTP GET
-func (rqt *Requester) Get(path string, params map[string]string) (string, error) {
-
-	url := fmt.Sprint(rqt.baseURL, path)
-
-	resp, err := rqt.client.SetQueryParams(params).R().Get(url)
-	if err != nil {
-		return "", err
-	}
-
-	return string(resp.Body()), nil
+func (rqt *Requester) Get(path string, params map[string]string) (*HTTPResponse, error) {
+	return rqt.Do(http.MethodGet, path, params, nil)
 }
 
 // Delete request using HTTP DELETE
-func (rqt *Requester) Delete(path string, params map[string]string) (string, error) {
-
-	url := fmt.Sprint(rqt.baseURL, path)
-
-	resp, err := rqt.client.SetQueryParams(params).R().Delete(url)
-	if err != nil {
-		return "", err
-	}
+func (rqt *Requester) Delete(path string, params map[string]string) (*HTTPResponse, error) {
+	return rqt.Do(http.MethodDelete, path, params, nil)
+}
 
-	return string(resp.Body()), nil
+// Post request using HTTP POST
+func (rqt *Requester) Post(path string, params map[string]string) (*HTTPResponse, error) {
+	return rqt.Do(http.MethodPost, path, params, nil)
+}
 
+// PostJSON request using HTTP POST with JSON body
+func (rqt *Requester) PostJSON(path string, jsonBody any) (*HTTPResponse, error) {
+	return rqt.Do(http.MethodPost, path, nil, jsonBody)
 }
 
-// Post request using HTTP POST
-func (rqt *Requester) Post(path string, params map[string]string) (string, error) {
+// Put request using HTTP PUT
+func (rqt *Requester) Put(path string, params map[string]string) (*HTTPResponse, error) {
+	return rqt.Do(http.MethodPut, path, params, nil)
+}
 
-	url := fmt.Sprint(rqt.baseURL, path)
+// PutJSON request using HTTP PUT with JSON body
+func (rqt *Requester) PutJSON(path string, jsonBody interface{}) (*HTTPResponse, error) {
+	return rqt.Do(http.MethodPut, path, nil, jsonBody)
+}
 
-	resp, err := rqt.client.SetQueryParams(params).R().Post(url)
-	if err != nil {
-		return "", err
+// Do send the request and apply the status policy to the response.
+//
+// With a retry policy a transport error or a retryable status is retried with backoff,
+// a Retry-After header replace the backoff. Every attempt pass the circuit breaker
+// and the whole call including the waits stop at the deadline budget.
+func (rqt *Requester) Do(method string, path string, params map[string]string, body any) (*HTTPResponse, error) {
+	call := requestCall{}
+	if body != nil {
+		call.prepare = func(req *resty.Request) error {
+			req.SetBody(body)
+			return nil
+		}
+		// a reader is consumed by the first attempt
+		_, call.noReplay = body.(io.Reader)
 	}
-
-	return string(resp.Body()), nil
+	return rqt.send(method, path, params, call)
 }
 
-// PostJSON request using HTTP POST with JSON body
-func (rqt *Requester) PostJSON(path string, jsonBody any) (string, error) {
+// requestCall is the body and the response handling of a call
+type requestCall struct {
+	// prepare set the body of each attempt
+	prepare func(req *resty.Request) error
+	// noReplay is set when the body can not be sent twice, the call is neither retried nor re-authenticated
+	noReplay bool
+	// stream consume the response body instead of buffering it, it is called with the 2xx responses
+	// or with every response when streamAnyStatus is set
+	stream          func(response *HTTPResponse, body io.Reader) error
+	streamAnyStatus bool
+}
 
+func (rqt *Requester) send(method string, path string, params map[string]string, call requestCall) (*HTTPResponse, error) {
 	url := fmt.Sprint(rqt.baseURL, path)
 
-	resp, err := rqt.client.R().SetBody(jsonBody).Post(url)
-	if err != nil {
-		return "", err
+	ctx := rqt.parent
+	if rqt.deadlineBudget > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rqt.deadlineBudget)
+		defer cancel()
 	}
 
-	return string(resp.Body()), nil
+	reauthenticated := false
+	for attempt := 0; ; attempt++ {
+		if rqt.breaker != nil {
+			if err := rqt.breaker.Allow(); err != nil {
+				return nil, err
+			}
+		}
 
-}
+		req := rqt.client.R().SetContext(ctx).SetQueryParams(params).SetHeaders(rqt.headers)
+		if requestID := RequestIDFromContext(rqt.parent); requestID != "" {
+			req.SetHeader(echo.HeaderXRequestID, requestID)
+		}
+		if call.prepare != nil {
+			if err := call.prepare(req); err != nil {
+				if rqt.breaker != nil {
+					rqt.breaker.Release()
+				}
+				return nil, err
+			}
+		}
+		if call.stream != nil {
+			req.SetDoNotParseResponse(true)
+		}
+		start := time.Now()
+		resp, err := req.Execute(method, url)
+
+		var response *HTTPResponse
+		var rawBody io.ReadCloser
+		if err == nil {
+			response = newHTTPResponse(method, url, resp)
+			if call.stream != nil {
+				rawBody = resp.RawBody()
+				if !call.streamAnyStatus && !response.IsSuccess() {
+					// the error body is kept for the status error
+					response.Body, _ = io.ReadAll(io.LimitReader(rawBody, DefaultRequesterMaxErrorBodySize))
+					rawBody.Close()
+					rawBody = nil
+				}
+			}
+		}
+		rqt.logCall(method, url, attempt, response, err, time.Since(start))
+		if rqt.breaker != nil {
+			if err != nil && rqt.parent.Err() != nil {
+				// the caller gave up, it says nothing about the downstream
+				rqt.breaker.Release()
+			} else {
+				rqt.breaker.Done(err == nil && response.StatusCode < http.StatusInternalServerError)
+			}
+		}
 
-// Put request using HTTP PUT
-func (rqt *Requester) Put(path string, params map[string]string) (string, error) {
+		if rawBody != nil {
+			// the streamed response is handed over as is, it is neither retried nor checked by the status policy
+			// when every status is streamed
+			streamErr := call.stream(response, rawBody)
+			rawBody.Close()
+			if streamErr != nil || call.streamAnyStatus {
+				return response, streamErr
+			}
+			if rqt.statusPolicy != nil {
+				if err := rqt.statusPolicy(response); err != nil {
+					return response, err
+				}
+			}
+			return response, nil
+		}
 
-	url := fmt.Sprint(rqt.baseURL, path)
+		if !call.noReplay && response != nil && response.StatusCode == http.StatusUnauthorized &&
+			rqt.authenticator != nil && !reauthenticated {
+			// the credentials may be revoked or expired early, retry once with new ones
+			reauthenticated = true
+			if rqt.authenticator.Invalidate(ctx) {
+				attempt--
+				continue
+			}
+		}
 
-	resp, err := rqt.client.SetQueryParams(params).R().Put(url)
-	if err != nil {
-		return "", err
+		wait, retry := rqt.retry.next(ctx, method, attempt, response, err)
+		if !retry || call.noReplay {
+			if err != nil {
+				return nil, err
+			}
+			if rqt.statusPolicy != nil {
+				if err := rqt.statusPolicy(response); err != nil {
+					return response, err
+				}
+			}
+			return response, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			if err != nil {
+				return nil, err
+			}
+			return response, ctx.Err()
+		case <-timer.C:
+		}
 	}
+}
 
-	return string(resp.Body()), nil
+func (rqt *Requester) SetStatusPolicy(policy StatusPolicy) {
+	rqt.statusPolicy = policy
 }
 
-// PutJSON request using HTTP PUT with JSON body
-func (rqt *Requester) PutJSON(path string, jsonBody interface{}) (string, error) {
+func (rqt *Requester) WithContext(ctx context.Context) IRequester {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	bound := rqt.clone()
+	bound.parent = ctx
+	return bound
+}
 
-	url := fmt.Sprint(rqt.baseURL, path)
+// clone return a copy of the requester sharing the client and its connection pool,
+// the headers are copied so binding the copy does not change rqt
+func (rqt *Requester) clone() *Requester {
+	cloned := *rqt
+	cloned.headers = make(map[string]string, len(rqt.headers))
+	for name, value := range rqt.headers {
+		cloned.headers[name] = value
+	}
+	return &cloned
+}
 
-	resp, err := rqt.client.R().SetBody(jsonBody).Put(url)
+// logCall log the outbound attempt with method, url, status and latency
+func (rqt *Requester) logCall(method string, url string, attempt int, resp *HTTPResponse, err error, latency time.Duration) {
+	if rqt.logger == nil {
+		return
+	}
+	fields := []any{
+		zap.String("method", method),
+		zap.String("url", url),
+		zap.Int("attempt", attempt+1),
+		zap.Duration("latency", latency),
+	}
+	level := InfoLevel
 	if err != nil {
-		return "", err
+		level = WarnLevel
+		fields = append(fields, zap.Error(err))
+	} else {
+		fields = append(fields, zap.Int("status", resp.StatusCode))
+		if resp.StatusCode >= http.StatusInternalServerError {
+			level = WarnLevel
+		}
 	}
-
-	return string(resp.Body()), nil
+	// the context logger add the request id itself
+	if _, ok := rqt.logger.(*HTTPContext); !ok {
+		if requestID := RequestIDFromContext(rqt.parent); requestID != "" {
+			fields = append(fields, zap.String(RequestIdField, requestID))
+		}
+	}
+	rqt.logger.Log(level, "outbound request", fields...)
 }
 
 // GetRequest return resty.Request
@@ -0,0 +1,209 @@
+package ihttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gitkeng/ihttp/util/stringutil"
+	"github.com/go-resty/resty/v2"
+	"github.com/labstack/echo/v4"
+)
+
+// RequestAuthenticator add the credentials to the outbound requests of Requester
+type RequestAuthenticator interface {
+	// Authenticate is called right before each attempt is sent,
+	// the request body is readable with req.GetBody
+	Authenticate(req *http.Request) error
+	// Invalidate is called when the downstream answer 401, it drop the cached credentials
+	// and return true to retry the call once with new credentials
+	Invalidate(ctx context.Context) bool
+}
+
+// OAuth2Config is the config of OAuth2ClientCredentials
+type OAuth2Config struct {
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// AuthInParams send the client credentials in the form instead of the basic auth header
+	AuthInParams bool
+	// Cache share the tokens between the replicas, nil keep them in process only
+	Cache IRedisCache
+	// CacheKey is the redis key of the token, default is derived from the token url, client id and scopes
+	CacheKey string
+	// ExpiryDelta refresh the token before it expire, default DefaultOAuth2ExpiryDelta
+	ExpiryDelta time.Duration
+	// Timeout is the timeout of the token request, default DefaultOAuth2TokenTimeout
+	Timeout time.Duration
+	// TLS is the TLS options of the token endpoint
+	TLS RequesterTLSOptions
+}
+
+// OAuth2Token is the access token of the client credentials grant
+type OAuth2Token struct {
+	AccessToken string    `json:"access_token"`
+	TokenType   string    `json:"token_type"`
+	Expiry      time.Time `json:"expiry"`
+}
+
+func (token *OAuth2Token) valid(delta time.Duration) bool {
+	return token != nil && token.AccessToken != "" &&
+		(token.Expiry.IsZero() || time.Now().Add(delta).Before(token.Expiry))
+}
+
+// OAuth2ClientCredentials is the RequestAuthenticator setting the bearer token of the OAuth2 client credentials grant,
+// the token is fetched on first use, cached until shortly before it expire and refreshed after a 401
+type OAuth2ClientCredentials struct {
+	config OAuth2Config
+	client *resty.Client
+
+	mutex sync.Mutex
+	token *OAuth2Token
+}
+
+// NewOAuth2ClientCredentials return new OAuth2ClientCredentials
+func NewOAuth2ClientCredentials(config OAuth2Config) (*OAuth2ClientCredentials, error) {
+	if stringutil.IsEmptyString(config.TokenURL) {
+		return nil, ErrOAuth2TokenURLIsRequire
+	}
+	if stringutil.IsEmptyString(config.ClientID) {
+		return nil, ErrOAuth2ClientIDIsRequire
+	}
+	if config.ExpiryDelta <= 0 {
+		config.ExpiryDelta = DefaultOAuth2ExpiryDelta
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultOAuth2TokenTimeout
+	}
+	if stringutil.IsEmptyString(config.CacheKey) {
+		sum := sha256.Sum256([]byte(config.TokenURL + "|" + config.ClientID + "|" + strings.Join(config.Scopes, " ")))
+		config.CacheKey = "oauth2:token:" + hex.EncodeToString(sum[:8])
+	}
+	transport, err := newRequesterTransport(RequesterOptions{TLS: config.TLS})
+	if err != nil {
+		return nil, err
+	}
+	return &OAuth2ClientCredentials{
+		config: config,
+		client: resty.New().SetTransport(transport).SetTimeout(config.Timeout),
+	}, nil
+}
+
+// Authenticate set the Authorization header with the bearer token
+func (auth *OAuth2ClientCredentials) Authenticate(req *http.Request) error {
+	token, err := auth.Token(req.Context())
+	if err != nil {
+		return err
+	}
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	req.Header.Set(echo.HeaderAuthorization, tokenType+" "+token.AccessToken)
+	return nil
+}
+
+// Invalidate drop the token, the shared token is deleted only when it is the rejected one
+// so a token just refreshed by another replica is kept
+func (auth *OAuth2ClientCredentials) Invalidate(ctx context.Context) bool {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	rejected := auth.token
+	auth.token = nil
+	if auth.config.Cache != nil && rejected != nil {
+		if shared, err := auth.cachedToken(); err == nil && shared != nil && shared.AccessToken == rejected.AccessToken {
+			if err := auth.config.Cache.Del(auth.config.CacheKey); err != nil {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Token return the cached token or fetch new one from the token endpoint
+func (auth *OAuth2ClientCredentials) Token(ctx context.Context) (*OAuth2Token, error) {
+	auth.mutex.Lock()
+	defer auth.mutex.Unlock()
+	if auth.token.valid(auth.config.ExpiryDelta) {
+		return auth.token, nil
+	}
+
+	if auth.config.Cache != nil {
+		if shared, err := auth.cachedToken(); err == nil && shared.valid(auth.config.ExpiryDelta) {
+			auth.token = shared
+			return shared, nil
+		}
+	}
+
+	token, err := auth.fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	auth.token = token
+	if auth.config.Cache != nil {
+		expire := time.Duration(0)
+		if !token.Expiry.IsZero() {
+			expire = time.Until(token.Expiry)
+		}
+		// a failure to share the token only cost the other replicas a token request
+		_ = auth.config.Cache.SetS(auth.config.CacheKey, stringutil.Json(token), expire)
+	}
+	return token, nil
+}
+
+func (auth *OAuth2ClientCredentials) cachedToken() (*OAuth2Token, error) {
+	value, err := auth.config.Cache.Get(auth.config.CacheKey)
+	if err != nil || value == "" {
+		return nil, err
+	}
+	token := &OAuth2Token{}
+	if err := json.Unmarshal([]byte(value), token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+func (auth *OAuth2ClientCredentials) fetch(ctx context.Context) (*OAuth2Token, error) {
+	form := map[string]string{
+		"grant_type": "client_credentials",
+	}
+	if len(auth.config.Scopes) > 0 {
+		form["scope"] = strings.Join(auth.config.Scopes, " ")
+	}
+	req := auth.client.R().SetContext(ctx).SetHeader("Accept", "application/json")
+	if auth.config.AuthInParams {
+		form["client_id"] = auth.config.ClientID
+		form["client_secret"] = auth.config.ClientSecret
+	} else {
+		req.SetBasicAuth(auth.config.ClientID, auth.config.ClientSecret)
+	}
+	resp, err := req.SetFormData(form).Post(auth.config.TokenURL)
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsSuccess() {
+		return nil, ErrOAuth2TokenRequest(resp.StatusCode(), strings.TrimSpace(resp.String()))
+	}
+	body := struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}{}
+	if err := json.Unmarshal(resp.Body(), &body); err != nil || body.AccessToken == "" {
+		return nil, ErrOAuth2TokenInvalid
+	}
+	token := &OAuth2Token{
+		AccessToken: body.AccessToken,
+		TokenType:   body.TokenType,
+	}
+	if body.ExpiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(body.ExpiresIn) * time.Second)
+	}
+	return token, nil
+}
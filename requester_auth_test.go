@@ -0,0 +1,152 @@
+package ihttp_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gitkeng/ihttp"
+	"github.com/go-resty/resty/v2"
+)
+
+func TestRequesterOAuth2ClientCredentials(t *testing.T) {
+	var issued int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientID, secret, ok := r.BasicAuth()
+		if !ok || clientID != "partner" || secret != "s3cret" || r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n := atomic.AddInt32(&issued, 1)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"access_token":"token-%d","token_type":"bearer","expires_in":3600}`, n)
+	}))
+	defer tokenServer.Close()
+
+	// token-1 is revoked by the downstream
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"name":"somchai"}`))
+	}))
+	defer api.Close()
+
+	cache := newMemoryCache()
+	newAuth := func() *ihttp.OAuth2ClientCredentials {
+		auth, err := ihttp.NewOAuth2ClientCredentials(ihttp.OAuth2Config{
+			TokenURL:     tokenServer.URL,
+			ClientID:     "partner",
+			ClientSecret: "s3cret",
+			Scopes:       []string{"users.read"},
+			Cache:        cache,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return auth
+	}
+
+	requester, err := ihttp.NewRequesterWithOptions(nil, api.URL, ihttp.RequesterOptions{Authenticator: newAuth()})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	user, err := ihttp.GetJSON[echoUser](requester, "/users", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if user.Name != "somchai" || atomic.LoadInt32(&issued) != 2 {
+		t.Errorf("expect retry with refreshed token, got %v after %d tokens", user, issued)
+	}
+
+	// another replica use the shared token
+	replica, err := ihttp.NewRequesterWithOptions(nil, api.URL, ihttp.RequesterOptions{Authenticator: newAuth()})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := replica.Get("/users", nil); err != nil {
+		t.Error(err)
+	}
+	if atomic.LoadInt32(&issued) != 2 {
+		t.Errorf("expect shared token, got %d tokens", issued)
+	}
+}
+
+func TestRequesterHMACSignature(t *testing.T) {
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer ms.Cleanup()
+
+	var body string
+	verify := ms.VerifyHMAC(ihttp.HMACVerifyConfig{
+		Secrets:    map[string]string{"partner": "s3cret"},
+		NonceCache: newMemoryCache(),
+	})
+	ms.POST("/webhooks", func(ctx ihttp.IContext) error {
+		raw, _ := io.ReadAll(ctx.WebContext().Request().Body)
+		body = string(raw)
+		return ctx.WebContext().NoContent(http.StatusNoContent)
+	}, verify)
+	server := httptest.NewServer(ms.GetEngine())
+	defer server.Close()
+
+	var signed http.Header
+	requester, err := ihttp.NewRequesterWithOptions(nil, server.URL, ihttp.RequesterOptions{
+		Authenticator: ihttp.NewHMACSigner("partner", "s3cret"),
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	requester.GetClient().OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+		signed = resp.Request.RawRequest.Header.Clone()
+		return nil
+	})
+	if _, err := requester.PostJSON("/webhooks?event=paid", map[string]any{"order": 1}); err != nil {
+		t.Error(err)
+		return
+	}
+	if body != `{"order":1}` {
+		t.Errorf("expect handler read the body, got %q", body)
+	}
+
+	// the captured request is replayed and then tampered
+	send := func(payload string, header http.Header) int {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+"/webhooks?event=paid", bytes.NewBufferString(payload))
+		req.Header = header.Clone()
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		raw, _ := io.ReadAll(resp.Body)
+		if resp.StatusCode == http.StatusUnauthorized && !strings.Contains(string(raw), "INVALID_SIGNATURE") {
+			t.Errorf("expect errors envelope, got %s", raw)
+		}
+		return resp.StatusCode
+	}
+	if status := send(`{"order":1}`, signed); status != http.StatusUnauthorized {
+		t.Errorf("expect replay rejected, got %d", status)
+	}
+	signed.Set(ihttp.HeaderSignatureNonce, "another-nonce")
+	if status := send(`{"order":2}`, signed); status != http.StatusUnauthorized {
+		t.Errorf("expect tampered body rejected, got %d", status)
+	}
+	signed.Set(ihttp.HeaderSignatureTimestamp, fmt.Sprint(time.Now().Add(-time.Hour).Unix()))
+	if status := send(`{"order":1}`, signed); status != http.StatusUnauthorized {
+		t.Errorf("expect expired timestamp rejected, got %d", status)
+	}
+}
@@ -0,0 +1,278 @@
+package ihttp
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/gitkeng/ihttp/log"
+)
+
+// CircuitState is the state of CircuitBreaker
+type CircuitState string
+
+const (
+	// CircuitClosed let every call through and count the failures
+	CircuitClosed CircuitState = "closed"
+	// CircuitOpen reject every call until the open timeout elapse
+	CircuitOpen CircuitState = "open"
+	// CircuitHalfOpen let a few trial calls through to decide whether the downstream recovered
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitBreakerConfig is the thresholds of CircuitBreaker, zero values use the defaults
+type CircuitBreakerConfig struct {
+	// FailureThreshold is the consecutive failures that open the circuit
+	FailureThreshold int
+	// SuccessThreshold is the consecutive half-open successes that close the circuit
+	SuccessThreshold int
+	// OpenTimeout is the time the circuit stay open before half-open
+	OpenTimeout time.Duration
+	// HalfOpenMaxCalls is the max concurrent trial calls in half-open state
+	HalfOpenMaxCalls int
+}
+
+func (cfg CircuitBreakerConfig) withDefaults() CircuitBreakerConfig {
+	if cfg.FailureThreshold <= 0 {
+		cfg.FailureThreshold = DefaultCircuitFailureThreshold
+	}
+	if cfg.SuccessThreshold <= 0 {
+		cfg.SuccessThreshold = DefaultCircuitSuccessThreshold
+	}
+	if cfg.OpenTimeout <= 0 {
+		cfg.OpenTimeout = DefaultCircuitOpenTimeout
+	}
+	if cfg.HalfOpenMaxCalls <= 0 {
+		cfg.HalfOpenMaxCalls = DefaultCircuitHalfOpenMaxCalls
+	}
+	return cfg
+}
+
+// CircuitBreakerStats is the snapshot of a CircuitBreaker for metrics
+type CircuitBreakerStats struct {
+	Name                string       `json:"name"`
+	State               CircuitState `json:"state"`
+	ConsecutiveFailures int          `json:"consecutive_failures"`
+	Successes           uint64       `json:"successes"`
+	Failures            uint64       `json:"failures"`
+	Rejected            uint64       `json:"rejected"`
+	// Opened is the number of times the circuit opened
+	Opened          uint64    `json:"opened"`
+	LastStateChange time.Time `json:"last_state_change"`
+}
+
+// CircuitBreaker protect a downstream by rejecting calls after consecutive failures
+type CircuitBreaker struct {
+	name   string
+	config CircuitBreakerConfig
+
+	mutex               sync.Mutex
+	state               CircuitState
+	consecutiveFailures int
+	halfOpenSuccesses   int
+	halfOpenCalls       int
+	openedAt            time.Time
+	lastUsed            time.Time
+	stats               CircuitBreakerStats
+}
+
+// NewCircuitBreaker return new closed CircuitBreaker, name is used in logs and stats
+func NewCircuitBreaker(name string, cfg CircuitBreakerConfig) *CircuitBreaker {
+	now := time.Now()
+	return &CircuitBreaker{
+		name:     name,
+		config:   cfg.withDefaults(),
+		state:    CircuitClosed,
+		lastUsed: now,
+		stats: CircuitBreakerStats{
+			Name:            name,
+			State:           CircuitClosed,
+			LastStateChange: now,
+		},
+	}
+}
+
+// Allow return an error matching ErrCircuitOpen when the call must not be made,
+// otherwise the caller must report the result with Done
+func (breaker *CircuitBreaker) Allow() error {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	breaker.lastUsed = time.Now()
+	if breaker.state == CircuitOpen {
+		if time.Since(breaker.openedAt) < breaker.config.OpenTimeout {
+			breaker.stats.Rejected++
+			return ErrCircuitBreakerOpen(breaker.name)
+		}
+		breaker.setState(CircuitHalfOpen)
+	}
+	if breaker.state == CircuitHalfOpen {
+		if breaker.halfOpenCalls >= breaker.config.HalfOpenMaxCalls {
+			breaker.stats.Rejected++
+			return ErrCircuitBreakerOpen(breaker.name)
+		}
+		breaker.halfOpenCalls++
+	}
+	return nil
+}
+
+// Done report the result of an allowed call
+func (breaker *CircuitBreaker) Done(success bool) {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+
+	if success {
+		breaker.stats.Successes++
+	} else {
+		breaker.stats.Failures++
+	}
+
+	switch breaker.state {
+	case CircuitClosed:
+		if success {
+			breaker.consecutiveFailures = 0
+			return
+		}
+		breaker.consecutiveFailures++
+		if breaker.consecutiveFailures >= breaker.config.FailureThreshold {
+			breaker.setState(CircuitOpen)
+		}
+	case CircuitHalfOpen:
+		if breaker.halfOpenCalls > 0 {
+			breaker.halfOpenCalls--
+		}
+		if !success {
+			breaker.setState(CircuitOpen)
+			return
+		}
+		breaker.halfOpenSuccesses++
+		if breaker.halfOpenSuccesses >= breaker.config.SuccessThreshold {
+			breaker.setState(CircuitClosed)
+		}
+	case CircuitOpen:
+		// a call allowed before the circuit opened, it does not change the state
+	}
+}
+
+// Release give back an allowed call without a result, such as a call cancelled by the caller
+func (breaker *CircuitBreaker) Release() {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	if breaker.state == CircuitHalfOpen && breaker.halfOpenCalls > 0 {
+		breaker.halfOpenCalls--
+	}
+}
+
+// State return the current state
+func (breaker *CircuitBreaker) State() CircuitState {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	return breaker.state
+}
+
+// Stats return the snapshot of the breaker
+func (breaker *CircuitBreaker) Stats() CircuitBreakerStats {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	stats := breaker.stats
+	stats.State = breaker.state
+	stats.ConsecutiveFailures = breaker.consecutiveFailures
+	return stats
+}
+
+// idle report whether the breaker is closed without failures and not used since before
+func (breaker *CircuitBreaker) idle(before time.Time) bool {
+	breaker.mutex.Lock()
+	defer breaker.mutex.Unlock()
+	return breaker.state == CircuitClosed && breaker.consecutiveFailures == 0 && breaker.lastUsed.Before(before)
+}
+
+// setState must be called with the mutex held
+func (breaker *CircuitBreaker) setState(state CircuitState) {
+	from := breaker.state
+	breaker.state = state
+	breaker.stats.LastStateChange = time.Now()
+	breaker.halfOpenCalls = 0
+	breaker.halfOpenSuccesses = 0
+	switch state {
+	case CircuitOpen:
+		breaker.openedAt = breaker.stats.LastStateChange
+		breaker.stats.Opened++
+		log.Warnf("circuit breaker %s change state from %s to %s after %d consecutive failures",
+			breaker.name, from, state, breaker.consecutiveFailures)
+	case CircuitClosed:
+		breaker.consecutiveFailures = 0
+		log.Infof("circuit breaker %s change state from %s to %s", breaker.name, from, state)
+	default:
+		log.Infof("circuit breaker %s change state from %s to %s", breaker.name, from, state)
+	}
+}
+
+// circuitBreakers hold one breaker per base URL and config so requesters created per request share its state
+var circuitBreakers = &circuitBreakerRegistry{
+	breakers:    make(map[circuitBreakerKey]*CircuitBreaker),
+	idleTimeout: DefaultCircuitIdleTimeout,
+}
+
+type circuitBreakerKey struct {
+	name   string
+	config CircuitBreakerConfig
+}
+
+// circuitBreakerRegistry evict the breakers idle for idleTimeout, a closed breaker without failures
+// carry no state so the next requester of its base URL start over with a new one
+type circuitBreakerRegistry struct {
+	mutex       sync.Mutex
+	breakers    map[circuitBreakerKey]*CircuitBreaker
+	idleTimeout time.Duration
+	lastPrune   time.Time
+}
+
+// get return the breaker of name and cfg, the callers with other thresholds for the same name get their own breaker
+func (registry *circuitBreakerRegistry) get(name string, cfg CircuitBreakerConfig) *CircuitBreaker {
+	key := circuitBreakerKey{name: name, config: cfg.withDefaults()}
+	registry.mutex.Lock()
+	defer registry.mutex.Unlock()
+	if now := time.Now(); now.Sub(registry.lastPrune) >= registry.idleTimeout {
+		registry.prune(now.Add(-registry.idleTimeout))
+		registry.lastPrune = now
+	}
+	breaker, found := registry.breakers[key]
+	if !found {
+		breaker = NewCircuitBreaker(name, key.config)
+		registry.breakers[key] = breaker
+	}
+	return breaker
+}
+
+// prune must be called with the mutex held
+func (registry *circuitBreakerRegistry) prune(before time.Time) {
+	for key, breaker := range registry.breakers {
+		if breaker.idle(before) {
+			delete(registry.breakers, key)
+		}
+	}
+}
+
+func (registry *circuitBreakerRegistry) stats() []CircuitBreakerStats {
+	registry.mutex.Lock()
+	breakers := make([]*CircuitBreaker, 0, len(registry.breakers))
+	for _, breaker := range registry.breakers {
+		breakers = append(breakers, breaker)
+	}
+	registry.mutex.Unlock()
+
+	stats := make([]CircuitBreakerStats, 0, len(breakers))
+	for _, breaker := range breakers {
+		stats = append(stats, breaker.Stats())
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Name < stats[j].Name
+	})
+	return stats
+}
+
+// CircuitBreakerStatsAll return the stats of every requester circuit breaker sorted by base URL
+func CircuitBreakerStatsAll() []CircuitBreakerStats {
+	return circuitBreakers.stats()
+}
@@ -0,0 +1,40 @@
+package ihttp
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerRegistryPrune(t *testing.T) {
+	registry := &circuitBreakerRegistry{
+		breakers:    make(map[circuitBreakerKey]*CircuitBreaker),
+		idleTimeout: time.Hour,
+	}
+	cfg := CircuitBreakerConfig{FailureThreshold: 1}
+
+	idle := registry.get("http://idle", cfg)
+	failing := registry.get("http://failing", cfg)
+	if err := failing.Allow(); err != nil {
+		t.Fatal(err)
+	}
+	failing.Done(false)
+	active := registry.get("http://active", cfg)
+
+	// every breaker but active was last used before the idle timeout
+	idle.lastUsed = time.Now().Add(-2 * time.Hour)
+	failing.lastUsed = time.Now().Add(-2 * time.Hour)
+	registry.lastPrune = time.Now().Add(-2 * time.Hour)
+
+	if registry.get("http://active", cfg) != active {
+		t.Errorf("expect the active breaker to be kept")
+	}
+	if registry.get("http://failing", cfg) != failing {
+		t.Errorf("expect the open breaker to be kept")
+	}
+	if registry.get("http://idle", cfg) == idle {
+		t.Errorf("expect the idle closed breaker to be evicted")
+	}
+	if len(registry.stats()) != 3 {
+		t.Errorf("got %d breakers want 3", len(registry.stats()))
+	}
+}
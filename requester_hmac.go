@@ -0,0 +1,197 @@
+package ihttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gitkeng/ihttp/util/cryptutil"
+	"github.com/gitkeng/ihttp/util/id"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+)
+
+const (
+	HeaderSignatureKeyID     = "X-Signature-Key-Id"
+	HeaderSignatureTimestamp = "X-Signature-Timestamp"
+	HeaderSignatureNonce     = "X-Signature-Nonce"
+	HeaderContentSHA256      = "X-Content-Sha256"
+	HeaderSignature          = "X-Signature"
+)
+
+// HMACSigner is the RequestAuthenticator signing the requests with HMAC-SHA256.
+//
+// The signature is the hex HMAC of the canonical string, the lines joined by \n:
+// method, path with the raw query, unix timestamp, nonce and the hex SHA-256 of the body.
+type HMACSigner struct {
+	// KeyID is sent in X-Signature-Key-Id so the receiver can pick the secret, empty send no key id
+	KeyID  string
+	Secret string
+}
+
+// NewHMACSigner return new HMACSigner
+func NewHMACSigner(keyID string, secret string) *HMACSigner {
+	return &HMACSigner{
+		KeyID:  keyID,
+		Secret: secret,
+	}
+}
+
+// Authenticate set the signature headers of req
+func (signer *HMACSigner) Authenticate(req *http.Request) error {
+	var body []byte
+	if req.GetBody != nil {
+		reader, err := req.GetBody()
+		if err != nil {
+			return err
+		}
+		if reader != nil {
+			defer reader.Close()
+			if body, err = io.ReadAll(reader); err != nil {
+				return err
+			}
+		}
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	nonce := id.UUID()
+	digest := contentSHA256(body)
+	signature, err := cryptutil.HashSHA256(hmacCanonicalString(req.Method, req.URL.RequestURI(), timestamp, nonce, digest), signer.Secret)
+	if err != nil {
+		return err
+	}
+	if signer.KeyID != "" {
+		req.Header.Set(HeaderSignatureKeyID, signer.KeyID)
+	}
+	req.Header.Set(HeaderSignatureTimestamp, timestamp)
+	req.Header.Set(HeaderSignatureNonce, nonce)
+	req.Header.Set(HeaderContentSHA256, digest)
+	req.Header.Set(HeaderSignature, signature)
+	return nil
+}
+
+// Invalidate return false, a rejected signature is not retried
+func (signer *HMACSigner) Invalidate(ctx context.Context) bool {
+	return false
+}
+
+func hmacCanonicalString(method string, requestURI string, timestamp string, nonce string, digest string) string {
+	return strings.Join([]string{strings.ToUpper(method), requestURI, timestamp, nonce, digest}, "\n")
+}
+
+func contentSHA256(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// HMACVerifyConfig is the config of the VerifyHMAC middleware
+type HMACVerifyConfig struct {
+	// Skipper defines a function to skip middleware.
+	Skipper middleware.Skipper
+	// Secrets map the key id to the secret, the "" key is used when the request send no key id
+	Secrets map[string]string
+	// MaxSkew is the accepted difference between the request timestamp and now, default DefaultHMACMaxSkew
+	MaxSkew time.Duration
+	// NonceCache reject a nonce seen within MaxSkew, nil disable the replay check
+	NonceCache IRedisCache
+	// NonceKeyPrefix is the redis key prefix of the seen nonces, default "hmac:nonce:"
+	NonceKeyPrefix string
+	// MaxBodySize is the max bytes of the signed body, default DefaultHMACMaxBodySize
+	MaxBodySize int64
+}
+
+// VerifyHMAC return the middleware verifying the requests signed by HMACSigner such as inbound webhooks,
+// rejected requests are answered 401 with the Response envelope
+func (ms *Microservice) VerifyHMAC(config HMACVerifyConfig) echo.MiddlewareFunc {
+	if config.Skipper == nil {
+		config.Skipper = middleware.DefaultSkipper
+	}
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = DefaultHMACMaxSkew
+	}
+	if config.NonceKeyPrefix == "" {
+		config.NonceKeyPrefix = "hmac:nonce:"
+	}
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DefaultHMACMaxBodySize
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if config.Skipper(c) {
+				return next(c)
+			}
+			if err := verifyHMACRequest(c, config); err != nil {
+				ctx := NewHTTPContext(ms, c)
+				return ctx.Response(WarnLevel, "VerifyHMAC", http.StatusUnauthorized, "INVALID_SIGNATURE", "request signature is invalid", err)
+			}
+			return next(c)
+		}
+	}
+}
+
+func verifyHMACRequest(c echo.Context, config HMACVerifyConfig) error {
+	req := c.Request()
+	secret, found := config.Secrets[req.Header.Get(HeaderSignatureKeyID)]
+	if !found {
+		return ErrHMACUnknownKey(req.Header.Get(HeaderSignatureKeyID))
+	}
+	timestamp := req.Header.Get(HeaderSignatureTimestamp)
+	nonce := req.Header.Get(HeaderSignatureNonce)
+	signature := req.Header.Get(HeaderSignature)
+	if timestamp == "" || nonce == "" || signature == "" {
+		return ErrHMACHeadersMissing
+	}
+	epoch, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrHMACTimestampInvalid
+	}
+	if skew := time.Since(time.Unix(epoch, 0)); skew > config.MaxSkew || skew < -config.MaxSkew {
+		return ErrHMACTimestampInvalid
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, config.MaxBodySize+1))
+	if err != nil {
+		return err
+	}
+	if int64(len(body)) > config.MaxBodySize {
+		return ErrHMACBodyTooLarge
+	}
+	// the handler read the body again
+	req.Body = io.NopCloser(bytes.NewReader(body))
+
+	digest := contentSHA256(body)
+	if claimed := req.Header.Get(HeaderContentSHA256); claimed != "" && !hmac.Equal([]byte(claimed), []byte(digest)) {
+		return ErrHMACSignatureMismatch
+	}
+	expected, err := cryptutil.HashSHA256(hmacCanonicalString(req.Method, req.URL.RequestURI(), timestamp, nonce, digest), secret)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(expected), []byte(strings.ToLower(signature))) {
+		return ErrHMACSignatureMismatch
+	}
+
+	// the nonce is recorded after the signature is verified so a forged request cannot burn it
+	if config.NonceCache != nil {
+		key := config.NonceKeyPrefix + nonce
+		seen, err := config.NonceCache.Incr(key)
+		if err != nil {
+			return err
+		}
+		if seen > 1 {
+			return ErrHMACNonceReplayed
+		}
+		if err := config.NonceCache.Expire(key, 2*config.MaxSkew); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,105 @@
+package ihttp
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gitkeng/ihttp/util/stringutil"
+)
+
+// newRequesterTransport return the transport of a requester, it hold the connection pool
+// so it must be reused across calls to keep the connections alive
+func newRequesterTransport(options RequesterOptions) (*http.Transport, error) {
+	tlsConfig, err := options.TLS.tlsConfig(options.CertFiles)
+	if err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+	}
+	transport := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     true,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		MaxIdleConns:          DefaultRequesterMaxIdleConns,
+		MaxIdleConnsPerHost:   DefaultRequesterMaxIdleConnsPerHost,
+		MaxConnsPerHost:       options.MaxConnsPerHost,
+		IdleConnTimeout:       DefaultRequesterIdleConnTimeout,
+	}
+	if options.MaxIdleConns > 0 {
+		transport.MaxIdleConns = options.MaxIdleConns
+	}
+	if options.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+	}
+	if options.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = options.IdleConnTimeout
+	}
+	return transport, nil
+}
+
+// requesterPool hold the requesters built by IContext.Requester so the calls with the same
+// base URL and options share one connection pool instead of building a client per request
+type requesterPool struct {
+	mutex      sync.Mutex
+	requesters map[string]*Requester
+}
+
+func newRequesterPool() *requesterPool {
+	return &requesterPool{
+		requesters: make(map[string]*Requester),
+	}
+}
+
+// get return the pooled requester of baseURL and options, it is built on the first call.
+// A function value has no identity, so a WrapTransport is pooled under its PoolKey only.
+func (pool *requesterPool) get(ms *Microservice, baseURL string, options RequesterOptions) (*Requester, error) {
+	if options.WrapTransport != nil && options.PoolKey == "" {
+		return NewRequesterWithOptions(ms, baseURL, options)
+	}
+	key := baseURL + " " + stringutil.Json(options)
+	if options.Authenticator != nil {
+		// the authenticator hold the credentials, requesters with different authenticators are not shared
+		key += fmt.Sprintf(" %p", options.Authenticator)
+	}
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	if rqt, found := pool.requesters[key]; found {
+		return rqt, nil
+	}
+	rqt, err := NewRequesterWithOptions(ms, baseURL, options)
+	if err != nil {
+		return nil, err
+	}
+	if len(pool.requesters) >= DefaultRequesterPoolSize {
+		// make room by dropping any pooled requester, its in-flight calls keep working
+		for evicted, pooled := range pool.requesters {
+			pooled.closeIdleConnections()
+			delete(pool.requesters, evicted)
+			break
+		}
+	}
+	pool.requesters[key] = rqt
+	return rqt, nil
+}
+
+// close release the idle connections of every pooled requester
+func (pool *requesterPool) close() {
+	pool.mutex.Lock()
+	defer pool.mutex.Unlock()
+	for key, rqt := range pool.requesters {
+		rqt.closeIdleConnections()
+		delete(pool.requesters, key)
+	}
+}
+
+func (rqt *Requester) closeIdleConnections() {
+	rqt.client.GetClient().CloseIdleConnections()
+}
@@ -0,0 +1,166 @@
+package ihttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// HTTPResponse is the response of IRequester
+type HTTPResponse struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+	// Duration is the time from sending the request to reading the whole body
+	Duration time.Duration
+}
+
+func newHTTPResponse(method string, url string, resp *resty.Response) *HTTPResponse {
+	return &HTTPResponse{
+		Method:     method,
+		URL:        url,
+		StatusCode: resp.StatusCode(),
+		Header:     resp.Header(),
+		Body:       resp.Body(),
+		Duration:   resp.Time(),
+	}
+}
+
+// String return the body as string
+func (resp *HTTPResponse) String() string {
+	return string(resp.Body)
+}
+
+// IsSuccess report whether the status is 2xx
+func (resp *HTTPResponse) IsSuccess() bool {
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+// JSON decode the body into v
+func (resp *HTTPResponse) JSON(v any) error {
+	return json.Unmarshal(resp.Body, v)
+}
+
+// StatusPolicy decide whether the response status is an error, nil accept the response
+type StatusPolicy func(resp *HTTPResponse) error
+
+// ErrorOnNon2xx is the default StatusPolicy, every status outside 2xx is an *HTTPStatusError
+func ErrorOnNon2xx(resp *HTTPResponse) error {
+	if resp.IsSuccess() {
+		return nil
+	}
+	return NewHTTPStatusError(resp)
+}
+
+// ErrorOn5xx is the StatusPolicy for callers handling 4xx themselves, only 5xx is an *HTTPStatusError
+func ErrorOn5xx(resp *HTTPResponse) error {
+	if resp.StatusCode < http.StatusInternalServerError {
+		return nil
+	}
+	return NewHTTPStatusError(resp)
+}
+
+// IgnoreStatus is the StatusPolicy accepting every status
+func IgnoreStatus(resp *HTTPResponse) error {
+	return nil
+}
+
+// HTTPStatusError is the error for a response status rejected by StatusPolicy.
+//
+// errors.Is match ErrHTTPClientStatus for 4xx and ErrHTTPServerStatus for 5xx.
+type HTTPStatusError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Body       []byte
+	// Response is the downstream envelope when the downstream is an ihttp service, otherwise nil
+	Response *Response
+}
+
+// NewHTTPStatusError return HTTPStatusError of resp and parse the ihttp Response envelope of the body
+func NewHTTPStatusError(resp *HTTPResponse) *HTTPStatusError {
+	statusErr := &HTTPStatusError{
+		Method:     resp.Method,
+		URL:        resp.URL,
+		StatusCode: resp.StatusCode,
+		Body:       resp.Body,
+	}
+	envelope := &Response{}
+	if err := json.Unmarshal(resp.Body, envelope); err == nil && (len(envelope.Error) > 0 || envelope.Code != "") {
+		statusErr.Response = envelope
+	}
+	return statusErr
+}
+
+func (err *HTTPStatusError) Error() string {
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("%s %s return status %d", err.Method, err.URL, err.StatusCode))
+	if err.Response != nil {
+		for _, item := range err.Response.Error {
+			sb.WriteString(fmt.Sprintf(": %s %s", item.Code, item.Message))
+		}
+		if len(err.Response.Error) == 0 {
+			sb.WriteString(fmt.Sprintf(": %s %s", err.Response.Code, err.Response.Message))
+		}
+	}
+	return sb.String()
+}
+
+func (err *HTTPStatusError) Is(target error) bool {
+	switch target {
+	case ErrHTTPClientStatus:
+		return err.StatusCode >= 400 && err.StatusCode < 500
+	case ErrHTTPServerStatus:
+		return err.StatusCode >= 500
+	}
+	return false
+}
+
+// Errors return the downstream errors, it is a single error with the status text
+// when the downstream is not an ihttp service
+func (err *HTTPStatusError) Errors() Errors {
+	if err.Response != nil && len(err.Response.Error) > 0 {
+		return err.Response.Error
+	}
+	code := http.StatusText(err.StatusCode)
+	message := strings.TrimSpace(string(err.Body))
+	if err.Response != nil {
+		code, message = err.Response.Code, err.Response.Message
+	}
+	return Errors{
+		{
+			Code:    code,
+			Message: message,
+		},
+	}
+}
+
+// GetJSON request using HTTP GET and decode the JSON body into T
+func GetJSON[T any](rqt IRequester, path string, params map[string]string) (T, error) {
+	return decodeResponse[T](rqt.Get(path, params))
+}
+
+// PostJSON request using HTTP POST with JSON body and decode the JSON response body into T
+func PostJSON[T any](rqt IRequester, path string, body any) (T, error) {
+	return decodeResponse[T](rqt.PostJSON(path, body))
+}
+
+func decodeResponse[T any](resp *HTTPResponse, err error) (T, error) {
+	var result T
+	if err != nil {
+		return result, err
+	}
+	if len(resp.Body) == 0 {
+		return result, nil
+	}
+	if err := resp.JSON(&result); err != nil {
+		return result, ErrHTTPResponseDecode(resp.Method, resp.URL, err)
+	}
+	return result, nil
+}
@@ -0,0 +1,115 @@
+package ihttp
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gitkeng/ihttp/util/retryutil"
+)
+
+// RetryPolicy is the retry option of Requester, zero values use the defaults
+type RetryPolicy struct {
+	// MaxAttempts is the total attempts include the first one
+	MaxAttempts int
+	// InitialInterval is the backoff before the first retry
+	InitialInterval time.Duration
+	// MaxInterval cap the backoff and the Retry-After wait
+	MaxInterval time.Duration
+	// RetryOnStatus is the statuses to retry, default 429, 502, 503 and 504
+	RetryOnStatus []int
+	// RetryNonIdempotent allow retrying POST and PATCH, the downstream must dedupe them
+	RetryNonIdempotent bool
+}
+
+func (policy RetryPolicy) withDefaults() RetryPolicy {
+	if policy.MaxAttempts <= 0 {
+		policy.MaxAttempts = DefaultRequesterRetryMaxAttempts
+	}
+	if policy.InitialInterval <= 0 {
+		policy.InitialInterval = DefaultRequesterRetryInitialInterval
+	}
+	if policy.MaxInterval <= 0 {
+		policy.MaxInterval = DefaultRequesterRetryMaxInterval
+	}
+	if policy.MaxInterval < policy.InitialInterval {
+		policy.MaxInterval = policy.InitialInterval
+	}
+	if len(policy.RetryOnStatus) == 0 {
+		policy.RetryOnStatus = []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		}
+	}
+	return policy
+}
+
+// next return the wait before the next attempt and whether to retry
+func (policy *RetryPolicy) next(ctx context.Context, method string, attempt int, resp *HTTPResponse, err error) (time.Duration, bool) {
+	if policy == nil || attempt+1 >= policy.MaxAttempts || ctx.Err() != nil {
+		return 0, false
+	}
+	if !policy.RetryNonIdempotent && !isIdempotentMethod(method) {
+		return 0, false
+	}
+	if err == nil && !policy.isRetryStatus(resp.StatusCode) {
+		return 0, false
+	}
+
+	backoff := retryutil.NewBackoff(policy.InitialInterval, policy.MaxInterval)
+	wait := backoff.Next(attempt)
+	if resp != nil {
+		if retryAfter, ok := parseRetryAfter(resp.Header.Get("Retry-After")); ok {
+			wait = retryAfter
+		}
+	}
+	if wait > policy.MaxInterval {
+		wait = policy.MaxInterval
+	}
+	// do not start a wait that the deadline budget can not cover
+	if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+		return 0, false
+	}
+	return wait, true
+}
+
+func (policy *RetryPolicy) isRetryStatus(status int) bool {
+	for _, retryStatus := range policy.RetryOnStatus {
+		if status == retryStatus {
+			return true
+		}
+	}
+	return false
+}
+
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// parseRetryAfter parse the Retry-After header in seconds or http date
+func parseRetryAfter(value string) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		wait := time.Until(date)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+	return 0, false
+}
@@ -0,0 +1,326 @@
+package ihttp_test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gitkeng/ihttp"
+)
+
+type echoUser struct {
+	Name string `json:"name"`
+}
+
+func TestRequesterStatusPolicy(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/users":
+			w.Header().Set("X-Total", "1")
+			w.Write([]byte(`{"name":"somchai"}`))
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status_code":404,"errors":[{"code":"USER_NOT_FOUND","message":"user not found"}]}`))
+		default:
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte(`bad gateway`))
+		}
+	}))
+	defer server.Close()
+
+	requester, err := ihttp.NewRequester(nil, server.URL, 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	resp, err := requester.Get("/users", nil)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Total") != "1" {
+		t.Errorf("unexpected response %d %v", resp.StatusCode, resp.Header)
+	}
+
+	user, err := ihttp.GetJSON[echoUser](requester, "/users", nil)
+	if err != nil || user.Name != "somchai" {
+		t.Errorf("expect somchai got %+v err %v", user, err)
+	}
+
+	_, err = ihttp.GetJSON[echoUser](requester, "/missing", nil)
+	var statusErr *ihttp.HTTPStatusError
+	if !errors.As(err, &statusErr) || !errors.Is(err, ihttp.ErrHTTPClientStatus) {
+		t.Errorf("expect client status error got %v", err)
+		return
+	}
+	if errs := statusErr.Errors(); len(errs) != 1 || errs[0].Code != "USER_NOT_FOUND" {
+		t.Errorf("expect downstream errors got %+v", errs)
+	}
+	t.Logf("%s", err)
+
+	_, err = requester.Get("/down", nil)
+	if !errors.Is(err, ihttp.ErrHTTPServerStatus) {
+		t.Errorf("expect server status error got %v", err)
+	}
+
+	requester.SetStatusPolicy(ihttp.IgnoreStatus)
+	if resp, err := requester.Get("/down", nil); err != nil || resp.StatusCode != http.StatusBadGateway {
+		t.Errorf("expect 502 without error got %v", err)
+	}
+}
+
+func TestRequesterRetryAndCircuitBreaker(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.URL.Path == "/flaky" && calls%3 != 0 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.Write([]byte(`{"name":"somchai"}`))
+	}))
+	defer server.Close()
+
+	requester, err := ihttp.NewRequesterWithOptions(nil, server.URL, ihttp.RequesterOptions{
+		Retry: &ihttp.RetryPolicy{
+			MaxAttempts:     3,
+			InitialInterval: time.Millisecond,
+		},
+		CircuitBreaker: &ihttp.CircuitBreakerConfig{
+			FailureThreshold: 3,
+			OpenTimeout:      time.Hour,
+		},
+		DeadlineBudget: 5 * time.Second,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if _, err := requester.Get("/flaky", nil); err != nil {
+		t.Errorf("expect success after retry got %v", err)
+	}
+	if calls != 3 {
+		t.Errorf("expect 3 calls got %d", calls)
+	}
+
+	// post is not idempotent, it is not retried
+	calls = 0
+	if _, err := requester.PostJSON("/flaky", echoUser{Name: "somsri"}); !errors.Is(err, ihttp.ErrHTTPServerStatus) {
+		t.Errorf("expect server status error got %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expect 1 call got %d", calls)
+	}
+
+	// the failed post and two 500 make 3 consecutive failures and open the circuit
+	for idx := 0; idx < 2; idx++ {
+		if _, err := requester.Get("/down", nil); !errors.Is(err, ihttp.ErrHTTPServerStatus) {
+			t.Errorf("expect server status error got %v", err)
+		}
+	}
+	if _, err := requester.Get("/users", nil); !errors.Is(err, ihttp.ErrCircuitOpen) {
+		t.Errorf("expect circuit open got %v", err)
+	}
+	for _, stats := range ihttp.CircuitBreakerStatsAll() {
+		if stats.Name == server.URL && stats.State != ihttp.CircuitOpen {
+			t.Errorf("expect open state got %s", stats.State)
+		}
+		t.Logf("%+v", stats)
+	}
+
+	// the same base URL and thresholds share the open breaker, other thresholds get their own
+	breakers := []struct {
+		config ihttp.CircuitBreakerConfig
+		open   bool
+	}{
+		{ihttp.CircuitBreakerConfig{FailureThreshold: 3, OpenTimeout: time.Hour}, true},
+		{ihttp.CircuitBreakerConfig{FailureThreshold: 10, OpenTimeout: time.Hour}, false},
+	}
+	for _, breaker := range breakers {
+		config := breaker.config
+		other, err := ihttp.NewRequesterWithOptions(nil, server.URL, ihttp.RequesterOptions{CircuitBreaker: &config})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := other.Get("/users", nil); errors.Is(err, ihttp.ErrCircuitOpen) != breaker.open {
+			t.Errorf("config %+v expect open %t got %v", breaker.config, breaker.open, err)
+		}
+	}
+}
+
+func TestCircuitBreakerHalfOpen(t *testing.T) {
+	breaker := ihttp.NewCircuitBreaker("test", ihttp.CircuitBreakerConfig{
+		FailureThreshold: 1,
+		SuccessThreshold: 1,
+		OpenTimeout:      time.Millisecond,
+	})
+	if err := breaker.Allow(); err != nil {
+		t.Error(err)
+		return
+	}
+	breaker.Done(false)
+	if breaker.State() != ihttp.CircuitOpen {
+		t.Errorf("expect open got %s", breaker.State())
+	}
+	time.Sleep(2 * time.Millisecond)
+	if err := breaker.Allow(); err != nil {
+		t.Errorf("expect trial call in half-open got %v", err)
+		return
+	}
+	if err := breaker.Allow(); !errors.Is(err, ihttp.ErrCircuitOpen) {
+		t.Errorf("expect second trial call rejected got %v", err)
+	}
+	breaker.Done(true)
+	if breaker.State() != ihttp.CircuitClosed {
+		t.Errorf("expect closed got %s", breaker.State())
+	}
+}
+
+func TestRequesterPropagation(t *testing.T) {
+	var received http.Header
+	downstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Write([]byte(`{"name":"somchai"}`))
+	}))
+	defer downstream.Close()
+
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{
+		PropagateHeaders: []string{"x-tenant-id"},
+	}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer ms.Cleanup()
+
+	ms.GET("/proxy", func(ctx ihttp.IContext) error {
+		requester, err := ctx.Requester(downstream.URL, time.Second)
+		if err != nil {
+			return err
+		}
+		user, err := ihttp.GetJSON[echoUser](requester, "/users", nil)
+		if err != nil {
+			return err
+		}
+		return ctx.WebContext().JSON(http.StatusOK, user)
+	})
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/proxy", nil)
+	req.Header.Set("X-Request-ID", "request-1")
+	req.Header.Set("Traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	req.Header.Set("X-Tenant-ID", "tenant-1")
+	req.Header.Set("X-Not-Forwarded", "secret")
+	rec := httptest.NewRecorder()
+	ms.GetEngine().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expect 200 got %d %s", rec.Code, rec.Body.String())
+		return
+	}
+	if received.Get("X-Request-ID") != "request-1" {
+		t.Errorf("expect request id forwarded got %q", received.Get("X-Request-ID"))
+	}
+	if received.Get("X-Tenant-ID") != "tenant-1" {
+		t.Errorf("expect tenant forwarded got %q", received.Get("X-Tenant-ID"))
+	}
+	if received.Get("X-Not-Forwarded") != "" {
+		t.Errorf("expect header not forwarded")
+	}
+	traceParent := received.Get("Traceparent")
+	if len(traceParent) != 55 || traceParent[3:35] != traceID || traceParent[36:52] == "00f067aa0ba902b7" {
+		t.Errorf("expect child traceparent of %s got %q", traceID, traceParent)
+	}
+}
+
+func TestRequesterClientPool(t *testing.T) {
+	var connections int32
+	var received http.Header
+	downstream := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Clone()
+		w.Write([]byte(`{"name":"somchai"}`))
+	}))
+	downstream.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&connections, 1)
+		}
+	}
+	downstream.Start()
+	defer downstream.Close()
+
+	ms, err := ihttp.New(
+		ihttp.WithAPIConfig(&ihttp.APIConfig{}),
+		ihttp.WithClientConfigs(&ihttp.ClientConfig{
+			ContextName:      "users",
+			BaseURL:          downstream.URL + "/",
+			Headers:          map[string]string{"x-api-version": "2"},
+			PropagateHeaders: []string{"x-tenant-id"},
+		}),
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer ms.Cleanup()
+
+	ms.GET("/client", func(ctx ihttp.IContext) error {
+		requester, found := ctx.Client("users")
+		if !found {
+			return errors.New("client users not found")
+		}
+		user, err := ihttp.GetJSON[echoUser](requester, "/users", nil)
+		if err != nil {
+			return err
+		}
+		return ctx.WebContext().JSON(http.StatusOK, user)
+	})
+	ms.GET("/requester", func(ctx ihttp.IContext) error {
+		requester, err := ctx.Requester(downstream.URL, time.Second)
+		if err != nil {
+			return err
+		}
+		user, err := ihttp.GetJSON[echoUser](requester, "/users", nil)
+		if err != nil {
+			return err
+		}
+		return ctx.WebContext().JSON(http.StatusOK, user)
+	})
+
+	for _, path := range []string{"/client", "/requester"} {
+		atomic.StoreInt32(&connections, 0)
+		for idx := 0; idx < 5; idx++ {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("X-Tenant-ID", "tenant-1")
+			rec := httptest.NewRecorder()
+			ms.GetEngine().ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				t.Errorf("%s expect 200 got %d %s", path, rec.Code, rec.Body.String())
+				return
+			}
+		}
+		if count := atomic.LoadInt32(&connections); count != 1 {
+			t.Errorf("%s expect one kept alive connection got %d", path, count)
+		}
+		if path == "/client" {
+			if received.Get("X-Api-Version") != "2" || received.Get("X-Tenant-Id") != "tenant-1" {
+				t.Errorf("expect client headers got %v", received)
+			}
+		}
+	}
+
+	if _, found := ms.Client("orders"); found {
+		t.Error("expect client orders not found")
+	}
+}
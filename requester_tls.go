@@ -0,0 +1,110 @@
+package ihttp
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"os"
+	"strings"
+
+	"github.com/gitkeng/ihttp/util/fileutil"
+	"github.com/gitkeng/ihttp/util/stringutil"
+)
+
+// RequesterTLSOptions is the TLS options of Requester, the server certificate is verified by default
+type RequesterTLSOptions struct {
+	// ClientCertFile and ClientKeyFile is the client certificate pair for mTLS
+	ClientCertFile string
+	ClientKeyFile  string
+	// ServerName override the host name used to verify the server certificate and sent as SNI
+	ServerName string
+	// MinVersion is the minimum TLS version such as tls.VersionTLS13, default TLS 1.2
+	MinVersion uint16
+	// PinnedSPKIHashes is the base64 SHA-256 hashes of the accepted public keys ("sha256/" prefix is optional),
+	// a server chain without one of them is rejected, see SPKIHash
+	PinnedSPKIHashes []string
+	// InsecureSkipVerify disable the server certificate verification, pinning still apply
+	InsecureSkipVerify bool
+}
+
+// SPKIHash return the base64 SHA-256 hash of the certificate public key for PinnedSPKIHashes
+func SPKIHash(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+func (options RequesterTLSOptions) tlsConfig(caFiles []string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         strings.TrimSpace(options.ServerName),
+		InsecureSkipVerify: options.InsecureSkipVerify,
+	}
+	if options.MinVersion != 0 {
+		tlsConfig.MinVersion = options.MinVersion
+	}
+
+	if len(caFiles) > 0 {
+		rootCAs, err := x509.SystemCertPool()
+		if err != nil {
+			return nil, err
+		}
+		for _, caFile := range caFiles {
+			if found, _ := fileutil.IsFileExist(caFile); !found {
+				return nil, ErrSSLCertificateFileNotfound(caFile)
+			}
+			certRaw, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, err
+			}
+			if ok := rootCAs.AppendCertsFromPEM(certRaw); !ok {
+				return nil, ErrRequesterCertInvalid(caFile)
+			}
+		}
+		tlsConfig.RootCAs = rootCAs
+	}
+
+	if stringutil.IsNotEmptyString(options.ClientCertFile) || stringutil.IsNotEmptyString(options.ClientKeyFile) {
+		if stringutil.IsEmptyString(options.ClientCertFile) {
+			return nil, ErrSSLCertificateFileRequire
+		}
+		if stringutil.IsEmptyString(options.ClientKeyFile) {
+			return nil, ErrSSLKeyFileRequire
+		}
+		clientCert, err := tls.LoadX509KeyPair(options.ClientCertFile, options.ClientKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{clientCert}
+	}
+
+	if len(options.PinnedSPKIHashes) > 0 {
+		pins := make(map[string]bool, len(options.PinnedSPKIHashes))
+		for _, pin := range options.PinnedSPKIHashes {
+			pins[strings.TrimPrefix(strings.TrimSpace(pin), "sha256/")] = true
+		}
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			// verified chains include the trusted root, without verification only the presented certificates count
+			for _, chain := range verifiedChains {
+				for _, cert := range chain {
+					if pins[SPKIHash(cert)] {
+						return nil
+					}
+				}
+			}
+			if len(verifiedChains) == 0 {
+				for _, raw := range rawCerts {
+					cert, err := x509.ParseCertificate(raw)
+					if err != nil {
+						return err
+					}
+					if pins[SPKIHash(cert)] {
+						return nil
+					}
+				}
+			}
+			return ErrRequesterPinMismatch
+		}
+	}
+	return tlsConfig, nil
+}
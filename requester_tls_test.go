@@ -0,0 +1,106 @@
+package ihttp_test
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gitkeng/ihttp"
+)
+
+func TestRequesterTLS(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if len(r.TLS.PeerCertificates) == 0 {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`ok`))
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	server.StartTLS()
+	defer server.Close()
+
+	// the httptest certificate is reused as CA and as client certificate
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+	serverCert := server.Certificate()
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: serverCert.Raw}), 0600); err != nil {
+		t.Error(err)
+		return
+	}
+	keyRaw, err := x509.MarshalPKCS8PrivateKey(server.TLS.Certificates[0].PrivateKey)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyRaw}), 0600); err != nil {
+		t.Error(err)
+		return
+	}
+	clientTLS := ihttp.RequesterTLSOptions{
+		ClientCertFile: certFile,
+		ClientKeyFile:  keyFile,
+	}
+
+	// the server certificate is verified by default
+	requester, err := ihttp.NewRequesterWithOptions(nil, server.URL, ihttp.RequesterOptions{TLS: clientTLS})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := requester.Get("/", nil); err == nil {
+		t.Error("expect untrusted certificate error")
+	}
+
+	requester, err = ihttp.NewRequesterWithOptions(nil, server.URL, ihttp.RequesterOptions{
+		CertFiles: []string{certFile},
+		TLS:       clientTLS,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := requester.Get("/", nil); err != nil {
+		t.Error(err)
+	}
+
+	pinned := clientTLS
+	pinned.PinnedSPKIHashes = []string{"sha256/" + ihttp.SPKIHash(serverCert)}
+	requester, err = ihttp.NewRequesterWithOptions(nil, server.URL, ihttp.RequesterOptions{
+		CertFiles: []string{certFile},
+		TLS:       pinned,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := requester.Get("/", nil); err != nil {
+		t.Error(err)
+	}
+
+	pinned.PinnedSPKIHashes = []string{"AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA="}
+	requester, err = ihttp.NewRequesterWithOptions(nil, server.URL, ihttp.RequesterOptions{
+		CertFiles: []string{certFile},
+		TLS:       pinned,
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if _, err := requester.Get("/", nil); !errors.Is(err, ihttp.ErrRequesterPinMismatch) {
+		t.Errorf("expect pin mismatch error, got %v", err)
+	}
+
+	if _, err := ihttp.NewRequesterWithOptions(nil, server.URL, ihttp.RequesterOptions{
+		TLS: ihttp.RequesterTLSOptions{ClientCertFile: certFile},
+	}); !errors.Is(err, ihttp.ErrSSLKeyFileRequire) {
+		t.Errorf("expect key file require error, got %v", err)
+	}
+}
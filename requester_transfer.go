@@ -0,0 +1,179 @@
+package ihttp
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/go-resty/resty/v2"
+	"github.com/labstack/echo/v4"
+)
+
+// MultipartFile is a file part of PostMultipart, the content is read from Reader or from FilePath
+type MultipartFile struct {
+	FieldName string
+	// FileName default to the base name of FilePath
+	FileName    string
+	ContentType string
+	// Reader is the file content, a call with a reader is not retried because the reader is consumed
+	Reader io.Reader
+	// FilePath is opened for each attempt when Reader is nil
+	FilePath string
+}
+
+// DownloadOptions is the options of Download
+type DownloadOptions struct {
+	// MaxSize is the max bytes written to the writer, 0 is unlimited.
+	// A larger Content-Length is rejected before writing, a body growing past it stop the copy.
+	MaxSize int64
+	// Progress is called after each write with the bytes written and the Content-Length, -1 when unknown
+	Progress func(written int64, total int64)
+}
+
+// PostForm request using HTTP POST with form-urlencoded body
+func (rqt *Requester) PostForm(path string, form map[string]string) (*HTTPResponse, error) {
+	return rqt.send(http.MethodPost, path, nil, requestCall{
+		prepare: func(req *resty.Request) error {
+			req.SetFormData(form)
+			return nil
+		},
+	})
+}
+
+// PostMultipart upload the fields and files using HTTP POST with multipart/form-data body
+func (rqt *Requester) PostMultipart(path string, fields map[string]string, files ...MultipartFile) (*HTTPResponse, error) {
+	call := requestCall{}
+	opened := make([]*os.File, 0, len(files))
+	closeFiles := func() {
+		for _, file := range opened {
+			file.Close()
+		}
+		opened = opened[:0]
+	}
+	defer closeFiles()
+
+	call.prepare = func(req *resty.Request) error {
+		// the files of the previous attempt are read already
+		closeFiles()
+		req.SetFormData(fields)
+		for _, file := range files {
+			reader := file.Reader
+			fileName := file.FileName
+			if reader == nil {
+				osFile, err := os.Open(file.FilePath)
+				if err != nil {
+					return err
+				}
+				opened = append(opened, osFile)
+				reader = osFile
+				if fileName == "" {
+					fileName = filepath.Base(file.FilePath)
+				}
+			}
+			req.SetMultipartField(file.FieldName, fileName, file.ContentType, reader)
+		}
+		return nil
+	}
+	for _, file := range files {
+		if file.Reader != nil {
+			call.noReplay = true
+		}
+	}
+	return rqt.send(http.MethodPost, path, nil, call)
+}
+
+// Download stream the response body of HTTP GET to w without buffering it,
+// the returned response has no body and a non 2xx response is not written to w
+func (rqt *Requester) Download(path string, params map[string]string, w io.Writer, options DownloadOptions) (*HTTPResponse, error) {
+	return rqt.send(http.MethodGet, path, params, requestCall{
+		stream: func(response *HTTPResponse, body io.Reader) error {
+			total := response.contentLength()
+			source := body
+			if options.MaxSize > 0 {
+				if total > options.MaxSize {
+					return ErrDownloadTooLarge(response.URL, options.MaxSize)
+				}
+				source = io.LimitReader(body, options.MaxSize)
+			}
+			if _, err := io.Copy(&progressWriter{writer: w, total: total, progress: options.Progress}, source); err != nil {
+				return err
+			}
+			// a body without Content-Length is detected by the byte after the limit
+			if options.MaxSize > 0 {
+				if n, _ := io.ReadFull(body, make([]byte, 1)); n > 0 {
+					return ErrDownloadTooLarge(response.URL, options.MaxSize)
+				}
+			}
+			return nil
+		},
+	})
+}
+
+// Proxy send the request and stream the downstream status, headers and body to resp without buffering.
+//
+// Every status is passed through so the status policy is not applied, the error is the transport error
+// or a copy error after the status is written, when the handler can not answer anymore.
+func (rqt *Requester) Proxy(resp *echo.Response, method string, path string, params map[string]string, body any) (*HTTPResponse, error) {
+	call := requestCall{
+		streamAnyStatus: true,
+		stream: func(response *HTTPResponse, downstream io.Reader) error {
+			header := resp.Header()
+			for name, values := range response.Header {
+				if hopByHopHeaders[http.CanonicalHeaderKey(name)] {
+					continue
+				}
+				header[name] = append([]string(nil), values...)
+			}
+			resp.WriteHeader(response.StatusCode)
+			_, err := io.Copy(resp, downstream)
+			return err
+		},
+	}
+	if body != nil {
+		call.prepare = func(req *resty.Request) error {
+			req.SetBody(body)
+			return nil
+		}
+		_, call.noReplay = body.(io.Reader)
+	}
+	return rqt.send(method, path, params, call)
+}
+
+// hopByHopHeaders is the headers of a single connection, they are not proxied
+var hopByHopHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+func (resp *HTTPResponse) contentLength() int64 {
+	length, err := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	if err != nil {
+		return -1
+	}
+	return length
+}
+
+type progressWriter struct {
+	writer   io.Writer
+	total    int64
+	written  int64
+	progress func(written int64, total int64)
+}
+
+func (writer *progressWriter) Write(p []byte) (int, error) {
+	n, err := writer.writer.Write(p)
+	writer.written += int64(n)
+	if writer.progress != nil && n > 0 {
+		writer.progress(writer.written, writer.total)
+	}
+	return n, err
+}
@@ -0,0 +1,169 @@
+package ihttp_test
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gitkeng/ihttp"
+)
+
+func newTransferServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/upload":
+			if err := r.ParseMultipartForm(1 << 20); err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			parts := []string{r.FormValue("title")}
+			for _, field := range []string{"doc", "note"} {
+				file, header, err := r.FormFile(field)
+				if err != nil {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				content, _ := io.ReadAll(file)
+				parts = append(parts, header.Filename+"="+string(content))
+			}
+			w.Write([]byte(strings.Join(parts, ",")))
+		case "/form":
+			r.ParseForm()
+			w.Write([]byte(r.Header.Get("Content-Type") + ";" + r.PostForm.Get("name")))
+		case "/files/sized":
+			w.Header().Set("Content-Length", "2048")
+			w.Write(bytes.Repeat([]byte("a"), 2048))
+		case "/files/chunked":
+			for idx := 0; idx < 4; idx++ {
+				w.Write(bytes.Repeat([]byte("b"), 512))
+				w.(http.Flusher).Flush()
+			}
+		case "/files/report":
+			w.Header().Set("Content-Type", "text/csv")
+			w.Header().Set("X-Report-Id", "r-1")
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("id,name\n1,somchai\n"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"status_code":404,"errors":[{"code":"FILE_NOT_FOUND","message":"file not found"}]}`))
+		}
+	}))
+}
+
+func TestRequesterUploadAndForm(t *testing.T) {
+	server := newTransferServer()
+	defer server.Close()
+	requester, err := ihttp.NewRequester(nil, server.URL, 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	notePath := filepath.Join(t.TempDir(), "note.txt")
+	if err := os.WriteFile(notePath, []byte("from file"), 0600); err != nil {
+		t.Error(err)
+		return
+	}
+	resp, err := requester.PostMultipart("/upload", map[string]string{"title": "report"},
+		ihttp.MultipartFile{FieldName: "doc", FileName: "doc.txt", Reader: strings.NewReader("from reader")},
+		ihttp.MultipartFile{FieldName: "note", FilePath: notePath},
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if resp.String() != "report,doc.txt=from reader,note.txt=from file" {
+		t.Errorf("unexpected upload result %q", resp.String())
+	}
+
+	resp, err = requester.PostForm("/form", map[string]string{"name": "somchai"})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if resp.String() != "application/x-www-form-urlencoded;somchai" {
+		t.Errorf("unexpected form result %q", resp.String())
+	}
+}
+
+func TestRequesterDownload(t *testing.T) {
+	server := newTransferServer()
+	defer server.Close()
+	requester, err := ihttp.NewRequester(nil, server.URL, 0)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	var buf bytes.Buffer
+	var lastWritten, lastTotal int64
+	resp, err := requester.Download("/files/sized", nil, &buf, ihttp.DownloadOptions{
+		Progress: func(written int64, total int64) {
+			lastWritten, lastTotal = written, total
+		},
+	})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if buf.Len() != 2048 || lastWritten != 2048 || lastTotal != 2048 || len(resp.Body) != 0 {
+		t.Errorf("unexpected download %d bytes progress %d/%d", buf.Len(), lastWritten, lastTotal)
+	}
+
+	for _, path := range []string{"/files/sized", "/files/chunked"} {
+		buf.Reset()
+		_, err = requester.Download(path, nil, &buf, ihttp.DownloadOptions{MaxSize: 1024})
+		if err == nil || !strings.Contains(err.Error(), "larger than 1024 bytes") {
+			t.Errorf("%s expect too large error, got %v", path, err)
+		}
+		if buf.Len() > 1024 {
+			t.Errorf("%s expect at most 1024 bytes written, got %d", path, buf.Len())
+		}
+	}
+
+	buf.Reset()
+	_, err = requester.Download("/files/missing", nil, &buf, ihttp.DownloadOptions{})
+	statusErr := &ihttp.HTTPStatusError{}
+	if !errors.As(err, &statusErr) || statusErr.Errors()[0].Code != "FILE_NOT_FOUND" || buf.Len() != 0 {
+		t.Errorf("expect status error without writing, got %v and %d bytes", err, buf.Len())
+	}
+}
+
+func TestRequesterProxy(t *testing.T) {
+	server := newTransferServer()
+	defer server.Close()
+
+	ms, err := ihttp.New(ihttp.WithAPIConfig(&ihttp.APIConfig{}))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	defer ms.Cleanup()
+	ms.GET("/reports/:name", func(ctx ihttp.IContext) error {
+		requester, err := ctx.Requester(server.URL, 0)
+		if err != nil {
+			return err
+		}
+		_, err = requester.Proxy(ctx.WebContext().Response(), http.MethodGet, fmt.Sprint("/files/", ctx.Param("name")), nil, nil)
+		return err
+	})
+
+	rec := httptest.NewRecorder()
+	ms.GetEngine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/reports/report", nil))
+	if rec.Code != http.StatusCreated || rec.Header().Get("X-Report-Id") != "r-1" || rec.Body.String() != "id,name\n1,somchai\n" {
+		t.Errorf("unexpected proxied response %d %v %q", rec.Code, rec.Header(), rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	ms.GetEngine().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/reports/missing", nil))
+	if rec.Code != http.StatusNotFound || !strings.Contains(rec.Body.String(), "FILE_NOT_FOUND") {
+		t.Errorf("expect downstream status passed through, got %d %q", rec.Code, rec.Body.String())
+	}
+}
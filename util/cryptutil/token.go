@@ -10,27 +10,28 @@ type HashToken struct {
 }
 
 func IssueHashToken() (*HashToken, error) {
-	token:= RandToken(128)
-	hashStr, err := HashPassword(token,10)
-	if err != nil{
+	// 48 random bytes encode to 64 characters, bcrypt reject passwords longer than 72 bytes
+	token := RandToken(48)
+	hashStr, err := HashPassword(token, 10)
+	if err != nil {
 		return nil, err
 	}
 	//encode to base64
 	hashEncodeBase64 := base64.StdEncoding.EncodeToString([]byte(hashStr))
 	hashToken := &HashToken{
-		Token:token,
-		Hash:hashEncodeBase64,
+		Token: token,
+		Hash:  hashEncodeBase64,
 	}
 	return hashToken, nil
 }
 
-func ValidHashToken(hashToken *HashToken) bool{
-	if hashToken == nil{
+func ValidHashToken(hashToken *HashToken) bool {
+	if hashToken == nil {
 		return false
 	}
 
 	hashDecodeByte, err := base64.StdEncoding.DecodeString(hashToken.Hash)
-	if err != nil{
+	if err != nil {
 		return false
 	}
 
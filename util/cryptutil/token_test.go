@@ -0,0 +1,29 @@
+package cryptutil_test
+
+import (
+	"testing"
+
+	"github.com/gitkeng/ihttp/util/cryptutil"
+)
+
+func TestIssueHashToken(t *testing.T) {
+	hashToken, err := cryptutil.IssueHashToken()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+	if !cryptutil.ValidHashToken(hashToken) {
+		t.Errorf("expect the issued token to be valid")
+	}
+
+	tests := map[string]*cryptutil.HashToken{
+		"nil":            nil,
+		"other token":    {Token: cryptutil.RandToken(48), Hash: hashToken.Hash},
+		"invalid base64": {Token: hashToken.Token, Hash: "not base64!"},
+	}
+	for name, test := range tests {
+		if cryptutil.ValidHashToken(test) {
+			t.Errorf("%s: expect invalid token", name)
+		}
+	}
+}
@@ -0,0 +1,189 @@
+package dbutil
+
+import (
+	"strings"
+)
+
+// NormalizeSQL return the statement shape for logging and metrics.
+//
+// String and numeric literals become ?, comments are removed, whitespace is
+// collapsed and a list of placeholders inside IN (...) or VALUES (...) is
+// folded to a single ?, so statements that differ only by their values share
+// one key and no value reach the log. # starts a comment in the MySQL dialect only.
+func NormalizeSQL(sql string, dialect Dialect) string {
+	var sb strings.Builder
+	sb.Grow(len(sql))
+	space := false
+	write := func(token string) {
+		if space && sb.Len() > 0 {
+			sb.WriteByte(' ')
+		}
+		space = false
+		sb.WriteString(token)
+	}
+
+	pos := 0
+	for pos < len(sql) {
+		c := sql[pos]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			space = true
+			pos++
+		case strings.HasPrefix(sql[pos:], "--") || (c == '#' && dialect == DialectMySQL):
+			end := strings.IndexByte(sql[pos:], '\n')
+			if end < 0 {
+				pos = len(sql)
+			} else {
+				pos += end
+			}
+			space = true
+		case strings.HasPrefix(sql[pos:], "/*"):
+			end := strings.Index(sql[pos+2:], "*/")
+			if end < 0 {
+				pos = len(sql)
+			} else {
+				pos += end + 4
+			}
+			space = true
+		case c == '\'':
+			pos = skipLiteral(sql, pos, '\'')
+			write("?")
+		case c == '"' || c == '`':
+			end := skipLiteral(sql, pos, c)
+			write(sql[pos:end])
+			pos = end
+		case c == '$' && pos+1 < len(sql) && sql[pos+1] >= '0' && sql[pos+1] <= '9':
+			end := pos + 1
+			for end < len(sql) && sql[end] >= '0' && sql[end] <= '9' {
+				end++
+			}
+			write("?")
+			pos = end
+		case c >= '0' && c <= '9' && (pos == 0 || !isIdentChar(sql[pos-1])):
+			end := pos
+			for end < len(sql) && (isIdentChar(sql[end]) || sql[end] == '.') {
+				end++
+			}
+			write("?")
+			pos = end
+		case isIdentChar(c):
+			end := pos
+			for end < len(sql) && isIdentChar(sql[end]) {
+				end++
+			}
+			write(sql[pos:end])
+			pos = end
+		default:
+			write(string(c))
+			space = c == ','
+			pos++
+		}
+	}
+	return foldPlaceholderList(sb.String())
+}
+
+// skipLiteral return the position after the quoted literal starting at pos
+func skipLiteral(sql string, pos int, quote byte) int {
+	end := pos + 1
+	for end < len(sql) {
+		if sql[end] == '\\' && quote == '\'' {
+			end += 2
+			continue
+		}
+		if sql[end] == quote {
+			if end+1 < len(sql) && sql[end+1] == quote {
+				end += 2
+				continue
+			}
+			return end + 1
+		}
+		end++
+	}
+	return len(sql)
+}
+
+// foldPlaceholderList fold IN (?, ?, ?) to IN (?) and the VALUES rows to one row,
+// the other placeholder lists such as function arguments are kept
+func foldPlaceholderList(sql string) string {
+	var sb strings.Builder
+	sb.Grow(len(sql))
+	pos := 0
+	for pos < len(sql) {
+		keyword := listKeywordAt(sql, pos)
+		if keyword == "" {
+			sb.WriteByte(sql[pos])
+			pos++
+			continue
+		}
+		open := pos + len(keyword)
+		for open < len(sql) && sql[open] == ' ' {
+			open++
+		}
+		end := closingParen(sql, open)
+		if end < 0 {
+			sb.WriteString(sql[pos:open])
+			pos = open
+			continue
+		}
+		sb.WriteString(sql[pos:open])
+		row := foldRow(sql[open:end])
+		sb.WriteString(row)
+		pos = end
+		if keyword != "VALUES" {
+			continue
+		}
+		// the repeated rows of a multi-row insert
+		for strings.HasPrefix(sql[pos:], ", (") {
+			next := closingParen(sql, pos+2)
+			if next < 0 || foldRow(sql[pos+2:next]) != row {
+				break
+			}
+			pos = next
+		}
+	}
+	return sb.String()
+}
+
+// listKeywordAt return IN or VALUES when the word at pos is one of them
+func listKeywordAt(sql string, pos int) string {
+	if pos > 0 && isIdentChar(sql[pos-1]) {
+		return ""
+	}
+	for _, keyword := range []string{"IN", "VALUES"} {
+		end := pos + len(keyword)
+		if end <= len(sql) && strings.EqualFold(sql[pos:end], keyword) && (end == len(sql) || !isIdentChar(sql[end])) {
+			return keyword
+		}
+	}
+	return ""
+}
+
+// closingParen return the position after the parenthesis matching the one at open, -1 if there is none
+func closingParen(sql string, open int) int {
+	if open >= len(sql) || sql[open] != '(' {
+		return -1
+	}
+	depth := 0
+	for pos := open; pos < len(sql); pos++ {
+		switch sql[pos] {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return pos + 1
+			}
+		}
+	}
+	return -1
+}
+
+// foldRow fold a row made of placeholders only to (?)
+func foldRow(row string) string {
+	for _, item := range strings.Split(row[1:len(row)-1], ",") {
+		if strings.TrimSpace(item) != "?" {
+			return row
+		}
+	}
+	return "(?)"
+}
@@ -0,0 +1,328 @@
+package dbutil
+
+import (
+	"os"
+	"strings"
+)
+
+// Dialect is the SQL syntax used to split a script into statements
+type Dialect string
+
+const (
+	DialectPostgres Dialect = "postgres"
+	DialectMySQL    Dialect = "mysql"
+	DialectSQLite   Dialect = "sqlite"
+)
+
+const defaultDelimiter = ";"
+
+// SQLStatement is a single statement split from a SQL script
+type SQLStatement struct {
+	// File is the script file name, empty when split from a string
+	File string
+	// Line is the 1-based line number where the statement starts
+	Line int
+	// SQL is the statement text without its delimiter
+	SQL string
+}
+
+// SQLStatementLoader load the sql file and split it into statements
+func SQLStatementLoader(fileLocation string, dialect Dialect) ([]SQLStatement, error) {
+	content, err := os.ReadFile(fileLocation)
+	if err != nil {
+		return nil, err
+	}
+	statements := SplitSQL(string(content), dialect)
+	for idx := range statements {
+		statements[idx].File = fileLocation
+	}
+	return statements, nil
+}
+
+// SplitSQL split the sql script into statements.
+//
+// It skips delimiters inside comments, quoted strings and identifiers,
+// Postgres dollar-quoted bodies ($$ ... $$, $tag$ ... $tag$), SQLite
+// CREATE TRIGGER ... BEGIN ... END bodies and handles the MySQL client
+// DELIMITER command.
+func SplitSQL(script string, dialect Dialect) []SQLStatement {
+	s := &sqlSplitter{
+		src:       script,
+		dialect:   dialect,
+		delimiter: defaultDelimiter,
+		line:      1,
+		start:     -1,
+	}
+	return s.split()
+}
+
+type sqlSplitter struct {
+	src        string
+	dialect    Dialect
+	delimiter  string
+	pos        int
+	line       int
+	start      int
+	startLine  int
+	statements []SQLStatement
+	// words, create, trigger and depth follow the keywords of the current sqlite statement,
+	// depth is the BEGIN/CASE ... END nesting of a trigger body
+	words   int
+	create  bool
+	trigger bool
+	depth   int
+}
+
+func (s *sqlSplitter) split() []SQLStatement {
+	for s.pos < len(s.src) {
+		c := s.src[s.pos]
+		switch {
+		case c == '\n':
+			s.line++
+			s.pos++
+		case c == ' ' || c == '\t' || c == '\r' || c == '\f':
+			s.pos++
+		case s.hasPrefix("--") || (c == '#' && s.dialect == DialectMySQL):
+			s.skipLineComment()
+		case s.hasPrefix("/*"):
+			s.skipBlockComment()
+		case s.start < 0 && s.dialect == DialectMySQL && s.isDelimiterCommand():
+			s.readDelimiterCommand()
+		case s.hasPrefix(s.delimiter):
+			if s.dialect == DialectSQLite && s.inTriggerBody() {
+				s.pos += len(s.delimiter)
+				continue
+			}
+			s.flush(s.pos)
+			s.pos += len(s.delimiter)
+		case s.dialect == DialectSQLite && isIdentChar(c):
+			s.mark()
+			s.readKeyword()
+		default:
+			s.mark()
+			s.skipToken()
+		}
+	}
+	s.flush(len(s.src))
+	return s.statements
+}
+
+// inTriggerBody report whether the delimiter at pos is inside a sqlite trigger body
+func (s *sqlSplitter) inTriggerBody() bool {
+	return s.start >= 0 && s.trigger && s.depth > 0
+}
+
+// readKeyword read the word at pos and follow the CREATE [TEMP] TRIGGER statements,
+// the words inside comments, strings and quoted identifiers never reach it
+func (s *sqlSplitter) readKeyword() {
+	end := s.pos
+	for end < len(s.src) && isIdentChar(s.src[end]) {
+		end++
+	}
+	word := strings.ToUpper(s.src[s.pos:end])
+	s.pos = end
+
+	switch {
+	case s.words == 0:
+		s.create = word == "CREATE"
+	case s.create && s.words <= 2 && word == "TRIGGER":
+		s.trigger = true
+	case !s.trigger:
+	case word == "BEGIN" || word == "CASE":
+		s.depth++
+	case word == "END" && s.depth > 0:
+		s.depth--
+	}
+	s.words++
+}
+
+func (s *sqlSplitter) hasPrefix(prefix string) bool {
+	return strings.HasPrefix(s.src[s.pos:], prefix)
+}
+
+// mark remember where the current statement starts
+func (s *sqlSplitter) mark() {
+	if s.start < 0 {
+		s.start = s.pos
+		s.startLine = s.line
+	}
+}
+
+func (s *sqlSplitter) flush(end int) {
+	if s.start < 0 {
+		return
+	}
+	sql := strings.TrimSpace(s.src[s.start:end])
+	if sql != "" {
+		s.statements = append(s.statements, SQLStatement{
+			Line: s.startLine,
+			SQL:  sql,
+		})
+	}
+	s.start = -1
+	s.words = 0
+	s.create = false
+	s.trigger = false
+	s.depth = 0
+}
+
+// advance move the cursor to end and keep the line counter in sync
+func (s *sqlSplitter) advance(end int) {
+	if end > len(s.src) {
+		end = len(s.src)
+	}
+	s.line += strings.Count(s.src[s.pos:end], "\n")
+	s.pos = end
+}
+
+func (s *sqlSplitter) skipLineComment() {
+	idx := strings.IndexByte(s.src[s.pos:], '\n')
+	if idx < 0 {
+		s.pos = len(s.src)
+		return
+	}
+	// leave the newline to the main loop
+	s.pos += idx
+}
+
+func (s *sqlSplitter) skipBlockComment() {
+	depth := 0
+	end := s.pos
+	for end < len(s.src) {
+		switch {
+		case strings.HasPrefix(s.src[end:], "/*"):
+			depth++
+			end += 2
+		case strings.HasPrefix(s.src[end:], "*/"):
+			depth--
+			end += 2
+			// only postgres supports nested block comments
+			if depth == 0 || s.dialect != DialectPostgres {
+				s.advance(end)
+				return
+			}
+		default:
+			end++
+		}
+	}
+	s.advance(end)
+}
+
+func (s *sqlSplitter) skipToken() {
+	c := s.src[s.pos]
+	switch {
+	case c == '\'':
+		backslash := s.dialect == DialectMySQL || s.isEscapeStringPrefix()
+		s.skipQuoted('\'', backslash)
+	case c == '"':
+		s.skipQuoted('"', s.dialect == DialectMySQL)
+	case c == '`' && (s.dialect == DialectMySQL || s.dialect == DialectSQLite):
+		s.skipQuoted('`', false)
+	case c == '[' && s.dialect == DialectSQLite:
+		if end := strings.IndexByte(s.src[s.pos:], ']'); end >= 0 {
+			s.advance(s.pos + end + 1)
+		} else {
+			s.advance(len(s.src))
+		}
+	case c == '$' && s.dialect == DialectPostgres:
+		if tag, ok := s.dollarTag(); ok {
+			end := strings.Index(s.src[s.pos+len(tag):], tag)
+			if end < 0 {
+				s.advance(len(s.src))
+			} else {
+				s.advance(s.pos + len(tag) + end + len(tag))
+			}
+			return
+		}
+		s.pos++
+	default:
+		s.pos++
+	}
+}
+
+// skipQuoted skip a quoted span, a doubled quote is an escaped quote
+func (s *sqlSplitter) skipQuoted(quote byte, backslash bool) {
+	end := s.pos + 1
+	for end < len(s.src) {
+		c := s.src[end]
+		if backslash && c == '\\' {
+			end += 2
+			continue
+		}
+		if c == quote {
+			if end+1 < len(s.src) && s.src[end+1] == quote {
+				end += 2
+				continue
+			}
+			s.advance(end + 1)
+			return
+		}
+		end++
+	}
+	s.advance(len(s.src))
+}
+
+// isEscapeStringPrefix report whether the quote at pos opens a postgres E'...' string
+func (s *sqlSplitter) isEscapeStringPrefix() bool {
+	if s.pos == 0 {
+		return false
+	}
+	prev := s.src[s.pos-1]
+	if prev != 'E' && prev != 'e' {
+		return false
+	}
+	return s.pos < 2 || !isIdentChar(s.src[s.pos-2])
+}
+
+// dollarTag return the dollar quote tag ($$ or $tag$) starting at pos
+func (s *sqlSplitter) dollarTag() (string, bool) {
+	if s.pos > 0 && isIdentChar(s.src[s.pos-1]) {
+		return "", false
+	}
+	end := s.pos + 1
+	for end < len(s.src) {
+		c := s.src[end]
+		if c == '$' {
+			return s.src[s.pos : end+1], true
+		}
+		if !isIdentChar(c) || (end == s.pos+1 && c >= '0' && c <= '9') {
+			return "", false
+		}
+		end++
+	}
+	return "", false
+}
+
+func (s *sqlSplitter) isDelimiterCommand() bool {
+	const keyword = "delimiter"
+	if len(s.src)-s.pos <= len(keyword) {
+		return false
+	}
+	if !strings.EqualFold(s.src[s.pos:s.pos+len(keyword)], keyword) {
+		return false
+	}
+	next := s.src[s.pos+len(keyword)]
+	return next == ' ' || next == '\t'
+}
+
+func (s *sqlSplitter) readDelimiterCommand() {
+	end := strings.IndexByte(s.src[s.pos:], '\n')
+	if end < 0 {
+		end = len(s.src)
+	} else {
+		end += s.pos
+	}
+	fields := strings.Fields(s.src[s.pos:end])
+	if len(fields) > 1 {
+		s.delimiter = fields[1]
+	}
+	s.pos = end
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' ||
+		(c >= 'a' && c <= 'z') ||
+		(c >= 'A' && c <= 'Z') ||
+		(c >= '0' && c <= '9') ||
+		c >= 0x80
+}
@@ -0,0 +1,163 @@
+package dbutil_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/gitkeng/ihttp/util/dbutil"
+)
+
+func TestSplitSQLPostgres(t *testing.T) {
+	script := `-- create table
+CREATE TABLE users (
+    id   SERIAL PRIMARY KEY,
+    name varchar(50) DEFAULT 'semi;colon'
+);
+/* block /* nested */ comment; */
+CREATE FUNCTION touch() RETURNS trigger AS $body$
+BEGIN
+    NEW.updated_at = now();
+    RETURN NEW;
+END;
+$body$ LANGUAGE plpgsql;
+
+INSERT INTO users (name) VALUES (E'it\'s;'), ('a''b;');
+SELECT $1::text`
+
+	statements := dbutil.SplitSQL(script, dbutil.DialectPostgres)
+	if len(statements) != 4 {
+		t.Errorf("expect 4 statements got %d", len(statements))
+		return
+	}
+	expectLines := []int{2, 7, 14, 15}
+	for idx, statement := range statements {
+		if statement.Line != expectLines[idx] {
+			t.Errorf("statement %d expect line %d got %d", idx, expectLines[idx], statement.Line)
+		}
+		t.Logf("line %d: %s", statement.Line, statement.SQL)
+	}
+}
+
+func TestSplitSQLMySQL(t *testing.T) {
+	script := `# create table
+CREATE TABLE ` + "`users;`" + ` (id INT, name VARCHAR(50) DEFAULT "a\";b");
+
+DELIMITER //
+CREATE PROCEDURE hello()
+BEGIN
+    SELECT 'hello;';
+    SELECT 1;
+END //
+DELIMITER ;
+CALL hello();`
+
+	statements := dbutil.SplitSQL(script, dbutil.DialectMySQL)
+	if len(statements) != 3 {
+		t.Errorf("expect 3 statements got %d", len(statements))
+		return
+	}
+	expectLines := []int{2, 5, 11}
+	for idx, statement := range statements {
+		if statement.Line != expectLines[idx] {
+			t.Errorf("statement %d expect line %d got %d", idx, expectLines[idx], statement.Line)
+		}
+		t.Logf("line %d: %s", statement.Line, statement.SQL)
+	}
+}
+
+func TestSQLStatementLoader(t *testing.T) {
+	fileName := "thai-division.sql"
+	statements, err := dbutil.SQLStatementLoader(fileName, dbutil.DialectPostgres)
+	if err != nil {
+		t.Errorf("%s", err)
+		return
+	}
+	if len(statements) != 3 {
+		t.Errorf("expect 3 statements got %d", len(statements))
+		return
+	}
+	for _, statement := range statements {
+		t.Logf("%s:%d", statement.File, statement.Line)
+	}
+}
+
+func TestSplitSQLSQLite(t *testing.T) {
+	script := `CREATE TABLE users (id INTEGER PRIMARY KEY, name TEXT);
+CREATE TRIGGER users_delete AFTER DELETE ON users
+BEGIN
+    DELETE FROM orders WHERE user_id = OLD.id;
+    INSERT INTO audit VALUES ('delete;');
+END;
+INSERT INTO users VALUES (1, 'somchai');`
+
+	statements := dbutil.SplitSQL(script, dbutil.DialectSQLite)
+	if len(statements) != 3 {
+		t.Errorf("expect 3 statements got %d", len(statements))
+		return
+	}
+	expectLines := []int{1, 2, 7}
+	for idx, statement := range statements {
+		if statement.Line != expectLines[idx] {
+			t.Errorf("statement %d expect line %d got %d", idx, expectLines[idx], statement.Line)
+		}
+		t.Logf("line %d: %s", statement.Line, statement.SQL)
+	}
+}
+
+func TestSplitSQLSQLiteTriggerCase(t *testing.T) {
+	script := `CREATE TEMP TRIGGER IF NOT EXISTS orders_total AFTER UPDATE ON orders
+BEGIN
+    UPDATE orders SET status = CASE WHEN NEW.total > 100 THEN 'big' ELSE 'small' END;
+    UPDATE [end] SET note = 'case end;' WHERE id = NEW.id;
+    INSERT INTO audit VALUES (CASE NEW.status WHEN 'paid' THEN 1 ELSE 0 END);
+END;
+CREATE TABLE t (state TEXT DEFAULT 'end');
+BEGIN;
+INSERT INTO t VALUES ('x');
+END;`
+
+	statements := dbutil.SplitSQL(script, dbutil.DialectSQLite)
+	expectLines := []int{1, 7, 8, 9, 10}
+	if len(statements) != len(expectLines) {
+		t.Errorf("expect %d statements got %d", len(expectLines), len(statements))
+		return
+	}
+	for idx, statement := range statements {
+		if statement.Line != expectLines[idx] {
+			t.Errorf("statement %d expect line %d got %d", idx, expectLines[idx], statement.Line)
+		}
+	}
+	if !strings.HasSuffix(statements[0].SQL, "END") {
+		t.Errorf("expect the whole trigger got %s", statements[0].SQL)
+	}
+}
+
+func TestNormalizeSQL(t *testing.T) {
+	cases := map[string]string{
+		"SELECT *\n  FROM users -- all\n WHERE id = 10 AND name = 'it''s'":         "SELECT * FROM users WHERE id = ? AND name = ?",
+		"select id from t1 where id in ($1, $2, $3)":                               "select id from t1 where id in (?)",
+		"INSERT INTO users (name, age) VALUES (?, 1), (?, 2), (?, 3)":              "INSERT INTO users (name, age) VALUES (?)",
+		"UPDATE `users` SET score = -1.5e3 /* note */ WHERE id = ?":                "UPDATE `users` SET score = -? WHERE id = ?",
+		"SELECT coalesce(?, ?) FROM t WHERE a IN(?,?) AND b IN (SELECT id FROM u)": "SELECT coalesce(?, ?) FROM t WHERE a IN(?) AND b IN (SELECT id FROM u)",
+		"INSERT INTO t (a, b) VALUES (?, now()), (?, now())":                       "INSERT INTO t (a, b) VALUES (?, now())",
+	}
+	for sql, expect := range cases {
+		if got := dbutil.NormalizeSQL(sql, dbutil.DialectPostgres); got != expect {
+			t.Errorf("normalize %q expect %q got %q", sql, expect, got)
+		}
+	}
+
+	dialects := []struct {
+		dialect dbutil.Dialect
+		expect  string
+	}{
+		{dbutil.DialectPostgres, "SELECT data #>> ? FROM t WHERE data #> ? IS NOT NULL"},
+		{dbutil.DialectMySQL, "SELECT data"},
+	}
+	for _, test := range dialects {
+		sql := "SELECT data #>> '{a,b}' FROM t WHERE data #> '{a}' IS NOT NULL"
+		if got := dbutil.NormalizeSQL(sql, test.dialect); got != test.expect {
+			t.Errorf("normalize %s %q expect %q got %q", test.dialect, sql, test.expect, got)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package retryutil
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+const (
+	DefaultMultiplier          = 2.0
+	DefaultRandomizationFactor = 0.5
+)
+
+// Backoff compute exponential backoff intervals with jitter
+type Backoff struct {
+	// InitialInterval is the interval before the first retry
+	InitialInterval time.Duration
+	// MaxInterval cap the interval before jitter is applied
+	MaxInterval time.Duration
+	// Multiplier grow the interval on every attempt
+	Multiplier float64
+	// RandomizationFactor spread the interval to [interval*(1-factor), interval*(1+factor)]
+	RandomizationFactor float64
+}
+
+// NewBackoff return Backoff with the default multiplier and randomization factor
+func NewBackoff(initialInterval time.Duration, maxInterval time.Duration) *Backoff {
+	return &Backoff{
+		InitialInterval:     initialInterval,
+		MaxInterval:         maxInterval,
+		Multiplier:          DefaultMultiplier,
+		RandomizationFactor: DefaultRandomizationFactor,
+	}
+}
+
+// Next return the interval to wait after the attempt, attempt start from 0
+func (b *Backoff) Next(attempt int) time.Duration {
+	if attempt < 0 {
+		attempt = 0
+	}
+	multiplier := b.Multiplier
+	if multiplier < 1 {
+		multiplier = 1
+	}
+	interval := float64(b.InitialInterval) * math.Pow(multiplier, float64(attempt))
+	if b.MaxInterval > 0 && interval > float64(b.MaxInterval) {
+		interval = float64(b.MaxInterval)
+	}
+	if b.RandomizationFactor > 0 {
+		delta := b.RandomizationFactor * interval
+		interval = interval - delta + rand.Float64()*(2*delta)
+	}
+	return time.Duration(interval)
+}
+
+// Sleep wait for the interval of the attempt, it return false when stop is closed first
+func (b *Backoff) Sleep(attempt int, stop <-chan struct{}) bool {
+	timer := time.NewTimer(b.Next(attempt))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-stop:
+		return false
+	}
+}
@@ -0,0 +1,32 @@
+package retryutil_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/gitkeng/ihttp/util/retryutil"
+)
+
+func TestBackoffNext(t *testing.T) {
+	backoff := retryutil.NewBackoff(100*time.Millisecond, 2*time.Second)
+	for attempt := 0; attempt < 10; attempt++ {
+		interval := backoff.Next(attempt)
+		base := 100 * time.Millisecond << attempt
+		if base > 2*time.Second {
+			base = 2 * time.Second
+		}
+		if interval < base/2 || interval > base*3/2 {
+			t.Errorf("attempt %d interval %s out of range of %s", attempt, interval, base)
+		}
+		t.Logf("attempt %d interval %s", attempt, interval)
+	}
+}
+
+func TestBackoffSleepStop(t *testing.T) {
+	backoff := retryutil.NewBackoff(time.Hour, time.Hour)
+	stop := make(chan struct{})
+	close(stop)
+	if backoff.Sleep(0, stop) {
+		t.Errorf("expect sleep to be stopped")
+	}
+}